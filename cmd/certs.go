@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+
+	"github.com/yuyicai/kubei/internal/options"
+	certphases "github.com/yuyicai/kubei/internal/phases/cert"
+	"github.com/yuyicai/kubei/internal/preflight"
+	"github.com/yuyicai/kubei/internal/rundata"
+)
+
+// NewCmdCerts returns "kubei certs" command.
+func NewCmdCerts(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "certs",
+		Short: "Commands related to handling kubernetes certificates",
+	}
+
+	cmd.AddCommand(NewCmdCertsRenew(out, nil))
+	cmd.AddCommand(NewCmdCertsBackup(out, nil))
+	cmd.AddCommand(NewCmdCertsRestore(out, nil))
+	return cmd
+}
+
+// NewCmdCertsRenew returns "kubei certs renew" command.
+func NewCmdCertsRenew(out io.Writer, runOptions *runOptions) *cobra.Command {
+	if runOptions == nil {
+		runOptions = newCertsOptions()
+	}
+
+	cluster := &rundata.Cluster{}
+
+	cmd := &cobra.Command{
+		Use:   "renew",
+		Short: "Renew the certificates and kubeconfig files of the masters without regenerating the CAs",
+		Long: "Renew the certificates and kubeconfig files of the masters without regenerating the CAs. " +
+			"The CAs are fetched from master0, or loaded from --cert-dir if it is set.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			data, err := newCertsData(runOptions)
+			if err != nil {
+				return err
+			}
+			cluster = data.Cluster()
+			return preflight.CertsPrepare(cluster)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return certphases.RenewCert(cluster)
+		},
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+			preflight.CloseExecutors(cluster)
+			return nil
+		},
+		Args: cobra.NoArgs,
+	}
+
+	addCertsRenewConfigFlags(cmd.Flags(), runOptions.kubei)
+	options.AddKubeadmConfigFlags(cmd.Flags(), runOptions.kubeadm)
+
+	return cmd
+}
+
+// NewCmdCertsBackup returns "kubei certs backup" command.
+func NewCmdCertsBackup(out io.Writer, runOptions *runOptions) *cobra.Command {
+	if runOptions == nil {
+		runOptions = newCertsOptions()
+	}
+
+	cluster := &rundata.Cluster{}
+
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Back up the CAs, the service account key pair and admin.conf of master0 to a local dir or an encrypted archive",
+		Long: "Back up the CAs, the service account key pair and admin.conf of master0 to a local dir or an encrypted archive. " +
+			"The backup dir has the same layout as /etc/kubernetes/pki, so that it can be used as --cert-dir to rebuild the control plane with the same trust roots.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			data, err := newCertsData(runOptions)
+			if err != nil {
+				return err
+			}
+			cluster = data.Cluster()
+			return preflight.CertsPrepare(cluster)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return certphases.BackupCert(cluster)
+		},
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+			preflight.CloseExecutors(cluster)
+			return nil
+		},
+		Args: cobra.NoArgs,
+	}
+
+	addCertsBackupConfigFlags(cmd.Flags(), runOptions.kubei)
+	_ = cmd.MarkFlagRequired(options.Backup)
+
+	return cmd
+}
+
+// NewCmdCertsRestore returns "kubei certs restore" command.
+func NewCmdCertsRestore(out io.Writer, runOptions *runOptions) *cobra.Command {
+	if runOptions == nil {
+		runOptions = newCertsOptions()
+	}
+
+	cluster := &rundata.Cluster{}
+
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Restore the CAs, the service account key pair and admin.conf of a backup to the masters",
+		Long: "Restore the CAs, the service account key pair and admin.conf of a backup to the masters. " +
+			"The files existing on the masters are not overwritten unless --force is set.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			data, err := newCertsData(runOptions)
+			if err != nil {
+				return err
+			}
+			cluster = data.Cluster()
+			return preflight.CertsPrepare(cluster)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return certphases.RestoreCert(cluster)
+		},
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+			preflight.CloseExecutors(cluster)
+			return nil
+		},
+		Args: cobra.NoArgs,
+	}
+
+	addCertsBackupConfigFlags(cmd.Flags(), runOptions.kubei)
+	options.AddForceFlags(cmd.Flags(), &runOptions.kubei.Backup.Force)
+	_ = cmd.MarkFlagRequired(options.Backup)
+
+	return cmd
+}
+
+func addCertsBackupConfigFlags(flagSet *flag.FlagSet, k *options.Kubei) {
+	options.AddPublicUserInfoConfigFlags(flagSet, &k.ClusterNodes.PublicHostInfo)
+	options.AddKubeClusterNodesConfigFlags(flagSet, &k.ClusterNodes)
+	options.AddJumpServerFlags(flagSet, &k.JumpServer)
+	options.AddBackupFlags(flagSet, &k.Backup)
+}
+
+func addCertsRenewConfigFlags(flagSet *flag.FlagSet, k *options.Kubei) {
+	options.AddPublicUserInfoConfigFlags(flagSet, &k.ClusterNodes.PublicHostInfo)
+	options.AddKubeClusterNodesConfigFlags(flagSet, &k.ClusterNodes)
+	options.AddJumpServerFlags(flagSet, &k.JumpServer)
+	options.AddCertNotAfterTimeFlags(flagSet, &k.CertNotAfterTime)
+	options.AddCertificatesDirFlags(flagSet, &k.CertificatesDir)
+	options.AddCertKeyAlgorithmFlags(flagSet, &k.CertKeyAlgorithm)
+}
+
+func newCertsOptions() *runOptions {
+	kubeiOptions := options.NewKubei()
+	kubeadmOptions := options.NewKubeadm()
+
+	return &runOptions{
+		kubei:   kubeiOptions,
+		kubeadm: kubeadmOptions,
+	}
+}
+
+func newCertsData(options *runOptions) (*runData, error) {
+	clusterCfg := rundata.NewCluster()
+
+	options.kubei.ApplyTo(clusterCfg.Kubei)
+	options.kubeadm.ApplyTo(clusterCfg.Kubeadm)
+
+	rundata.DefaultKubeiCfg(clusterCfg.Kubei)
+	rundata.DefaultkubeadmCfg(clusterCfg.Kubeadm, clusterCfg.Kubei)
+
+	if err := rundata.ValidateCertCfg(clusterCfg.Kubei, clusterCfg.Kubeadm); err != nil {
+		return nil, err
+	}
+
+	if err := rundata.ValidateExecutors(clusterCfg.ClusterNodes.GetAllNodes()); err != nil {
+		return nil, err
+	}
+
+	if err := rundata.ValidateBecome(clusterCfg.ClusterNodes.GetAllNodes()); err != nil {
+		return nil, err
+	}
+
+	certsDatacfg := &runData{
+		cluster: clusterCfg,
+	}
+
+	return certsDatacfg, nil
+}
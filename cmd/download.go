@@ -3,32 +3,39 @@ package cmd
 import (
 	"io"
 
-	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
 	"k8s.io/klog"
 
+	"github.com/yuyicai/kubei/internal/options"
 	"github.com/yuyicai/kubei/internal/phases/download"
 )
 
 const DefaultKubernetesVersion = "v1.20.4"
 
 func NewCmdDownload(out io.Writer) *cobra.Command {
+	runOptions := newCertsOptions()
+
 	cmd := &cobra.Command{
 		Use:   "download",
 		Short: "download kubernetes files",
+		Long: "Download the offline files of the Kubernetes version from a registry, " +
+			"or the images needed by the Kubernetes version if --images is set.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return RunDownload(out, cmd)
+			klog.V(1).Infoln("download kubernetes files")
+			return download.Download(newLocalData(runOptions))
 		},
+		Args: cobra.NoArgs,
 	}
-	cmd.Flags().String("kube-version", DefaultKubernetesVersion, "kubernetes version")
+
+	addDownloadConfigFlags(cmd.Flags(), runOptions)
+
 	return cmd
 }
 
-func RunDownload(out io.Writer, cmd *cobra.Command) error {
-	klog.V(1).Infoln("download kubernetes files")
-	version, err := cmd.Flags().GetString("kube-version")
-	if err != nil {
-		return errors.Wrapf(err, "error accessing flag %s for command %s", "kube-version", cmd.Name())
-	}
-	return download.KubeFiles(version, "")
+func addDownloadConfigFlags(flagSet *flag.FlagSet, o *runOptions) {
+	flagSet.StringVar(&o.kubei.Kubernetes.Version, "kube-version", DefaultKubernetesVersion, "kubernetes version")
+	options.AddDownloadFlags(flagSet, &o.kubei.Download)
+	options.AddImageMetaFlags(flagSet, &o.kubeadm.ImageRepository)
+	options.AddNetworkPluginFlags(flagSet, &o.kubei.NetworkType)
 }
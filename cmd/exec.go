@@ -40,7 +40,7 @@ func NewCmdExec(out io.Writer, runOptions *runOptions) *cobra.Command {
 			return runExec(cluster, command)
 		},
 		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
-			preflight.CloseSSH(cluster)
+			preflight.CloseExecutors(cluster)
 			return nil
 		},
 		Args: cobra.NoArgs,
@@ -58,6 +58,7 @@ func addExecConfigFlags(flagSet *flag.FlagSet, k *options.Kubei, command *string
 	options.AddKubeClusterNodesConfigFlags(flagSet, &k.ClusterNodes)
 	options.AddJumpServerFlags(flagSet, &k.JumpServer)
 	options.AddExecCommandFlags(flagSet, command)
+	options.AddDryRunFlags(flagSet, &k.DryRun)
 }
 
 func newExecOptions() *runOptions {
@@ -79,6 +80,18 @@ func newExecData(options *runOptions) (*runData, error) {
 	rundata.DefaultKubeiCfg(clusterCfg.Kubei)
 	rundata.DefaultkubeadmCfg(clusterCfg.Kubeadm, clusterCfg.Kubei)
 
+	if err := rundata.ValidateExecutors(clusterCfg.ClusterNodes.GetAllNodes()); err != nil {
+		return nil, err
+	}
+
+	if err := rundata.ValidateBecome(clusterCfg.ClusterNodes.GetAllNodes()); err != nil {
+		return nil, err
+	}
+
+	if err := rundata.ValidateDryRun(&clusterCfg.DryRun); err != nil {
+		return nil, err
+	}
+
 	initDatacfg := &runData{
 		cluster: clusterCfg,
 	}
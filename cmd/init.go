@@ -40,7 +40,7 @@ func NewCmdInit(out io.Writer, initOptions *runOptions) *cobra.Command {
 			return initRunner.Run(args)
 		},
 		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
-			preflight.CloseSSH(cluster)
+			preflight.CloseExecutors(cluster)
 			return nil
 		},
 		Args: cobra.NoArgs,
@@ -52,11 +52,13 @@ func NewCmdInit(out io.Writer, initOptions *runOptions) *cobra.Command {
 	options.AddKubeadmConfigFlags(cmd.Flags(), initOptions.kubeadm)
 
 	// initialize the workflow runner with the list of phases
+	initRunner.AppendPhase(initphases.NewPreflightPhase())
 	initRunner.AppendPhase(initphases.NewSendPhase())
 	initRunner.AppendPhase(initphases.NewContainerEnginePhase())
 	initRunner.AppendPhase(initphases.NewKubeComponentPhase())
 	initRunner.AppendPhase(initphases.NewCertPhase())
 	initRunner.AppendPhase(initphases.NewKubeadmPhase())
+	initRunner.AppendPhase(initphases.NewKubeconfigPhase())
 
 	// sets the rundata builder function, that will be used by the runner
 	// both when running the entire workflow or single phases
@@ -73,11 +75,22 @@ func NewCmdInit(out io.Writer, initOptions *runOptions) *cobra.Command {
 
 func addInitConfigFlags(flagSet *flag.FlagSet, k *options.Kubei) {
 	options.AddContainerEngineConfigFlags(flagSet, &k.ContainerEngine)
+	options.AddRegistriesFlags(flagSet, &k.ContainerEngine.Registries)
 	options.AddPublicUserInfoConfigFlags(flagSet, &k.ClusterNodes.PublicHostInfo)
 	options.AddKubeClusterNodesConfigFlags(flagSet, &k.ClusterNodes)
 	options.AddJumpServerFlags(flagSet, &k.JumpServer)
 	options.AddOfflinePackageFlags(flagSet, &k.OfflineFile)
+	options.AddDistributionFlags(flagSet, &k.Distribution)
+	options.AddLocalRegistryFlags(flagSet, &k.LocalRegistry)
+	options.AddIgnorePreflightErrorsFlags(flagSet, &k.IgnorePreflightErrors)
+	options.AddPackageReposFlags(flagSet, &k.PackageRepos)
+	options.AddProxyFlags(flagSet, &k.Proxy)
+	options.AddDryRunFlags(flagSet, &k.DryRun)
 	options.AddCertNotAfterTimeFlags(flagSet, &k.CertNotAfterTime)
+	options.AddCertificatesDirFlags(flagSet, &k.CertificatesDir)
+	options.AddCertKeyAlgorithmFlags(flagSet, &k.CertKeyAlgorithm)
+	options.AddBackupFlags(flagSet, &k.Backup)
+	options.AddKubeconfigFlags(flagSet, &k.Kubeconfig)
 	options.AddNetworkPluginFlags(flagSet, &k.NetworkType)
 	options.AddKubernetesFlags(flagSet, &k.Kubernetes)
 	//options.AddOnlineFlags(flagSet, &k.Online)
@@ -103,6 +116,46 @@ func newInitData(cmd *cobra.Command, args []string, options *runOptions, out io.
 	rundata.DefaultKubeiCfg(clusterCfg.Kubei)
 	rundata.DefaultkubeadmCfg(clusterCfg.Kubeadm, clusterCfg.Kubei)
 
+	if err := rundata.ValidateCertCfg(clusterCfg.Kubei, clusterCfg.Kubeadm); err != nil {
+		return nil, err
+	}
+
+	if err := rundata.ValidateDistribution(&clusterCfg.Distribution); err != nil {
+		return nil, err
+	}
+
+	if err := rundata.ValidateRegistries(&clusterCfg.ContainerEngine.Registries); err != nil {
+		return nil, err
+	}
+
+	if err := rundata.ValidateLocalRegistry(clusterCfg.Kubei); err != nil {
+		return nil, err
+	}
+
+	if err := rundata.ValidatePackageRepos(&clusterCfg.PackageRepos); err != nil {
+		return nil, err
+	}
+
+	if err := rundata.ValidateProxy(&clusterCfg.Proxy); err != nil {
+		return nil, err
+	}
+
+	if err := rundata.ValidateExecutors(clusterCfg.ClusterNodes.GetAllNodes()); err != nil {
+		return nil, err
+	}
+
+	if err := rundata.ValidateBecome(clusterCfg.ClusterNodes.GetAllNodes()); err != nil {
+		return nil, err
+	}
+
+	if err := rundata.ValidateDryRun(&clusterCfg.DryRun); err != nil {
+		return nil, err
+	}
+
+	if err := rundata.ValidateIgnorePreflightErrors(clusterCfg.IgnorePreflightErrors); err != nil {
+		return nil, err
+	}
+
 	initDatacfg := &runData{
 		cluster: clusterCfg,
 	}
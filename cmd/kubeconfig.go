@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+
+	"github.com/yuyicai/kubei/internal/options"
+	certphases "github.com/yuyicai/kubei/internal/phases/cert"
+	"github.com/yuyicai/kubei/internal/preflight"
+	"github.com/yuyicai/kubei/internal/rundata"
+)
+
+// NewCmdKubeconfig returns "kubei kubeconfig" command.
+func NewCmdKubeconfig(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "kubeconfig",
+		Short: "Commands related to handling kubeconfig files",
+	}
+
+	cmd.AddCommand(NewCmdKubeconfigGet(out, nil))
+	return cmd
+}
+
+// NewCmdKubeconfigGet returns "kubei kubeconfig get" command.
+func NewCmdKubeconfigGet(out io.Writer, runOptions *runOptions) *cobra.Command {
+	if runOptions == nil {
+		runOptions = newCertsOptions()
+	}
+
+	cluster := &rundata.Cluster{}
+
+	cmd := &cobra.Command{
+		Use:   "get",
+		Short: "Merge a kubeconfig of the cluster into the local kubeconfig file of the operator",
+		Long: "Merge a kubeconfig of the cluster into the local kubeconfig file of the operator under a named context. " +
+			"The admin identity of master0 is used, or a short-lived client certificate signed by the cluster CA if --kubeconfig-client-name is set.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			data, err := newCertsData(runOptions)
+			if err != nil {
+				return err
+			}
+			cluster = data.Cluster()
+			return preflight.CertsPrepare(cluster)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return certphases.GetKubeConfig(cluster)
+		},
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+			preflight.CloseExecutors(cluster)
+			return nil
+		},
+		Args: cobra.NoArgs,
+	}
+
+	addKubeconfigGetConfigFlags(cmd.Flags(), runOptions.kubei)
+
+	return cmd
+}
+
+func addKubeconfigGetConfigFlags(flagSet *flag.FlagSet, k *options.Kubei) {
+	options.AddPublicUserInfoConfigFlags(flagSet, &k.ClusterNodes.PublicHostInfo)
+	options.AddKubeClusterNodesConfigFlags(flagSet, &k.ClusterNodes)
+	options.AddJumpServerFlags(flagSet, &k.JumpServer)
+	options.AddKubeconfigFlags(flagSet, &k.Kubeconfig)
+}
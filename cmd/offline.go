@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+
+	"github.com/yuyicai/kubei/internal/options"
+	offlinephases "github.com/yuyicai/kubei/internal/phases/offline"
+	"github.com/yuyicai/kubei/internal/rundata"
+)
+
+// NewCmdOffline returns "kubei offline" command.
+func NewCmdOffline(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "offline",
+		Short: "Commands related to handling offline packages",
+	}
+
+	cmd.AddCommand(NewCmdOfflineBuild(out, nil))
+	return cmd
+}
+
+// NewCmdOfflineBuild returns "kubei offline build" command.
+func NewCmdOfflineBuild(out io.Writer, runOptions *runOptions) *cobra.Command {
+	if runOptions == nil {
+		runOptions = newCertsOptions()
+	}
+
+	cmd := &cobra.Command{
+		Use:   "build",
+		Short: "Build the offline package from a bill of materials",
+		Long: "Build the offline package from a bill of materials (BOM) of the kube components, the container engine and the images. " +
+			"The files are verified by their sha256 checksums, and kubei-manifest.json with the checksums of all files is written into the package.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return offlinephases.Build(newLocalData(runOptions))
+		},
+		Args: cobra.NoArgs,
+	}
+
+	addOfflineBuildConfigFlags(cmd.Flags(), runOptions)
+
+	return cmd
+}
+
+func addOfflineBuildConfigFlags(flagSet *flag.FlagSet, o *runOptions) {
+	options.AddKubernetesFlags(flagSet, &o.kubei.Kubernetes)
+	options.AddContainerEngineConfigFlags(flagSet, &o.kubei.ContainerEngine)
+	options.AddNetworkPluginFlags(flagSet, &o.kubei.NetworkType)
+	options.AddImageMetaFlags(flagSet, &o.kubeadm.ImageRepository)
+	options.AddOfflineBuildFlags(flagSet, &o.kubei.OfflineBuild)
+	options.AddRegistryAuthFlags(flagSet, &o.kubei.Download)
+}
+
+// newLocalData returns the config of the commands which run locally without connecting to the nodes.
+func newLocalData(options *runOptions) *rundata.Cluster {
+	clusterCfg := rundata.NewCluster()
+
+	options.kubei.ApplyTo(clusterCfg.Kubei)
+	options.kubeadm.ApplyTo(clusterCfg.Kubeadm)
+
+	rundata.DefaultKubeiCfg(clusterCfg.Kubei)
+
+	return clusterCfg
+}
@@ -33,7 +33,18 @@ func getCertPhaseFlags() []string {
 		options.Port,
 		options.User,
 		options.Key,
+		options.Become,
+		options.BecomePassword,
+		options.DryRun,
+		options.DryRunDir,
+		options.DryRunOS,
+		options.IgnorePreflightErrors,
 		options.CertNotAfterTime,
+		options.CertificatesDir,
+		options.CertKeyAlgorithm,
+		options.APIServerCertSANs,
+		options.Backup,
+		options.BackupPassphraseFile,
 	}
 	return flags
 }
@@ -50,5 +61,12 @@ func runCert(c workflow.RunData) error {
 		return err
 	}
 
-	return certphases.SendCert(cluster)
+	if err := certphases.SendCert(cluster); err != nil {
+		return err
+	}
+
+	if cluster.Backup.Path != "" {
+		return certphases.BackupCert(cluster)
+	}
+	return nil
 }
@@ -27,12 +27,31 @@ func getContainerEnginePhaseFlags() []string {
 		options.OfflineFile,
 		options.JumpServer,
 		options.ContainerEngineVersion,
+		options.RegistryMirror,
+		options.InsecureRegistry,
+		options.RegistryAuth,
+		options.RegistryCA,
+		options.LocalRegistry,
+		options.LocalRegistryNode,
+		options.LocalRegistryPort,
+		options.DockerRepo,
+		options.DockerRepoGPGKey,
+		options.DockerRepoProxy,
+		options.HTTPProxy,
+		options.HTTPSProxy,
+		options.NoProxy,
 		options.Masters,
 		options.Workers,
 		options.Password,
 		options.Port,
 		options.User,
 		options.Key,
+		options.Become,
+		options.BecomePassword,
+		options.DryRun,
+		options.DryRunDir,
+		options.DryRunOS,
+		options.IgnorePreflightErrors,
 	}
 	return flags
 }
@@ -27,12 +27,21 @@ func getKubeComponentPhaseFlags() []string {
 		options.OfflineFile,
 		options.JumpServer,
 		options.KubernetesVersion,
+		options.KubernetesRepo,
+		options.KubernetesRepoGPGKey,
+		options.KubernetesRepoProxy,
 		options.Masters,
 		options.Workers,
 		options.Password,
 		options.Port,
 		options.User,
 		options.Key,
+		options.Become,
+		options.BecomePassword,
+		options.DryRun,
+		options.DryRunDir,
+		options.DryRunOS,
+		options.IgnorePreflightErrors,
 	}
 	return flags
 }
@@ -40,6 +40,15 @@ func getKubeadmPhaseFlags() []string {
 		options.Port,
 		options.User,
 		options.Key,
+		options.Become,
+		options.BecomePassword,
+		options.DryRun,
+		options.DryRunDir,
+		options.DryRunOS,
+		options.IgnorePreflightErrors,
+		options.LocalRegistry,
+		options.LocalRegistryNode,
+		options.LocalRegistryPort,
 	}
 	return flags
 }
@@ -61,6 +70,11 @@ func runKubeadm(c workflow.RunData) error {
 		return err
 	}
 
+	// push the offline images to the local registry on master0
+	if err := kubeadmphases.SeedLocalRegistry(cluster); err != nil {
+		return err
+	}
+
 	// add network plugin
 	if err := networkphases.Network(cluster); err != nil {
 		return err
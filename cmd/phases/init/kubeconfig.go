@@ -0,0 +1,58 @@
+package init
+
+import (
+	"errors"
+
+	"k8s.io/kubernetes/cmd/kubeadm/app/cmd/phases/workflow"
+
+	"github.com/yuyicai/kubei/cmd/phases"
+	"github.com/yuyicai/kubei/internal/options"
+	certphases "github.com/yuyicai/kubei/internal/phases/cert"
+)
+
+// NewKubeconfigPhase creates a kubei workflow phase that implements handling of the kubeconfig for the operator.
+func NewKubeconfigPhase() workflow.Phase {
+	phase := workflow.Phase{
+		Name:         "kubeconfig",
+		Short:        "merge the kubeconfig of the cluster into the local kubeconfig file",
+		Long:         "merge the kubeconfig of the cluster into the local kubeconfig file",
+		InheritFlags: getKubeconfigPhaseFlags(),
+		Run:          runKubeconfig,
+	}
+	return phase
+}
+
+func getKubeconfigPhaseFlags() []string {
+	flags := []string{
+		options.JumpServer,
+		options.Masters,
+		options.Workers,
+		options.Password,
+		options.Port,
+		options.User,
+		options.Key,
+		options.Become,
+		options.BecomePassword,
+		options.DryRun,
+		options.DryRunDir,
+		options.DryRunOS,
+		options.IgnorePreflightErrors,
+		options.Kubeconfig,
+		options.KubeconfigServer,
+		options.KubeconfigContext,
+		options.KubeconfigUseContext,
+		options.KubeconfigClientName,
+		options.KubeconfigClientGroups,
+		options.KubeconfigClientCertTTL,
+	}
+	return flags
+}
+
+func runKubeconfig(c workflow.RunData) error {
+	data, ok := c.(phases.RunData)
+	if !ok {
+		return errors.New("kubeconfig phase invoked with an invalid rundata struct")
+	}
+
+	return certphases.GetKubeConfig(data.Cluster())
+}
@@ -0,0 +1,61 @@
+package init
+
+import (
+	"errors"
+
+	"k8s.io/kubernetes/cmd/kubeadm/app/cmd/phases/workflow"
+
+	"github.com/yuyicai/kubei/cmd/phases"
+	"github.com/yuyicai/kubei/internal/options"
+	"github.com/yuyicai/kubei/internal/preflight"
+)
+
+// NewPreflightPhase creates a kubei workflow phase that implements the host checks of the nodes.
+func NewPreflightPhase() workflow.Phase {
+	phase := workflow.Phase{
+		Name:         "preflight",
+		Short:        "run the preflight checks of the nodes",
+		Long:         "run the preflight checks of the nodes before any node is changed",
+		InheritFlags: getPreflightPhaseFlags(),
+		Run:          runPreflight,
+	}
+	return phase
+}
+
+func getPreflightPhaseFlags() []string {
+	flags := []string{
+		options.JumpServer,
+		options.Masters,
+		options.Workers,
+		options.Password,
+		options.Port,
+		options.User,
+		options.Key,
+		options.Become,
+		options.BecomePassword,
+		options.DryRun,
+		options.DryRunDir,
+		options.DryRunOS,
+		options.IgnorePreflightErrors,
+		options.DistributionMode,
+		options.DistributionPort,
+		options.LocalRegistry,
+		options.LocalRegistryNode,
+		options.LocalRegistryPort,
+	}
+	return flags
+}
+
+func runPreflight(c workflow.RunData) error {
+	data, ok := c.(phases.RunData)
+	if !ok {
+		return errors.New("preflight phase invoked with an invalid rundata struct")
+	}
+
+	cluster := data.Cluster()
+	// the nodes aren't connected with --dry-run, there are no facts to check
+	if cluster.DryRun.Enable {
+		return nil
+	}
+	return preflight.RunChecks(cluster)
+}
@@ -25,6 +25,10 @@ func NewSendPhase() workflow.Phase {
 func getSendPhaseFlags() []string {
 	flags := []string{
 		options.OfflineFile,
+		options.KubernetesVersion,
+		options.DistributionMode,
+		options.DistributionPort,
+		options.DistributionFanout,
 		options.JumpServer,
 		options.Masters,
 		options.Workers,
@@ -32,6 +36,12 @@ func getSendPhaseFlags() []string {
 		options.Port,
 		options.User,
 		options.Key,
+		options.Become,
+		options.BecomePassword,
+		options.DryRun,
+		options.DryRunDir,
+		options.DryRunOS,
+		options.IgnorePreflightErrors,
 	}
 	return flags
 }
@@ -0,0 +1,58 @@
+package reset
+
+import (
+	"errors"
+
+	"k8s.io/kubernetes/cmd/kubeadm/app/cmd/phases/workflow"
+
+	"github.com/yuyicai/kubei/cmd/phases"
+	"github.com/yuyicai/kubei/internal/options"
+	resetphases "github.com/yuyicai/kubei/internal/phases/reset"
+)
+
+// NewHostPhase creates a kubei workflow phase that restores the files of the nodes changed by kubei.
+func NewHostPhase() workflow.Phase {
+	phase := workflow.Phase{
+		Name:         "host",
+		Short:        "restore the files of the nodes changed by kubei",
+		Long:         "restore the files of the nodes changed by kubei",
+		InheritFlags: getHostPhaseFlags(),
+		Run:          runHost,
+	}
+	return phase
+}
+
+func getHostPhaseFlags() []string {
+	flags := []string{
+		options.RestoreHost,
+		options.JumpServer,
+		options.Masters,
+		options.Workers,
+		options.Password,
+		options.Port,
+		options.User,
+		options.Key,
+		options.Become,
+		options.BecomePassword,
+		options.DryRun,
+		options.DryRunDir,
+		options.DryRunOS,
+	}
+	return flags
+}
+
+func runHost(c workflow.RunData) error {
+	data, ok := c.(phases.RunData)
+	if !ok {
+		return errors.New("reset phase invoked with an invalid rundata struct")
+	}
+
+	cfg := data.KubeiCfg()
+	cluster := data.Cluster()
+
+	if cfg.Reset.RestoreHost {
+		return resetphases.RestoreHost(cluster)
+	}
+
+	return nil
+}
@@ -30,6 +30,11 @@ func getKubeComponentPhaseFlags() []string {
 		options.Port,
 		options.User,
 		options.Key,
+		options.Become,
+		options.BecomePassword,
+		options.DryRun,
+		options.DryRunDir,
+		options.DryRunOS,
 	}
 	return flags
 }
@@ -38,7 +38,7 @@ func NewCmdReset(out io.Writer, runOptions *runOptions) *cobra.Command {
 			return resetRunner.Run(args)
 		},
 		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
-			preflight.CloseSSH(cluster)
+			preflight.CloseExecutors(cluster)
 			return nil
 		},
 		Args: cobra.NoArgs,
@@ -53,6 +53,7 @@ func NewCmdReset(out io.Writer, runOptions *runOptions) *cobra.Command {
 	resetRunner.AppendPhase(phases.NewKubeadmPhase())
 	resetRunner.AppendPhase(phases.NewKubeComponentPhase())
 	resetRunner.AppendPhase(phases.NewContainerEnginePhase())
+	resetRunner.AppendPhase(phases.NewHostPhase())
 
 	// sets the rundata builder function, that will be used by the runner
 	// both when running the entire workflow or single phases
@@ -72,6 +73,7 @@ func addResetConfigFlags(flagSet *flag.FlagSet, k *options.Kubei) {
 	options.AddKubeClusterNodesConfigFlags(flagSet, &k.ClusterNodes)
 	options.AddJumpServerFlags(flagSet, &k.JumpServer)
 	options.AddResetFlags(flagSet, &k.Reset)
+	options.AddDryRunFlags(flagSet, &k.DryRun)
 }
 
 func newResetOptions() *runOptions {
@@ -93,6 +95,18 @@ func newResetData(cmd *cobra.Command, args []string, options *runOptions, out io
 	rundata.DefaultKubeiCfg(clusterCfg.Kubei)
 	rundata.DefaultkubeadmCfg(clusterCfg.Kubeadm, clusterCfg.Kubei)
 
+	if err := rundata.ValidateExecutors(clusterCfg.ClusterNodes.GetAllNodes()); err != nil {
+		return nil, err
+	}
+
+	if err := rundata.ValidateBecome(clusterCfg.ClusterNodes.GetAllNodes()); err != nil {
+		return nil, err
+	}
+
+	if err := rundata.ValidateDryRun(&clusterCfg.DryRun); err != nil {
+		return nil, err
+	}
+
 	initDatacfg := &runData{
 		cluster: clusterCfg,
 	}
@@ -28,6 +28,9 @@ func NewKubeiCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 	cmds.AddCommand(NewCmdVersion(out))
 	cmds.AddCommand(NewCmdDownload(out))
 	cmds.AddCommand(NewCmdExec(out, nil))
+	cmds.AddCommand(NewCmdCerts(out))
+	cmds.AddCommand(NewCmdKubeconfig(out))
+	cmds.AddCommand(NewCmdOffline(out))
 	return cmds
 
 }
@@ -3,12 +3,15 @@ module github.com/yuyicai/kubei
 go 1.16
 
 require (
+	github.com/docker/distribution v2.7.1+incompatible
 	github.com/fatih/color v1.13.0
 	github.com/go-kratos/kratos v1.0.1
 	github.com/heroku/docker-registry-client v0.0.0-20211012143308-9463674c8930
 	github.com/lithammer/dedent v1.1.0
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/mitchellh/mapstructure v1.4.2
+	github.com/opencontainers/go-digest v1.0.0
+	github.com/opencontainers/image-spec v1.0.1
 	github.com/pkg/errors v0.9.1
 	github.com/pkg/sftp v1.13.4
 	github.com/spf13/cobra v1.2.1
@@ -6,6 +6,11 @@ const (
 	// ssh
 	DefaultSSHUser = "root"
 	DefaultSSHPort = "22"
+	// the commands are run as root by sudo if the ssh user isn't root
+	DefaultBecome = "sudo"
+
+	// preflight
+	DefaultPreflightMaxTimeSkew = 30 * time.Second
 
 	InstallTypeOffline       = "offline"
 	InstallTypeOnline        = "online"
@@ -14,16 +19,57 @@ const (
 	DefaultLocalSLBInterval  = 2 * time.Second
 	DefaultLocalSLBTimeout   = 6 * time.Minute
 
+	// os, the IDs in /etc/os-release
+	OSUbuntu    = "ubuntu"
+	OSDebian    = "debian"
+	OSCentOS    = "centos"
+	OSRHEL      = "rhel"
+	OSRocky     = "rocky"
+	OSOpenEuler = "openeuler"
+	OSKylin     = "kylin"
+	ArchAMD64   = "amd64"
+	ArchARM64   = "arm64"
+
 	// container engine
 	ContainerEngineTypeDocker     = "docker"
 	ContainerEngineTypeContainerd = "containerd"
 	ContainerEngineTypeCRIO       = "cri-o"
-	RegistryMirrors               = "https://dockerhub.mirrors.nwafu.edu.cn/"
+	DefaultDockerHubMirrors       = "https://dockerhub.mirrors.nwafu.edu.cn/,https://hub-mirror.c.163.com"
+	ContainerdCertsDir            = "/etc/containerd/certs.d"
+	ContainerdConfigFile          = "/etc/containerd/config.toml"
+	DockerCertsDir                = "/etc/docker/certs.d"
+	KubeletDockerConfigFile       = "/var/lib/kubelet/config.json"
+	RootDockerConfigFile          = "/root/.docker/config.json"
 	DefaultCGroupDriver           = "cgroupfs"
 	DefaultLogDriver              = "json-file"
 	DefaultLogOptsMaxSize         = "500m"
 	DockerDefaultStorageDriver    = "overlay2"
 
+	// the changes of the hosts made by kubei, they are rolled back by "kubei reset --restore-host"
+	JournalFile      = "/var/lib/kubei/journal"
+	JournalBackupDir = "/var/lib/kubei/backup"
+	HostsFile        = "/etc/hosts"
+	FstabFile        = "/etc/fstab"
+	K8sSysctlConf    = "/etc/sysctl.d/99-k8s-sysctl.conf"
+	DockerDaemonJSON = "/etc/docker/daemon.json"
+	KubeletHADropIn  = "/etc/systemd/system/kubelet.service.d/20-ha-service-manager.conf"
+
+	// how the commands are run on the nodes, see the node formats of --masters and --nodes
+	ExecutorSSH    = "ssh"
+	ExecutorLocal  = "local"
+	ExecutorDocker = "docker"
+
+	// the OS of the nodes with --dry-run, the commands are rendered for it
+	DefaultDryRunOS = "ubuntu 20.04 amd64"
+
+	// the http proxy of the nodes
+	ProxyAptConf = "/etc/apt/apt.conf.d/90kubei-proxy"
+	ProxyDropIn  = "kubei-http-proxy.conf"
+
+	// package repositories of the online installation, with the same layout as download.docker.com and packages.cloud.google.com
+	DefaultDockerRepo     = "https://mirrors.aliyun.com/docker-ce"
+	DefaultKubernetesRepo = "https://mirrors.aliyun.com/kubernetes"
+
 	// kubeadm
 	DefaultServiceSubnet        = "10.96.0.0/12"
 	DefaultPodNetworkCidr       = "10.244.0.0/16"
@@ -35,12 +81,43 @@ const (
 	DefaultWaitNodeTimeout      = 6 * time.Minute
 	DefaultCertNotAfterYear     = 10
 	DefaultCertNotAfterTime     = Year * DefaultCertNotAfterYear
+	DefaultCertKeyAlgorithm     = "RSA-2048"
+	DefaultControlPlaneInterval = 2 * time.Second
+	DefaultControlPlaneTimeout  = 5 * time.Minute
+	BackupPassphraseEnv         = "KUBEI_BACKUP_PASSPHRASE"
+
+	// kubeconfig
+	DefaultKubeconfigClientCertTTL = 24 * time.Hour
+
+	// offline package
+	OfflineManifestFile         = "kubei-manifest.json"
+	DefaultOfflineDockerVersion = "20.10.11"
+	DefaultOfflineCNIVersion    = "v0.8.7"
+	DefaultOfflineCrictlVersion = "v1.21.0"
+
+	// download
+	DefaultDownloadRegistry   = "registry.aliyuncs.com"
+	DefaultDownloadRepository = "kubebin/kube-files"
+	DefaultArch               = "amd64"
+
+	// offline package distribution
+	DistributionModeDirect    = "direct"
+	DistributionModeP2P       = "p2p"
+	DefaultDistributionPort   = 18088
+	DefaultDistributionFanout = 2
+
+	// local registry
+	DefaultLocalRegistryPort  = 5000
+	DefaultLocalRegistryImage = "registry:2.7.1"
+	LocalRegistryDataDir      = "/var/lib/kubei/registry"
+	LocalRegistryManifest     = "/etc/kubernetes/manifests/kubei-registry.yaml"
+	DefaultLocalRegistryWait  = 2 * time.Minute
 
 	// networking plugin
 	DefaulNetworkPlugin           = "flannel"
 	DefaultFlannelImageRepository = "quay.io/coreos"
 	DefaultFlannelImageName       = "flannel"
-	DefaultFlannelVersion         = "v0.11.0-amd64"
+	DefaultFlannelVersion         = "v0.14.0"
 	DefaultFlannelBackendType     = "vxlan"
 
 	// ha
@@ -61,6 +61,15 @@ func RunOnOtherMastersOneByOne(c *rundata.Cluster, tasks Tasks) error {
 	return nil
 }
 
+func RunOnMastersOneByOne(c *rundata.Cluster, tasks Tasks) error {
+	for _, node := range c.ClusterNodes.Masters {
+		if err := runOne(node, c, tasks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func RunOnFirstMaster(c *rundata.Cluster, tasks Tasks) error {
 	if len(c.ClusterNodes.Masters) == 0 {
 		return errors.New("not master")
@@ -0,0 +1,55 @@
+package operator
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/yuyicai/kubei/internal/rundata"
+	"github.com/yuyicai/kubei/pkg/ssh"
+	"github.com/yuyicai/kubei/pkg/ssh/sshtest"
+)
+
+func TestRunOnAllNodes(t *testing.T) {
+	c := rundata.NewCluster()
+	var servers []*sshtest.Server
+	for i := 0; i < 3; i++ {
+		s := sshtest.NewServer(t, "root", "secret")
+		client, err := ssh.Connect(s.Host(), s.Port(), "root", "secret", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { client.Close() })
+
+		node := &rundata.Node{Executor: client, HostInfo: rundata.HostInfo{Host: s.Host()}}
+		if i == 0 {
+			c.ClusterNodes.Masters = append(c.ClusterNodes.Masters, node)
+		} else {
+			c.ClusterNodes.Workers = append(c.ClusterNodes.Workers, node)
+		}
+		servers = append(servers, s)
+	}
+
+	task := func(node *rundata.Node, c *rundata.Cluster) error {
+		return node.Run("systemctl restart kubelet")
+	}
+	if err := RunOnAllNodes(c, task); err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range servers {
+		if scripts := s.Scripts(); len(scripts) != 1 || scripts[0] != "systemctl restart kubelet" {
+			t.Errorf("scripts of node %d = %q, want the task", i, scripts)
+		}
+	}
+
+	servers[2].Handle(`^systemctl restart kubelet$`, func(e *sshtest.Exec) int {
+		io.WriteString(e.Stderr, "Job for kubelet.service failed\n")
+		return 1
+	})
+	if err := RunOnAllNodes(c, task); err == nil || !strings.Contains(err.Error(), "Job for kubelet.service failed") {
+		t.Errorf("RunOnAllNodes() = %v, want the error of the failed node", err)
+	}
+	if err := RunOnMasters(c, task); err != nil {
+		t.Errorf("RunOnMasters() = %v, want nil", err)
+	}
+}
@@ -3,6 +3,7 @@ package options
 import (
 	flag "github.com/spf13/pflag"
 	"github.com/yuyicai/kubei/internal/constants"
+	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
 )
 
 const (
@@ -11,6 +12,8 @@ const (
 	Port                      = "port"
 	Password                  = "password"
 	ShortPassword             = "p"
+	Become                    = "become"
+	BecomePassword            = "become-password"
 	User                      = "user"
 	KubernetesVersion         = "kubernetes-version"
 	ContainerEngineVersion    = "container-engine-version"
@@ -25,9 +28,59 @@ const (
 	JumpServer                = "jump-server"
 	RemoveContainerEngine     = "remove-container-engine"
 	RemoveKubernetesComponent = "remove-kubernetes-component"
+	RestoreHost               = "restore-host"
 	OfflineFile               = "offline-file"
 	ShortOfflineFile          = "f"
 	CertNotAfterTime          = "cert-time"
+	CertificatesDir           = "cert-dir"
+	CertKeyAlgorithm          = "cert-key-algorithm"
+	APIServerCertSANs         = "apiserver-cert-extra-sans"
+	Backup                    = "backup"
+	BackupPassphraseFile      = "backup-passphrase-file"
+	Force                     = "force"
+	Kubeconfig                = "kubeconfig"
+	KubeconfigServer          = "kubeconfig-server"
+	KubeconfigContext         = "kubeconfig-context"
+	KubeconfigUseContext      = "kubeconfig-use-context"
+	KubeconfigClientName      = "kubeconfig-client-name"
+	KubeconfigClientGroups    = "kubeconfig-client-groups"
+	KubeconfigClientCertTTL   = "kubeconfig-client-cert-ttl"
+	Registry                  = "registry"
+	Repository                = "repository"
+	RegistryUsername          = "registry-username"
+	RegistryPassword          = "registry-password"
+	DockerConfig              = "docker-config"
+	Dest                      = "dest"
+	Arch                      = "arch"
+	Platform                  = "platform"
+	RegistryMirror            = "registry-mirror"
+	InsecureRegistry          = "insecure-registry"
+	RegistryAuth              = "registry-auth"
+	RegistryCA                = "registry-ca"
+	Images                    = "images"
+	DistributionMode          = "distribution-mode"
+	DistributionPort          = "distribution-port"
+	DistributionFanout        = "distribution-fanout"
+	LocalRegistry             = "local-registry"
+	LocalRegistryNode         = "local-registry-node"
+	LocalRegistryPort         = "local-registry-port"
+	IgnorePreflightErrors     = "ignore-preflight-errors"
+	DockerRepo                = "docker-repo"
+	DockerRepoGPGKey          = "docker-repo-gpg-key"
+	DockerRepoProxy           = "docker-repo-proxy"
+	KubernetesRepo            = "kubernetes-repo"
+	KubernetesRepoGPGKey      = "kubernetes-repo-gpg-key"
+	KubernetesRepoProxy       = "kubernetes-repo-proxy"
+	HTTPProxy                 = "http-proxy"
+	HTTPSProxy                = "https-proxy"
+	NoProxy                   = "no-proxy"
+	DryRun                    = "dry-run"
+	DryRunDir                 = "dry-run-dir"
+	DryRunOS                  = "dry-run-os"
+	BOMFile                   = "bom"
+	Archs                     = "archs"
+	Output                    = "output"
+	ShortOutput               = "o"
 	NetworkPlugin             = "network-plugin"
 	Online                    = "install-online"
 	Command                   = "command"
@@ -43,6 +96,11 @@ func AddResetFlags(flagSet *flag.FlagSet, options *Reset) {
 		&options.RemoveKubeComponent, RemoveKubernetesComponent, options.RemoveKubeComponent,
 		"If true, remove the kubernetes component from the nodes",
 	)
+
+	flagSet.BoolVar(
+		&options.RestoreHost, RestoreHost, options.RestoreHost,
+		"If true, restore the files of the nodes changed by kubei, e.g. /etc/hosts, /etc/fstab, the sysctl and the systemd drop-ins",
+	)
 }
 
 func AddContainerEngineConfigFlags(flagSet *flag.FlagSet, options *ContainerEngine) {
@@ -55,12 +113,12 @@ func AddContainerEngineConfigFlags(flagSet *flag.FlagSet, options *ContainerEngi
 func AddKubeClusterNodesConfigFlags(flagSet *flag.FlagSet, options *ClusterNodes) {
 	flagSet.StringSliceVarP(
 		&options.Masters, Masters, ShortMasters, options.Masters,
-		"The master nodes IP",
+		"The master nodes IP, local://<ip> runs on the local host, docker://<container>@<ip> runs in the container by docker exec",
 	)
 
 	flagSet.StringSliceVarP(
 		&options.Workers, Workers, ShortNodes, options.Workers,
-		"The worker nodes IP",
+		"The worker nodes IP, local://<ip> runs on the local host, docker://<container>@<ip> runs in the container by docker exec",
 	)
 }
 
@@ -84,6 +142,16 @@ func AddPublicUserInfoConfigFlags(flagSet *flag.FlagSet, options *PublicHostInfo
 		&options.Key, Key, ShortKey, options.Key,
 		"SSH key of the nodes.",
 	)
+
+	flagSet.StringVar(
+		&options.Become, Become, constants.DefaultBecome,
+		"How the commands are run as root if the SSH user isn't root, one of sudo, su or doas.",
+	)
+
+	flagSet.StringVar(
+		&options.BecomePassword, BecomePassword, options.BecomePassword,
+		"The password asked by --become, the SSH password is used if it is empty. It is the password of root for su.",
+	)
 }
 
 func AddKubeadmConfigFlags(flagSet *flag.FlagSet, options *Kubeadm) {
@@ -96,6 +164,11 @@ func AddKubeadmConfigFlags(flagSet *flag.FlagSet, options *Kubeadm) {
 		"Specify range of IP addresses for the pod network",
 	)
 
+	flagSet.StringSliceVar(
+		&options.APIServerCertSANs, APIServerCertSANs, options.APIServerCertSANs,
+		"Optional extra Subject Alternative Names (SANs) to use for the API Server serving certificate. Can be both IP addresses and DNS names.",
+	)
+
 	AddImageMetaFlags(flagSet, &options.ImageRepository)
 	AddControlPlaneEndpointFlags(flagSet, options)
 }
@@ -131,6 +204,198 @@ func AddCertNotAfterTimeFlags(flagSet *flag.FlagSet, year *int) {
 	)
 }
 
+func AddCertificatesDirFlags(flagSet *flag.FlagSet, dir *string) {
+	flagSet.StringVar(dir, CertificatesDir, *dir,
+		"Path to a local directory holding the CA certificates and keys, with the same layout as /etc/kubernetes/pki",
+	)
+}
+
+func AddCertKeyAlgorithmFlags(flagSet *flag.FlagSet, keyAlgorithm *string) {
+	flagSet.StringVar(keyAlgorithm, CertKeyAlgorithm, constants.DefaultCertKeyAlgorithm,
+		"The key algorithm of the certificates and the service account key, supported: RSA-2048, RSA-4096, ECDSA-P256",
+	)
+}
+
+func AddBackupFlags(flagSet *flag.FlagSet, options *Backup) {
+	flagSet.StringVar(&options.Path, Backup, options.Path,
+		"Path to the local backup of the CAs, the service account key pair and admin.conf, it is an encrypted archive if it ends with .tar.gz, otherwise a directory",
+	)
+	flagSet.StringVar(&options.PassphraseFile, BackupPassphraseFile, options.PassphraseFile,
+		"Path to the file holding the passphrase of the encrypted backup archive, the KUBEI_BACKUP_PASSPHRASE environment variable is used if it is not set",
+	)
+}
+
+func AddForceFlags(flagSet *flag.FlagSet, force *bool) {
+	flagSet.BoolVar(force, Force, *force,
+		"Overwrite the existing files on the masters",
+	)
+}
+
+func AddKubeconfigFlags(flagSet *flag.FlagSet, options *Kubeconfig) {
+	flagSet.StringVar(&options.Path, Kubeconfig, options.Path,
+		"Path to the local kubeconfig file to merge the cluster into (default \"$HOME/.kube/config\")",
+	)
+	flagSet.StringVar(&options.Server, KubeconfigServer, options.Server,
+		"Rewrite the server of the kubeconfig to a reachable address or tunnel, e.g. https://127.0.0.1:16443",
+	)
+	flagSet.StringVar(&options.Context, KubeconfigContext, options.Context,
+		"The name of the context in the local kubeconfig file (default is the cluster name)",
+	)
+	flagSet.BoolVar(&options.UseContext, KubeconfigUseContext, options.UseContext,
+		"Switch the current context of the local kubeconfig file to the cluster, it is only switched if there is no current context by default",
+	)
+	flagSet.StringVar(&options.ClientName, KubeconfigClientName, options.ClientName,
+		"Use a short-lived client certificate signed by the cluster CA with this common name instead of the admin identity",
+	)
+	flagSet.StringSliceVar(&options.ClientGroups, KubeconfigClientGroups, options.ClientGroups,
+		"The groups (organizations) of the short-lived client certificate, no group by default, "+
+			"e.g. "+kubeadmconstants.SystemPrivilegedGroup+" grants the cluster admin",
+	)
+	flagSet.DurationVar(&options.ClientCertTTL, KubeconfigClientCertTTL, constants.DefaultKubeconfigClientCertTTL,
+		"The validity duration of the short-lived client certificate",
+	)
+}
+
+func AddDownloadFlags(flagSet *flag.FlagSet, options *Download) {
+	flagSet.StringVar(&options.Registry, Registry, constants.DefaultDownloadRegistry,
+		"The registry to pull the offline files from, e.g. registry.example.com or http://127.0.0.1:5000",
+	)
+	flagSet.StringVar(&options.Repository, Repository, constants.DefaultDownloadRepository,
+		"The repository of the offline files in the registry, the tag is the Kubernetes version",
+	)
+	AddRegistryAuthFlags(flagSet, options)
+	flagSet.StringVar(&options.Dest, Dest, options.Dest,
+		"The destination directory (default \"$HOME/.kubei/<kubernetes-version>\")",
+	)
+	flagSet.StringVar(&options.Arch, Arch, constants.DefaultArch,
+		"The architecture selected from the multi-architecture images, e.g. amd64, arm64",
+	)
+	flagSet.StringVar(&options.Platform, Platform, options.Platform,
+		"The platform selected from the multi-architecture images in the form of os/arch[/variant], e.g. linux/arm64 or linux/arm/v7, it overrides --arch",
+	)
+	flagSet.BoolVar(&options.Images, Images, options.Images,
+		"Download the images needed by the Kubernetes version instead of the offline files",
+	)
+}
+
+func AddRegistryAuthFlags(flagSet *flag.FlagSet, options *Download) {
+	flagSet.StringVar(&options.Username, RegistryUsername, options.Username,
+		"The username of the registry",
+	)
+	flagSet.StringVar(&options.Password, RegistryPassword, options.Password,
+		"The password of the registry",
+	)
+	flagSet.StringVar(&options.DockerConfig, DockerConfig, options.DockerConfig,
+		"Path to the docker config.json, the credentials of the registry in it are used if --registry-username is not set (default \"$HOME/.docker/config.json\")",
+	)
+}
+
+func AddRegistriesFlags(flagSet *flag.FlagSet, options *Registries) {
+	flagSet.Var(newMirrorsValue(&options.Mirrors), RegistryMirror,
+		"The mirrors of a registry in the form of <registry>=<mirror>[,<mirror>], e.g. docker.io=https://mirror.example.com, "+
+			"it can be set repeatedly, no mirror is used if the mirrors are empty, e.g. docker.io= "+
+			"(default \"docker.io="+constants.DefaultDockerHubMirrors+"\")",
+	)
+	flagSet.StringSliceVar(&options.Insecure, InsecureRegistry, options.Insecure,
+		"The registries accessed by http or by https without verifying the certificate, e.g. registry.example.com:5000",
+	)
+	flagSet.StringToStringVar(&options.Auths, RegistryAuth, options.Auths,
+		"The local paths to the credentials of the registries used by kubelet to pull the images in the form of <registry>=<path>, "+
+			"the file contains <username>:<password>",
+	)
+	flagSet.StringToStringVar(&options.CAs, RegistryCA, options.CAs,
+		"The local paths to the CA certificates of the registries in the form of <registry>=<path>, they are copied to every node",
+	)
+}
+
+func AddDistributionFlags(flagSet *flag.FlagSet, options *Distribution) {
+	flagSet.StringVar(&options.Mode, DistributionMode, constants.DistributionModeDirect,
+		"How to send the offline package to the nodes, \"direct\" uploads it to every node, "+
+			"\"p2p\" uploads it once to master0 and the nodes copy it from each other by http",
+	)
+	flagSet.IntVar(&options.Port, DistributionPort, constants.DefaultDistributionPort,
+		"The http port of the nodes serving the offline package in p2p distribution mode",
+	)
+	flagSet.IntVar(&options.Fanout, DistributionFanout, constants.DefaultDistributionFanout,
+		"The max number of nodes a node serves the offline package to at a time in p2p distribution mode",
+	)
+}
+
+func AddLocalRegistryFlags(flagSet *flag.FlagSet, options *LocalRegistry) {
+	flagSet.BoolVar(&options.Enable, LocalRegistry, options.Enable,
+		"If true, run a registry on a master and push the offline images to it, the other nodes pull the images from it instead of loading them",
+	)
+	flagSet.StringVar(&options.Node, LocalRegistryNode, options.Node,
+		"The master running the local registry, it is initialized first (default the first master of --masters)",
+	)
+	flagSet.IntVar(&options.Port, LocalRegistryPort, constants.DefaultLocalRegistryPort,
+		"The http port of the local registry",
+	)
+}
+
+func AddPackageReposFlags(flagSet *flag.FlagSet, options *PackageRepos) {
+	flagSet.StringVar(&options.Docker.BaseURL, DockerRepo, constants.DefaultDockerRepo,
+		"The docker-ce package repository of the online installation, with the same layout as https://download.docker.com",
+	)
+	flagSet.StringVar(&options.Docker.GPGKey, DockerRepoGPGKey, options.Docker.GPGKey,
+		"The URL of the GPG key of the docker-ce packages (default the key under --docker-repo)",
+	)
+	flagSet.StringVar(&options.Docker.Proxy, DockerRepoProxy, options.Docker.Proxy,
+		"The http proxy used to access the docker-ce package repository",
+	)
+	flagSet.StringVar(&options.Kubernetes.BaseURL, KubernetesRepo, constants.DefaultKubernetesRepo,
+		"The kubernetes package repository of the online installation, with the same layout as https://packages.cloud.google.com",
+	)
+	flagSet.StringVar(&options.Kubernetes.GPGKey, KubernetesRepoGPGKey, options.Kubernetes.GPGKey,
+		"The URL of the GPG key of the kubernetes packages (default the key under --kubernetes-repo)",
+	)
+	flagSet.StringVar(&options.Kubernetes.Proxy, KubernetesRepoProxy, options.Kubernetes.Proxy,
+		"The http proxy used to access the kubernetes package repository",
+	)
+}
+
+func AddDryRunFlags(flagSet *flag.FlagSet, options *DryRun) {
+	flagSet.BoolVar(&options.Enable, DryRun, options.Enable,
+		"If true, print the commands of each node instead of running them, the nodes are not connected",
+	)
+	flagSet.StringVar(&options.Dir, DryRunDir, options.Dir,
+		"Write the commands of each node to <dir>/<node>/ instead of printing them, with --dry-run",
+	)
+	flagSet.StringVar(&options.OS, DryRunOS, options.OS,
+		"The OS of the nodes the commands are rendered for with --dry-run, in the form of \"<id> <version> <arch>\" (default \"ubuntu 20.04 amd64\")",
+	)
+}
+
+func AddProxyFlags(flagSet *flag.FlagSet, options *Proxy) {
+	flagSet.StringVar(&options.HTTPProxy, HTTPProxy, options.HTTPProxy,
+		"The http proxy of the nodes, it is used by apt, yum, docker, containerd and kubelet",
+	)
+	flagSet.StringVar(&options.HTTPSProxy, HTTPSProxy, options.HTTPSProxy,
+		"The https proxy of the nodes, it is used by apt, yum, docker, containerd and kubelet",
+	)
+	flagSet.StringSliceVar(&options.NoProxy, NoProxy, options.NoProxy,
+		"The extra hosts, domains and CIDRs accessed without the proxy, the nodes, the control plane endpoint, the pod and service CIDRs are always added",
+	)
+}
+
+func AddIgnorePreflightErrorsFlags(flagSet *flag.FlagSet, ignorePreflightErrors *[]string) {
+	flagSet.StringSliceVar(ignorePreflightErrors, IgnorePreflightErrors, *ignorePreflightErrors,
+		"A list of checks whose errors will be shown as warnings. Example: 'Port-10250,NumCPU'. Value 'all' ignores errors from all checks.",
+	)
+}
+
+func AddOfflineBuildFlags(flagSet *flag.FlagSet, options *OfflineBuild) {
+	flagSet.StringVar(&options.BOMFile, BOMFile, options.BOMFile,
+		"Path to the bill of materials (yaml or json) of the offline package, the default one of --kubernetes-version is used if it is not set",
+	)
+	flagSet.StringVarP(&options.Output, Output, ShortOutput, options.Output,
+		"Path to the offline package (default \"kubei-offline-<kubernetes-version>.tar.gz\")",
+	)
+	flagSet.StringSliceVar(&options.Archs, Archs, []string{constants.DefaultArch},
+		"The architectures of the offline package, e.g. amd64,arm64, it is used if the BOM file doesn't set the architectures",
+	)
+}
+
 func AddNetworkPluginFlags(flagSet *flag.FlagSet, networkType *string) {
 	flagSet.StringVar(networkType, NetworkPlugin, constants.DefaulNetworkPlugin,
 		"network plugin",
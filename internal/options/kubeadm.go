@@ -13,6 +13,10 @@ func (c *Kubeadm) ApplyTo(data *rundata.Kubeadm) {
 		data.ImageRepository = c.ImageRepository
 	}
 
+	if len(c.APIServerCertSANs) > 0 {
+		data.APIServer.CertSANs = c.APIServerCertSANs
+	}
+
 	c.Networking.ApplyTo(data)
 }
 
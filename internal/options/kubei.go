@@ -28,6 +28,12 @@ func (c *ClusterNodes) ApplyTo(data *rundata.ClusterNodes) {
 		if v.HostInfo.Key == "" && c.PublicHostInfo.Key != "" {
 			v.HostInfo.Key = c.PublicHostInfo.Key
 		}
+		if v.HostInfo.Become == "" && c.PublicHostInfo.Become != "" {
+			v.HostInfo.Become = c.PublicHostInfo.Become
+		}
+		if v.HostInfo.BecomePassword == "" && c.PublicHostInfo.BecomePassword != "" {
+			v.HostInfo.BecomePassword = c.PublicHostInfo.BecomePassword
+		}
 		if v.Name == "" {
 			v.Name = v.HostInfo.Host
 		}
@@ -38,6 +44,35 @@ func (c *ContainerEngine) ApplyTo(data *rundata.ContainerEngine) {
 	if c.Version != "" {
 		data.Docker.Version = strings.Replace(c.Version, "v", "", -1)
 	}
+	c.Registries.ApplyTo(&data.Registries)
+}
+
+func (r *Registries) ApplyTo(data *rundata.Registries) {
+	if r.Mirrors != nil {
+		data.Mirrors = map[string][]string{}
+		for registry, mirrors := range r.Mirrors {
+			registry = rundata.NormalizeRegistry(registry)
+			data.Mirrors[registry] = append(data.Mirrors[registry], mirrors...)
+		}
+	}
+
+	for _, registry := range r.Insecure {
+		data.Insecure = append(data.Insecure, rundata.NormalizeRegistry(registry))
+	}
+
+	for registry, file := range r.Auths {
+		if data.AuthFiles == nil {
+			data.AuthFiles = map[string]string{}
+		}
+		data.AuthFiles[rundata.NormalizeRegistry(registry)] = file
+	}
+
+	for registry, ca := range r.CAs {
+		if data.CAs == nil {
+			data.CAs = map[string]string{}
+		}
+		data.CAs[rundata.NormalizeRegistry(registry)] = ca
+	}
 }
 
 func (k *Kubernetes) ApplyTo(data *rundata.Kubernetes) {
@@ -54,6 +89,81 @@ func (r *Reset) ApplyTo(data *rundata.Reset) {
 	if r.RemoveContainerEngine {
 		data.RemoveContainerEngine = r.RemoveContainerEngine
 	}
+
+	if r.RestoreHost {
+		data.RestoreHost = r.RestoreHost
+	}
+}
+
+func (b *Backup) ApplyTo(data *rundata.Backup) {
+	data.Path = b.Path
+	data.PassphraseFile = b.PassphraseFile
+	data.Force = b.Force
+}
+
+func (k *Kubeconfig) ApplyTo(data *rundata.Kubeconfig) {
+	data.Path = k.Path
+	data.Server = k.Server
+	data.Context = k.Context
+	data.UseContext = k.UseContext
+	data.ClientName = k.ClientName
+	data.ClientGroups = k.ClientGroups
+	data.ClientCertTTL = k.ClientCertTTL
+}
+
+func (d *Download) ApplyTo(data *rundata.Download) {
+	data.Registry = d.Registry
+	data.Repository = d.Repository
+	data.Username = d.Username
+	data.Password = d.Password
+	data.DockerConfig = d.DockerConfig
+	data.Dest = d.Dest
+	data.Arch = d.Arch
+	data.Platform = d.Platform
+	data.Images = d.Images
+}
+
+func (d *Distribution) ApplyTo(data *rundata.Distribution) {
+	data.Mode = d.Mode
+	data.Port = d.Port
+	data.Fanout = d.Fanout
+}
+
+func (l *LocalRegistry) ApplyTo(data *rundata.LocalRegistry) {
+	data.Enable = l.Enable
+	data.Node = l.Node
+	data.Port = l.Port
+}
+
+func (r *PackageRepos) ApplyTo(data *rundata.PackageRepos) {
+	r.Docker.ApplyTo(&data.Docker)
+	r.Kubernetes.ApplyTo(&data.Kubernetes)
+}
+
+func (r *PackageRepo) ApplyTo(data *rundata.PackageRepo) {
+	data.BaseURL = strings.TrimSuffix(r.BaseURL, "/")
+	data.GPGKey = r.GPGKey
+	data.Proxy = r.Proxy
+}
+
+func (d *DryRun) ApplyTo(data *rundata.DryRun) {
+	data.Enable = d.Enable
+	data.Dir = d.Dir
+	if d.OS != "" {
+		data.OS = rundata.ParseOS(d.OS)
+	}
+}
+
+func (p *Proxy) ApplyTo(data *rundata.Proxy) {
+	data.HTTPProxy = p.HTTPProxy
+	data.HTTPSProxy = p.HTTPSProxy
+	data.NoProxy = p.NoProxy
+}
+
+func (o *OfflineBuild) ApplyTo(data *rundata.OfflineBuild) {
+	data.BOMFile = o.BOMFile
+	data.Output = o.Output
+	data.Archs = o.Archs
 }
 
 func (k *Kubei) ApplyTo(data *rundata.Kubei) {
@@ -62,6 +172,15 @@ func (k *Kubei) ApplyTo(data *rundata.Kubei) {
 	k.ClusterNodes.ApplyTo(&data.ClusterNodes)
 	k.Reset.ApplyTo(&data.Reset)
 	k.Kubernetes.ApplyTo(&data.Kubernetes)
+	k.Backup.ApplyTo(&data.Backup)
+	k.Kubeconfig.ApplyTo(&data.Kubeconfig)
+	k.OfflineBuild.ApplyTo(&data.OfflineBuild)
+	k.Distribution.ApplyTo(&data.Distribution)
+	k.Download.ApplyTo(&data.Download)
+	k.LocalRegistry.ApplyTo(&data.LocalRegistry)
+	k.PackageRepos.ApplyTo(&data.PackageRepos)
+	k.Proxy.ApplyTo(&data.Proxy)
+	k.DryRun.ApplyTo(&data.DryRun)
 
 	if len(k.JumpServer) > 0 {
 		if err := mapstructure.Decode(k.JumpServer, &data.JumpServer.HostInfo); err != nil {
@@ -79,6 +198,9 @@ func (k *Kubei) ApplyTo(data *rundata.Kubei) {
 
 	data.NetworkPlugins.Type = k.NetworkType
 	data.CertNotAfterTime = k.CertNotAfterTime
+	data.CertificatesDir = k.CertificatesDir
+	data.CertKeyAlgorithm = k.CertKeyAlgorithm
+	data.IgnorePreflightErrors = k.IgnorePreflightErrors
 }
 
 func setNodesHost(nodes *[]*rundata.Node, optionsNodes []string) {
@@ -87,7 +209,7 @@ func setNodesHost(nodes *[]*rundata.Node, optionsNodes []string) {
 			v = strings.Replace(v, " ", "", -1)
 			vv := strings.Split(v, ";")
 			node := &rundata.Node{}
-			node.HostInfo.Host = vv[0]
+			node.HostInfo.Host, node.HostInfo.Executor, node.HostInfo.Container = parseNode(vv[0])
 			if len(vv) > 1 {
 				//TODO set nodes ssh host info (host,user,port,password,key) with --masters and --workers
 			}
@@ -96,6 +218,22 @@ func setNodesHost(nodes *[]*rundata.Node, optionsNodes []string) {
 	}
 }
 
+// parseNode parses a node of --masters and --nodes: <ip> is run by ssh, local://<ip> is the local host,
+// and docker://<container>@<ip> is run by docker exec in the container.
+func parseNode(s string) (host, executor, container string) {
+	switch {
+	case strings.HasPrefix(s, "local://"):
+		return strings.TrimPrefix(s, "local://"), constants.ExecutorLocal, ""
+	case strings.HasPrefix(s, "docker://"):
+		s = strings.TrimPrefix(s, "docker://")
+		if i := strings.LastIndex(s, "@"); i >= 0 {
+			return s[i+1:], constants.ExecutorDocker, s[:i]
+		}
+		return s, constants.ExecutorDocker, ""
+	}
+	return s, constants.ExecutorSSH, ""
+}
+
 func setNodesInstallType(nodes []*rundata.Node, installType string) {
 	for _, node := range nodes {
 		node.InstallType = installType
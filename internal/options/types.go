@@ -1,9 +1,12 @@
 package options
 
+import "time"
+
 type Kubeadm struct {
 	//Version              string
 	ControlPlaneEndpoint string
 	ImageRepository      string
+	APIServerCertSANs    []string
 	Networking           Networking
 }
 
@@ -16,7 +19,20 @@ type Kubei struct {
 	OfflineFile      string
 	Online           bool
 	CertNotAfterTime int
+	CertificatesDir  string
+	CertKeyAlgorithm string
+	Backup           Backup
+	Kubeconfig       Kubeconfig
+	OfflineBuild     OfflineBuild
+	Distribution     Distribution
+	Download         Download
+	LocalRegistry    LocalRegistry
+	PackageRepos     PackageRepos
+	Proxy            Proxy
+	DryRun           DryRun
 	NetworkType      string
+	// IgnorePreflightErrors are the names of the preflight checks whose errors are shown as warnings
+	IgnorePreflightErrors []string
 }
 
 type Kubernetes struct {
@@ -24,10 +40,12 @@ type Kubernetes struct {
 }
 
 type PublicHostInfo struct {
-	Key      string
-	User     string
-	Password string
-	Port     string
+	Key            string
+	User           string
+	Password       string
+	Port           string
+	Become         string
+	BecomePassword string
 }
 
 type ClusterNodes struct {
@@ -38,7 +56,16 @@ type ClusterNodes struct {
 }
 
 type ContainerEngine struct {
-	Version string
+	Version    string
+	Registries Registries
+}
+
+type Registries struct {
+	Mirrors  map[string][]string
+	Insecure []string
+	// Auths are the local paths to the files of <username>:<password> of the registries
+	Auths map[string]string
+	CAs   map[string]string
 }
 
 type JumpServerHostInfo struct {
@@ -49,6 +76,76 @@ type JumpServerHostInfo struct {
 type Reset struct {
 	RemoveContainerEngine bool
 	RemoveKubeComponent   bool
+	RestoreHost           bool
+}
+
+type Backup struct {
+	Path           string
+	PassphraseFile string
+	Force          bool
+}
+
+type Kubeconfig struct {
+	Path          string
+	Server        string
+	Context       string
+	UseContext    bool
+	ClientName    string
+	ClientGroups  []string
+	ClientCertTTL time.Duration
+}
+
+type Download struct {
+	Registry     string
+	Repository   string
+	Username     string
+	Password     string
+	DockerConfig string
+	Dest         string
+	Arch         string
+	Platform     string
+	Images       bool
+}
+
+type Distribution struct {
+	Mode   string
+	Port   int
+	Fanout int
+}
+
+type LocalRegistry struct {
+	Enable bool
+	Node   string
+	Port   int
+}
+
+type PackageRepos struct {
+	Docker     PackageRepo
+	Kubernetes PackageRepo
+}
+
+type PackageRepo struct {
+	BaseURL string
+	GPGKey  string
+	Proxy   string
+}
+
+type DryRun struct {
+	Enable bool
+	Dir    string
+	OS     string
+}
+
+type Proxy struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    []string
+}
+
+type OfflineBuild struct {
+	BOMFile string
+	Output  string
+	Archs   []string
 }
 
 type Networking struct {
@@ -0,0 +1,59 @@
+package options
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// mirrorsValue is the value of --registry-mirror in the form of <registry>=<mirror>[,<mirror>],
+// the mirrors of the same registry set by the flag repeatedly are appended,
+// and no mirror is used for the registry if the mirrors are empty, e.g. docker.io=
+type mirrorsValue struct {
+	value *map[string][]string
+}
+
+func newMirrorsValue(p *map[string][]string) *mirrorsValue {
+	return &mirrorsValue{value: p}
+}
+
+func (v *mirrorsValue) Set(s string) error {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+		return fmt.Errorf("%q must be <registry>=<mirror>[,<mirror>]", s)
+	}
+
+	if *v.value == nil {
+		*v.value = map[string][]string{}
+	}
+	registry := strings.TrimSpace(kv[0])
+	mirrors := (*v.value)[registry]
+	if mirrors == nil {
+		mirrors = []string{}
+	}
+	for _, mirror := range strings.Split(kv[1], ",") {
+		if mirror = strings.TrimSpace(mirror); mirror != "" {
+			mirrors = append(mirrors, mirror)
+		}
+	}
+	(*v.value)[registry] = mirrors
+	return nil
+}
+
+func (v *mirrorsValue) Type() string {
+	return "stringArray"
+}
+
+func (v *mirrorsValue) String() string {
+	// the default mirrors are set by rundata, they are in the usage of the flag
+	if v.value == nil || *v.value == nil {
+		return ""
+	}
+
+	var s []string
+	for registry, mirrors := range *v.value {
+		s = append(s, registry+"="+strings.Join(mirrors, ","))
+	}
+	sort.Strings(s)
+	return "[" + strings.Join(s, " ") + "]"
+}
@@ -0,0 +1,285 @@
+package cert
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
+
+	"github.com/yuyicai/kubei/internal/constants"
+	"github.com/yuyicai/kubei/internal/operator"
+	kubeadmphases "github.com/yuyicai/kubei/internal/phases/kubeadm"
+	"github.com/yuyicai/kubei/internal/rundata"
+	"github.com/yuyicai/kubei/pkg/archive"
+	"github.com/yuyicai/kubei/pkg/pki"
+)
+
+// backupFile is a file of the backup, the name is the path relative to /etc/kubernetes/pki,
+// so that a backup dir has the same layout as --cert-dir.
+type backupFile struct {
+	name string
+	// optional is true for the key of a CA, which is absent in external CA mode
+	optional bool
+	mode     os.FileMode
+}
+
+func getBackupFiles() []backupFile {
+	var files []backupFile
+	for _, ca := range rundata.GetDefaultCAList() {
+		files = append(files,
+			backupFile{name: ca.BaseName + ".crt", mode: 0644},
+			backupFile{name: ca.BaseName + ".key", mode: 0600, optional: true},
+		)
+	}
+
+	return append(files,
+		backupFile{name: kubeadmconstants.ServiceAccountPrivateKeyName, mode: 0600},
+		backupFile{name: kubeadmconstants.ServiceAccountPublicKeyName, mode: 0644},
+		backupFile{name: kubeadmconstants.AdminKubeConfigFileName, mode: 0600},
+	)
+}
+
+// remotePath returns the path of the backup file on the masters.
+func (f backupFile) remotePath() string {
+	if f.name == kubeadmconstants.AdminKubeConfigFileName {
+		return path.Join(kubeadmconstants.KubernetesDir, f.name)
+	}
+	return path.Join(kubeadmconstants.KubernetesDir, kubeadmconstants.DefaultCertificateDir, f.name)
+}
+
+// BackupCert fetches the CAs, the service account key pair and admin.conf from master0,
+// and writes them to a local dir or an encrypted archive.
+func BackupCert(c *rundata.Cluster) error {
+	color.HiBlue("Backing up certificates of kubernetes and etcd 📘")
+
+	files := map[string][]byte{}
+	if err := operator.RunOnFirstMaster(c, func(node *rundata.Node, c *rundata.Cluster) error {
+		for _, f := range getBackupFiles() {
+			if err := node.Run(fmt.Sprintf("test -f %s", f.remotePath())); err != nil {
+				if f.optional {
+					klog.V(2).Infof("[%s] [backup] %s not found, skip it", node.HostInfo.Host, f.remotePath())
+					continue
+				}
+				return fmt.Errorf("[%s] [backup] %s not found: %v", node.HostInfo.Host, f.remotePath(), err)
+			}
+
+			klog.V(2).Infof("[%s] [backup] Fetching %s", node.HostInfo.Host, f.remotePath())
+			content, err := node.RunOut(fmt.Sprintf("cat %s", f.remotePath()))
+			if err != nil {
+				return fmt.Errorf("[%s] [backup] Failed to fetch %s: %v", node.HostInfo.Host, f.remotePath(), err)
+			}
+			files[f.name] = content
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// the fetches are only recorded, there is nothing to back up
+	if c.DryRun.Enable {
+		klog.Infof("[backup] Skip writing backup to %s with --dry-run", c.Backup.Path)
+		return nil
+	}
+
+	if err := validateBackup(files); err != nil {
+		return err
+	}
+
+	if err := writeBackup(c.Backup, files); err != nil {
+		return errors.Wrapf(err, "[backup] Failed to write backup to %s", c.Backup.Path)
+	}
+
+	fmt.Printf("[backup] back up certificates to %s: %s\n", c.Backup.Path, color.HiGreenString("done✅️"))
+	return nil
+}
+
+// RestoreCert sends the CAs, the service account key pair and admin.conf of the backup to the masters.
+// The files existing on the masters are not overwritten unless they are the same as the backup or force is set.
+func RestoreCert(c *rundata.Cluster) error {
+	color.HiBlue("Restoring certificates of kubernetes and etcd 📘")
+
+	files, err := readBackup(c.Backup)
+	if err != nil {
+		return errors.Wrapf(err, "[restore] Failed to read backup from %s", c.Backup.Path)
+	}
+
+	if err := validateBackup(files); err != nil {
+		return err
+	}
+
+	return operator.RunOnMasters(c, func(node *rundata.Node, c *rundata.Cluster) error {
+		if !c.Backup.Force {
+			for _, f := range getBackupFiles() {
+				content, ok := files[f.name]
+				if !ok {
+					continue
+				}
+				existing, err := node.RunOut(fmt.Sprintf("cat %s 2>/dev/null || true", f.remotePath()))
+				if err != nil {
+					return fmt.Errorf("[%s] [restore] Failed to read %s: %v", node.HostInfo.Host, f.remotePath(), err)
+				}
+				if len(existing) > 0 && !bytes.Equal(existing, content) {
+					return fmt.Errorf("[%s] [restore] %s already exists and differs from the backup, use --force to overwrite it",
+						node.HostInfo.Host, f.remotePath())
+				}
+			}
+		}
+
+		for _, f := range getBackupFiles() {
+			content, ok := files[f.name]
+			if !ok {
+				continue
+			}
+			klog.V(2).Infof("[%s] [restore] Sending %s", node.HostInfo.Host, f.remotePath())
+			if err := node.Run(fmt.Sprintf("mkdir -p %s && echo %s | base64 -d > %s && chmod %o %s",
+				path.Dir(f.remotePath()), base64.StdEncoding.EncodeToString(content), f.remotePath(), f.mode, f.remotePath())); err != nil {
+				return fmt.Errorf("[%s] [restore] Failed to send %s: %v", node.HostInfo.Host, f.remotePath(), err)
+			}
+		}
+
+		if err := kubeadmphases.CopyAdminConfig(node); err != nil {
+			return err
+		}
+
+		fmt.Printf("[%s] [restore] restore certificates: %s\n", node.HostInfo.Host, color.HiGreenString("done✅️"))
+		return nil
+	})
+}
+
+// validateBackup checks that the certificates and keys of the backup can be parsed and match each other.
+func validateBackup(files map[string][]byte) error {
+	for _, f := range getBackupFiles() {
+		if _, ok := files[f.name]; !ok && !f.optional {
+			return errors.Errorf("[backup] %s is missing in the backup", f.name)
+		}
+	}
+
+	for _, ca := range rundata.GetDefaultCAList() {
+		cert, err := pki.ParseCertPEM(files[ca.BaseName+".crt"])
+		if err != nil {
+			return errors.Wrapf(err, "[backup] invalid %q CA certificate", ca.Name)
+		}
+
+		keyPEM, ok := files[ca.BaseName+".key"]
+		if !ok {
+			continue
+		}
+		key, err := pki.ParsePrivateKeyPEM(keyPEM)
+		if err != nil {
+			return errors.Wrapf(err, "[backup] invalid %q CA key", ca.Name)
+		}
+		if err := pki.ValidateKey(cert, key); err != nil {
+			return errors.Wrapf(err, "[backup] invalid %q CA key", ca.Name)
+		}
+	}
+
+	if _, err := pki.ParsePrivateKeyPEM(files[kubeadmconstants.ServiceAccountPrivateKeyName]); err != nil {
+		return errors.Wrap(err, "[backup] invalid service account key")
+	}
+
+	if _, err := clientcmd.Load(files[kubeadmconstants.AdminKubeConfigFileName]); err != nil {
+		return errors.Wrapf(err, "[backup] invalid %s", kubeadmconstants.AdminKubeConfigFileName)
+	}
+	return nil
+}
+
+// isBackupArchive returns true if the backup is an encrypted archive rather than a dir.
+func isBackupArchive(backupPath string) bool {
+	return strings.HasSuffix(backupPath, ".tar.gz")
+}
+
+func writeBackup(b rundata.Backup, files map[string][]byte) error {
+	if isBackupArchive(b.Path) {
+		passphrase, err := getBackupPassphrase(b)
+		if err != nil {
+			return err
+		}
+
+		data, err := archive.TarGz(files)
+		if err != nil {
+			return err
+		}
+
+		encrypted, err := archive.Encrypt(data, passphrase)
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(b.Path), 0700); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(b.Path, encrypted, 0600)
+	}
+
+	for name, content := range files {
+		file := filepath.Join(b.Path, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(file, content, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readBackup(b rundata.Backup) (map[string][]byte, error) {
+	if isBackupArchive(b.Path) {
+		passphrase, err := getBackupPassphrase(b)
+		if err != nil {
+			return nil, err
+		}
+
+		encrypted, err := ioutil.ReadFile(b.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := archive.Decrypt(encrypted, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return archive.UnTarGz(data)
+	}
+
+	files := map[string][]byte{}
+	for _, f := range getBackupFiles() {
+		content, err := ioutil.ReadFile(filepath.Join(b.Path, filepath.FromSlash(f.name)))
+		if os.IsNotExist(err) && f.optional {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files[f.name] = content
+	}
+	return files, nil
+}
+
+// getBackupPassphrase reads the passphrase from the passphrase file, or the environment variable if the file is not set.
+func getBackupPassphrase(b rundata.Backup) (string, error) {
+	if b.PassphraseFile != "" {
+		passphrase, err := ioutil.ReadFile(b.PassphraseFile)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to read the passphrase file")
+		}
+		return strings.TrimRight(string(passphrase), "\r\n"), nil
+	}
+
+	if passphrase := os.Getenv(constants.BackupPassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	return "", errors.Errorf("the passphrase of the encrypted backup archive is required, set it with --backup-passphrase-file or the %s environment variable",
+		constants.BackupPassphraseEnv)
+}
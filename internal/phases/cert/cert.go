@@ -2,8 +2,8 @@ package cert
 
 import (
 	"crypto"
-	"crypto/x509"
 	"fmt"
+	"path/filepath"
 	"time"
 
 	"github.com/fatih/color"
@@ -11,7 +11,6 @@ import (
 	"k8s.io/klog"
 	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
 	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
-	kubeadmpkiutil "k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
 
 	"github.com/yuyicai/kubei/internal/constants"
 	"github.com/yuyicai/kubei/internal/operator"
@@ -27,39 +26,80 @@ func CreateCert(c *rundata.Cluster) error {
 
 	certNotAfterTime := constants.Year * time.Duration(c.CertNotAfterTime)
 
+	if c.CertificatesDir != "" {
+		var err error
+		klog.V(2).Infof("[cert] Loading CA from %s", c.CertificatesDir)
+		if certTree, err = loadUserCA(c.CertificatesDir, certNotAfterTime); err != nil {
+			return err
+		}
+	}
+
+	// pending is true if any cert is waiting to be signed by an external CA
+	pending := false
+	createKubeConfig := func(node *rundata.Node, c *rundata.Cluster) error {
+		if c.CertificatesDir != "" {
+			p, err := signByExternalCA(node, &c.Kubeadm.InitConfiguration, c.CertificatesDir)
+			if err != nil {
+				return err
+			}
+			if p {
+				c.Mutex.Lock()
+				pending = true
+				c.Mutex.Unlock()
+				return nil
+			}
+		}
+
+		return node.CertificateTree.CreateKubeConfig(&c.Kubeadm.InitConfiguration)
+	}
+
 	if err := operator.RunOnFirstMaster(c, func(node *rundata.Node, c *rundata.Cluster) error {
 		klog.V(2).Infof("[%s] [cert] Creating certificate", node.HostInfo.Host)
 		klog.V(3).Infof("[%s] [cert] The cert not after time is %v", node.HostInfo.Host, certNotAfterTime)
 		c.Kubeadm.NodeRegistration.Name = node.Name
-		if err := CreatePKIAssets(node, &c.Kubeadm.InitConfiguration, certNotAfterTime, certTree); err != nil {
+		if err := CreatePKIAssets(node, &c.Kubeadm.InitConfiguration, certNotAfterTime, pki.KeyAlgorithm(c.CertKeyAlgorithm), certTree); err != nil {
 			return err
 		}
 		certTree = node.CertificateTree
 
-		return node.CertificateTree.CreateKubeConfig(&c.Kubeadm.InitConfiguration)
+		return createKubeConfig(node, c)
 
 	}); err != nil {
 		return err
 	}
 
-	return operator.RunOnOtherMasters(c, func(node *rundata.Node, c *rundata.Cluster) error {
+	if err := operator.RunOnOtherMasters(c, func(node *rundata.Node, c *rundata.Cluster) error {
 		klog.V(2).Infof("[%s] [cert] Creating certificate", node.HostInfo.Host)
 		klog.V(3).Infof("[%s] [cert] The cert not after time is %v", node.HostInfo.Host)
 		//c.Mutex.Lock()
 		//c.Kubeadm.NodeRegistration.Name = node.Name
 
-		if err := CreatePKIAssets(node, &c.Kubeadm.InitConfiguration, certNotAfterTime, certTree); err != nil {
+		if err := CreatePKIAssets(node, &c.Kubeadm.InitConfiguration, certNotAfterTime, pki.KeyAlgorithm(c.CertKeyAlgorithm), certTree); err != nil {
 			return err
 		}
 		//c.Mutex.Unlock()
 
-		return node.CertificateTree.CreateKubeConfig(&c.Kubeadm.InitConfiguration)
+		return createKubeConfig(node, c)
+
+	}); err != nil {
+		return err
+	}
 
-	})
+	if pending && c.DryRun.Enable {
+		return errors.Errorf("[cert] external CA mode: certificates in %s are not signed by the external CA yet, "+
+			"the commands can't be rendered without them, run kubei without --dry-run to write the certificate signing requests",
+			filepath.Join(c.CertificatesDir, "nodes"))
+	}
+	if pending {
+		return errors.Errorf("[cert] external CA mode: certificate signing requests have been written to %s, "+
+			"sign them with the external CA, save the certificates as <name>.crt next to them and run kubei again",
+			filepath.Join(c.CertificatesDir, "nodes"))
+	}
+	return nil
 }
 
 // CreatePKIAssets will create all PKI assets necessary.
-func CreatePKIAssets(node *rundata.Node, cfg *kubeadmapi.InitConfiguration, notAfterTime time.Duration, certTree rundata.CertificateTree) error {
+func CreatePKIAssets(node *rundata.Node, cfg *kubeadmapi.InitConfiguration, notAfterTime time.Duration, keyAlgorithm pki.KeyAlgorithm, certTree rundata.CertificateTree) error {
 	klog.V(3).Infoln("creating PKI assets")
 
 	var certList rundata.Certificates
@@ -67,6 +107,10 @@ func CreatePKIAssets(node *rundata.Node, cfg *kubeadmapi.InitConfiguration, notA
 
 	certList = rundata.GetDefaultCertList()
 
+	for _, cert := range certList {
+		cert.Config.KeyAlgorithm = keyAlgorithm
+	}
+
 	certMap := certList.AsMap()
 
 	for cert := range certTree {
@@ -88,10 +132,10 @@ func CreatePKIAssets(node *rundata.Node, cfg *kubeadmapi.InitConfiguration, notA
 }
 
 // CreateServiceAccountKeyAndPublicKey creates new public/private key files for signing service account users.
-func CreateServiceAccountKeyAndPublicKey(keyType x509.PublicKeyAlgorithm) (crypto.Signer, crypto.PublicKey, error) {
+func CreateServiceAccountKeyAndPublicKey(keyAlgorithm pki.KeyAlgorithm) (crypto.Signer, crypto.PublicKey, error) {
 	klog.V(3).Infoln("creating new public/private key files for signing service account users")
 
-	key, err := kubeadmpkiutil.NewPrivateKey(keyType)
+	key, err := pki.NewPrivateKey(keyAlgorithm)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -101,8 +145,8 @@ func CreateServiceAccountKeyAndPublicKey(keyType x509.PublicKeyAlgorithm) (crypt
 	return key, key.Public(), nil
 }
 
-func CreateEncodeServiceAccountKeyAndPublicKey(keyType x509.PublicKeyAlgorithm) (encodedPrivatKey, encodedPublicKey []byte, err error) {
-	privatKey, publicKey, err := CreateServiceAccountKeyAndPublicKey(keyType)
+func CreateEncodeServiceAccountKeyAndPublicKey(keyAlgorithm pki.KeyAlgorithm) (encodedPrivatKey, encodedPublicKey []byte, err error) {
+	privatKey, publicKey, err := CreateServiceAccountKeyAndPublicKey(keyAlgorithm)
 	if err != nil {
 		return nil, nil, err
 	}
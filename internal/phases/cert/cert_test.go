@@ -1,13 +1,26 @@
 package cert
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/hex"
+	"encoding/pem"
 	"fmt"
 	"github.com/yuyicai/kubei/internal/rundata"
 	"github.com/yuyicai/kubei/pkg/pki"
+	"io/ioutil"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+	"math/big"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,7 +36,7 @@ func SetCfg() *kubeadmapi.InitConfiguration {
 
 func TestCreateServiceAccountKeyAndPublicKeyFiles(t *testing.T) {
 	type args struct {
-		keyType x509.PublicKeyAlgorithm
+		keyAlgorithm pki.KeyAlgorithm
 	}
 	tests := []struct {
 		name    string
@@ -32,16 +45,32 @@ func TestCreateServiceAccountKeyAndPublicKeyFiles(t *testing.T) {
 	}{
 		{
 			name: "SA",
-			args: args{keyType: x509.RSA},
+			args: args{keyAlgorithm: pki.RSA2048},
+		},
+		{
+			name: "SA, RSA-4096",
+			args: args{keyAlgorithm: pki.RSA4096},
+		},
+		{
+			name: "SA, ECDSA-P256",
+			args: args{keyAlgorithm: pki.ECDSAP256},
+		},
+		{
+			name:    "SA, unsupported key algorithm",
+			args:    args{keyAlgorithm: "DSA"},
+			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, got1, err := CreateServiceAccountKeyAndPublicKey(tt.args.keyType)
+			got, got1, err := CreateServiceAccountKeyAndPublicKey(tt.args.keyAlgorithm)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CreateServiceAccountKeyAndPublicKey() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if tt.wantErr {
+				return
+			}
 			key, _ := pki.EncodePrivateKeyPEM(got)
 			publicKey, _ := pki.EncodePublicKeyPEM(got1)
 			t.Log(string(key))
@@ -176,7 +205,7 @@ func TestCreatePKIAssets(t *testing.T) {
 			nodes[0] = &rundata.Node{}
 			nodes[0].Name = "yyzz"
 
-			if err := CreatePKIAssets(nodes[0], tt.args.cfg, tt.args.notAfterTime, certTree); (err != nil) != tt.wantErr {
+			if err := CreatePKIAssets(nodes[0], tt.args.cfg, tt.args.notAfterTime, pki.RSA2048, certTree); (err != nil) != tt.wantErr {
 				t.Errorf("CreatePKIAssets() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
@@ -260,7 +289,7 @@ func TestCreatePKIAssetsLock(t *testing.T) {
 					n = &rundata.Node{}
 					n.Name = "yyzz" + strconv.Itoa(i)
 
-					if err := CreatePKIAssets(n, tt.args.cfg, tt.args.notAfterTime, certTree); (err != nil) != tt.wantErr {
+					if err := CreatePKIAssets(n, tt.args.cfg, tt.args.notAfterTime, pki.RSA2048, certTree); (err != nil) != tt.wantErr {
 						t.Errorf("CreatePKIAssets() error = %v, wantErr %v", err, tt.wantErr)
 					}
 
@@ -402,3 +431,608 @@ func TestAllCreateCert(t *testing.T) {
 		}
 	}
 }
+
+func TestCreatePKIAssetsWithExistingCA(t *testing.T) {
+	cfg := SetCfg()
+	cfg.LocalAPIEndpoint.AdvertiseAddress = "172.16.0.111"
+	cfg.Networking.ServiceSubnet = "10.96.0.0/12"
+
+	node := &rundata.Node{Name: "node0"}
+	node.HostInfo.Host = "172.16.0.111"
+	if err := CreatePKIAssets(node, cfg, 24*time.Hour*365, pki.RSA2048, rundata.CertificateTree{}); err != nil {
+		t.Fatalf("CreatePKIAssets() error = %v", err)
+	}
+
+	caTree := rundata.CertificateTree{}
+	for ca := range node.CertificateTree {
+		certPEM := pki.EncodeCertPEM(ca.Cert)
+		keyPEM, err := pki.EncodePrivateKeyPEM(ca.Key)
+		if err != nil {
+			t.Fatalf("EncodePrivateKeyPEM() error = %v", err)
+		}
+		loaded := &rundata.Cert{Name: ca.Name, BaseName: ca.BaseName}
+		if err := setCA(loaded, certPEM, keyPEM); err != nil {
+			t.Fatalf("setCA() error = %v", err)
+		}
+		caTree[loaded] = rundata.Certificates{}
+	}
+
+	renewed := &rundata.Node{Name: "node0"}
+	renewed.HostInfo.Host = "172.16.0.111"
+	if err := CreatePKIAssets(renewed, cfg, 24*time.Hour*365*2, pki.ECDSAP256, caTree); err != nil {
+		t.Fatalf("CreatePKIAssets() error = %v", err)
+	}
+
+	for ca, certs := range renewed.CertificateTree {
+		if _, ok := caTree[ca]; !ok {
+			t.Errorf("CA %q was regenerated", ca.Name)
+		}
+		for _, cert := range certs {
+			if err := cert.Cert.CheckSignatureFrom(ca.Cert); err != nil {
+				t.Errorf("cert %q is not signed by CA %q: %v", cert.Name, ca.Name, err)
+			}
+		}
+	}
+}
+
+func TestSignByExternalCA(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := &rundata.CertRootCA
+	caCert, caKey, err := pki.NewCertificateAuthority(&pki.CertConfig{
+		Config:       ca.Config.Config,
+		NotAfterTime: 24 * time.Hour * 365 * 20,
+	})
+	if err != nil {
+		t.Fatalf("NewCertificateAuthority() error = %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ca.BaseName+".crt"), pki.EncodeCertPEM(caCert), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := SetCfg()
+	cfg.LocalAPIEndpoint.AdvertiseAddress = "172.16.0.111"
+	cfg.Networking.ServiceSubnet = "10.96.0.0/12"
+	notAfterTime := 24 * time.Hour * 365
+
+	createNode := func() *rundata.Node {
+		certTree, err := loadUserCA(dir, notAfterTime)
+		if err != nil {
+			t.Fatalf("loadUserCA() error = %v", err)
+		}
+		node := &rundata.Node{Name: "node0"}
+		node.HostInfo.Host = "172.16.0.111"
+		if err := CreatePKIAssets(node, cfg, notAfterTime, pki.RSA2048, certTree); err != nil {
+			t.Fatalf("CreatePKIAssets() error = %v", err)
+		}
+		return node
+	}
+
+	node := createNode()
+	pending, err := signByExternalCA(node, cfg, dir)
+	if err != nil {
+		t.Fatalf("signByExternalCA() error = %v", err)
+	}
+	if !pending {
+		t.Fatal("signByExternalCA() pending = false, want true")
+	}
+
+	// sign the certificate signing requests with the external CA
+	csrFiles, err := filepath.Glob(filepath.Join(dir, "nodes", node.Name, "*.csr"))
+	if err != nil || len(csrFiles) == 0 {
+		t.Fatalf("no certificate signing request written: %v", err)
+	}
+	for _, csrFile := range csrFiles {
+		csrPEM, err := ioutil.ReadFile(csrFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, _ := pem.Decode(csrPEM)
+		csr, err := x509.ParseCertificateRequest(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      csr.Subject,
+			DNSNames:     csr.DNSNames,
+			IPAddresses:  csr.IPAddresses,
+			NotBefore:    caCert.NotBefore,
+			NotAfter:     time.Now().Add(notAfterTime),
+			KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, &tmpl, caCert, csr.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+		if err := ioutil.WriteFile(strings.TrimSuffix(csrFile, ".csr")+".crt", certPEM, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	node = createNode()
+	pending, err = signByExternalCA(node, cfg, dir)
+	if err != nil {
+		t.Fatalf("signByExternalCA() error = %v", err)
+	}
+	if pending {
+		t.Fatal("signByExternalCA() pending = true, want false")
+	}
+	for ca, certs := range node.CertificateTree {
+		for _, cert := range certs {
+			if cert.Cert == nil || cert.Key == nil {
+				t.Errorf("cert %q of CA %q is not set", cert.Name, ca.Name)
+			}
+		}
+	}
+}
+
+func TestLoadUserCALifetime(t *testing.T) {
+	writeCA := func(dir string, notBefore, notAfter time.Time) {
+		key, err := pki.NewPrivateKey(pki.RSA2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := x509.Certificate{
+			SerialNumber:          big.NewInt(0),
+			Subject:               pkix.Name{CommonName: "kubernetes"},
+			NotBefore:             notBefore,
+			NotAfter:              notAfter,
+			KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, key.Public(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyPEM, err := pki.EncodePrivateKeyPEM(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ca := rundata.CertRootCA
+		if err := ioutil.WriteFile(filepath.Join(dir, ca.BaseName+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, ca.BaseName+".key"), keyPEM, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	notAfterTime := 24 * time.Hour * 365 * 10
+
+	// a CA created by kubeadm some time ago has less than 10 years left
+	dir := t.TempDir()
+	writeCA(dir, now.Add(-24*time.Hour*365), now.Add(24*time.Hour*365*9))
+	certTree, err := loadUserCA(dir, notAfterTime)
+	if err != nil {
+		t.Fatalf("loadUserCA() error = %v", err)
+	}
+
+	cfg := SetCfg()
+	cfg.LocalAPIEndpoint.AdvertiseAddress = "172.16.0.111"
+	cfg.Networking.ServiceSubnet = "10.96.0.0/12"
+	node := &rundata.Node{Name: "node0"}
+	node.HostInfo.Host = "172.16.0.111"
+	if err := CreatePKIAssets(node, cfg, notAfterTime, pki.RSA2048, certTree); err != nil {
+		t.Fatalf("CreatePKIAssets() error = %v", err)
+	}
+	for ca, certs := range node.CertificateTree {
+		if ca.Name != rundata.CertRootCA.Name {
+			continue
+		}
+		for _, cert := range certs {
+			if !cert.Cert.NotAfter.Equal(ca.Cert.NotAfter) {
+				t.Errorf("cert %q expires at %v, want the expiry of the CA %v", cert.Name, cert.Cert.NotAfter, ca.Cert.NotAfter)
+			}
+		}
+	}
+
+	for name, validity := range map[string][2]time.Time{
+		"expired":       {now.Add(-24 * time.Hour * 365), now.Add(-time.Hour)},
+		"not yet valid": {now.Add(time.Hour), now.Add(24 * time.Hour * 365)},
+	} {
+		dir := t.TempDir()
+		writeCA(dir, validity[0], validity[1])
+		if _, err := loadUserCA(dir, notAfterTime); err == nil {
+			t.Errorf("loadUserCA() with a CA %s, want error", name)
+		}
+	}
+}
+
+func TestCreatePKIAssetsKeyAlgorithm(t *testing.T) {
+	tests := []struct {
+		name         string
+		keyAlgorithm pki.KeyAlgorithm
+		checkKey     func(key crypto.Signer) bool
+	}{
+		{
+			name:         "RSA-2048",
+			keyAlgorithm: pki.RSA2048,
+			checkKey: func(key crypto.Signer) bool {
+				k, ok := key.(*rsa.PrivateKey)
+				return ok && k.N.BitLen() == 2048
+			},
+		},
+		{
+			name:         "RSA-4096",
+			keyAlgorithm: pki.RSA4096,
+			checkKey: func(key crypto.Signer) bool {
+				k, ok := key.(*rsa.PrivateKey)
+				return ok && k.N.BitLen() == 4096
+			},
+		},
+		{
+			name:         "ECDSA-P256",
+			keyAlgorithm: pki.ECDSAP256,
+			checkKey: func(key crypto.Signer) bool {
+				k, ok := key.(*ecdsa.PrivateKey)
+				return ok && k.Curve == elliptic.P256()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := SetCfg()
+			cfg.LocalAPIEndpoint.AdvertiseAddress = "172.16.0.111"
+			cfg.Networking.ServiceSubnet = "10.96.0.0/12"
+
+			node := &rundata.Node{Name: "node0"}
+			node.HostInfo.Host = "172.16.0.111"
+			if err := CreatePKIAssets(node, cfg, 24*time.Hour*365, tt.keyAlgorithm, rundata.CertificateTree{}); err != nil {
+				t.Fatalf("CreatePKIAssets() error = %v", err)
+			}
+
+			for ca, certs := range node.CertificateTree {
+				if !tt.checkKey(ca.Key) {
+					t.Errorf("CA %q key is not %s", ca.Name, tt.keyAlgorithm)
+				}
+				for _, cert := range certs {
+					if !tt.checkKey(cert.Key) {
+						t.Errorf("cert %q key is not %s", cert.Name, tt.keyAlgorithm)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCreatePKIAssetsExtraSANs(t *testing.T) {
+	cfg := SetCfg()
+	cfg.LocalAPIEndpoint.AdvertiseAddress = "172.16.0.111"
+	cfg.Networking.ServiceSubnet = "10.96.0.0/12"
+	cfg.APIServer.CertSANs = []string{"10.0.0.100", "api.example.com", "*.k8s.example.com"}
+
+	node := &rundata.Node{Name: "node0"}
+	node.HostInfo.Host = "172.16.0.111"
+	if err := CreatePKIAssets(node, cfg, 24*time.Hour*365, pki.RSA2048, rundata.CertificateTree{}); err != nil {
+		t.Fatalf("CreatePKIAssets() error = %v", err)
+	}
+
+	var apiserver *rundata.Cert
+	for _, certs := range node.CertificateTree {
+		for _, cert := range certs {
+			if cert.Name == rundata.CertAPIServer.Name {
+				apiserver = cert
+			}
+		}
+	}
+	if apiserver == nil {
+		t.Fatal("apiserver cert not found")
+	}
+
+	for _, name := range []string{"api.example.com", "*.k8s.example.com"} {
+		if err := apiserver.Cert.VerifyHostname(strings.Replace(name, "*", "foo", 1)); err != nil {
+			t.Errorf("apiserver cert does not contain SAN %q: %v", name, err)
+		}
+	}
+	if err := apiserver.Cert.VerifyHostname("10.0.0.100"); err != nil {
+		t.Errorf("apiserver cert does not contain SAN %q: %v", "10.0.0.100", err)
+	}
+}
+
+func TestValidateCertSANs(t *testing.T) {
+	tests := []struct {
+		name    string
+		sans    []string
+		wantErr bool
+	}{
+		{
+			name: "IPs and DNS names",
+			sans: []string{"10.0.0.100", "::1", "api.example.com", "*.example.com"},
+		},
+		{
+			name:    "invalid wildcard",
+			sans:    []string{"api.*.example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid DNS name",
+			sans:    []string{"API_SERVER"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := rundata.ValidateCertSANs(tt.sans); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCertSANs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBackup(t *testing.T) {
+	cfg := SetCfg()
+	cfg.LocalAPIEndpoint.AdvertiseAddress = "172.16.0.111"
+	cfg.LocalAPIEndpoint.BindPort = 6443
+	cfg.Networking.ServiceSubnet = "10.96.0.0/12"
+
+	node := &rundata.Node{Name: "node0"}
+	node.HostInfo.Host = "172.16.0.111"
+	if err := CreatePKIAssets(node, cfg, 24*time.Hour*365, pki.RSA2048, rundata.CertificateTree{}); err != nil {
+		t.Fatalf("CreatePKIAssets() error = %v", err)
+	}
+	if err := node.CertificateTree.CreateKubeConfig(cfg); err != nil {
+		t.Fatalf("CreateKubeConfig() error = %v", err)
+	}
+
+	files := map[string][]byte{}
+	for ca, certs := range node.CertificateTree {
+		files[ca.BaseName+".crt"] = pki.EncodeCertPEM(ca.Cert)
+		files[ca.BaseName+".key"], _ = pki.EncodePrivateKeyPEM(ca.Key)
+		for _, cert := range certs {
+			if cert.IsKubeConfig && cert.BaseName == "admin.conf" {
+				files[cert.BaseName], _ = EncodeKubeConfig(cert.KubeConfig)
+			}
+		}
+	}
+	saKey, saPub, err := CreateEncodeServiceAccountKeyAndPublicKey(pki.RSA2048)
+	if err != nil {
+		t.Fatalf("CreateEncodeServiceAccountKeyAndPublicKey() error = %v", err)
+	}
+	files["sa.key"], files["sa.pub"] = saKey, saPub
+
+	if err := validateBackup(files); err != nil {
+		t.Fatalf("validateBackup() error = %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "kubei-backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	passphraseFile := filepath.Join(dir, "passphrase")
+	if err := ioutil.WriteFile(passphraseFile, []byte("passphrase\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, b := range []rundata.Backup{
+		{Path: filepath.Join(dir, "pki")},
+		{Path: filepath.Join(dir, "pki.tar.gz"), PassphraseFile: passphraseFile},
+	} {
+		if err := writeBackup(b, files); err != nil {
+			t.Fatalf("writeBackup(%s) error = %v", b.Path, err)
+		}
+
+		got, err := readBackup(b)
+		if err != nil {
+			t.Fatalf("readBackup(%s) error = %v", b.Path, err)
+		}
+		for name, content := range files {
+			if string(got[name]) != string(content) {
+				t.Errorf("readBackup(%s) %s differs from the backup", b.Path, name)
+			}
+		}
+	}
+
+	// the backup dir can be used as --cert-dir
+	certTree, err := loadUserCA(filepath.Join(dir, "pki"), 24*time.Hour)
+	if err != nil {
+		t.Fatalf("loadUserCA() error = %v", err)
+	}
+	if len(certTree) != len(rundata.GetDefaultCAList()) {
+		t.Errorf("loadUserCA() loaded %d CAs, want %d", len(certTree), len(rundata.GetDefaultCAList()))
+	}
+
+	c := &rundata.Cluster{Kubei: rundata.NewKubei()}
+	c.CertificatesDir = filepath.Join(dir, "pki")
+	key, _, err := getEncodeServiceAccountKeyAndPublicKey(c)
+	if err != nil {
+		t.Fatalf("getEncodeServiceAccountKeyAndPublicKey() error = %v", err)
+	}
+	if string(key) != string(saKey) {
+		t.Error("service account key was not loaded from the backup dir")
+	}
+}
+
+func TestMergeKubeConfig(t *testing.T) {
+	node := &rundata.Node{Name: "node0"}
+	node.HostInfo.Host = "172.16.0.111"
+	cfg := SetCfg()
+	cfg.LocalAPIEndpoint.AdvertiseAddress = "172.16.0.111"
+	cfg.Networking.ServiceSubnet = "10.96.0.0/12"
+	if err := CreatePKIAssets(node, cfg, 24*time.Hour*365, pki.RSA2048, rundata.CertificateTree{}); err != nil {
+		t.Fatalf("CreatePKIAssets() error = %v", err)
+	}
+	var ca *rundata.Cert
+	for c := range node.CertificateTree {
+		if c.Name == rundata.CertRootCA.Name {
+			ca = c
+		}
+	}
+
+	k := rundata.Kubeconfig{ClientName: "alice", ClientGroups: []string{"dev"}, ClientCertTTL: time.Hour}
+	authInfo, err := newClientAuthInfo(ca, k, pki.ECDSAP256)
+	if err != nil {
+		t.Fatalf("newClientAuthInfo() error = %v", err)
+	}
+	cert, err := pki.ParseCertPEM(authInfo.ClientCertificateData)
+	if err != nil {
+		t.Fatalf("ParseCertPEM() error = %v", err)
+	}
+	if err := cert.CheckSignatureFrom(ca.Cert); err != nil {
+		t.Errorf("client cert is not signed by the CA: %v", err)
+	}
+	if cert.Subject.CommonName != "alice" || len(cert.Subject.Organization) != 1 || cert.Subject.Organization[0] != "dev" {
+		t.Errorf("client cert subject = %v", cert.Subject)
+	}
+	if cert.NotAfter.After(time.Now().Add(time.Hour + time.Minute)) {
+		t.Errorf("client cert is not short-lived, not after %v", cert.NotAfter)
+	}
+
+	server, err := getKubeConfigServer("https://apiserver.k8s.local:6443", "", "172.16.0.111", 6443)
+	if err != nil {
+		t.Fatalf("getKubeConfigServer() error = %v", err)
+	}
+	if server != "https://172.16.0.111:6443" {
+		t.Errorf("getKubeConfigServer() = %s, want the apiserver of master0", server)
+	}
+	if _, err := getKubeConfigServer("https://apiserver.k8s.local:6443", "http://127.0.0.1:16443", "172.16.0.111", 6443); err == nil {
+		t.Error("getKubeConfigServer() with a non https server error = nil")
+	}
+
+	dir, err := ioutil.TempDir("", "kubei-kubeconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config")
+
+	existing := clientcmdapi.NewConfig()
+	existing.Clusters["other"] = &clientcmdapi.Cluster{Server: "https://10.0.0.1:6443"}
+	existing.AuthInfos["other"] = &clientcmdapi.AuthInfo{Token: "token"}
+	existing.Contexts["other"] = &clientcmdapi.Context{Cluster: "other", AuthInfo: "other"}
+	existing.CurrentContext = "other"
+	if err := clientcmd.WriteToFile(*existing, path); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &clientcmdapi.Cluster{Server: server, CertificateAuthorityData: pki.EncodeCertPEM(ca.Cert), TLSServerName: "apiserver.k8s.local"}
+	if err := mergeKubeConfig(path, "prod", "prod-alice", cluster, authInfo, false); err != nil {
+		t.Fatalf("mergeKubeConfig() error = %v", err)
+	}
+
+	got, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CurrentContext != "other" {
+		t.Errorf("current context = %s, want other which is not switched", got.CurrentContext)
+	}
+	if _, ok := got.Contexts["other"]; !ok {
+		t.Error("existing context was removed")
+	}
+	if got.Clusters["prod"].Server != server || got.Clusters["prod"].TLSServerName != "apiserver.k8s.local" {
+		t.Errorf("cluster = %+v", got.Clusters["prod"])
+	}
+	if got.Contexts["prod"].AuthInfo != "prod-alice" {
+		t.Errorf("context = %+v", got.Contexts["prod"])
+	}
+
+	// the current context is switched with --kubeconfig-use-context
+	if err := mergeKubeConfig(path, "prod", "prod-alice", cluster, authInfo, true); err != nil {
+		t.Fatalf("mergeKubeConfig() error = %v", err)
+	}
+	if got, err = clientcmd.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if got.CurrentContext != "prod" {
+		t.Errorf("current context = %s, want prod", got.CurrentContext)
+	}
+
+	// or if there is no current context
+	newPath := filepath.Join(dir, "new")
+	if err := mergeKubeConfig(newPath, "prod", "prod-alice", cluster, authInfo, false); err != nil {
+		t.Fatalf("mergeKubeConfig() error = %v", err)
+	}
+	if got, err = clientcmd.LoadFromFile(newPath); err != nil {
+		t.Fatal(err)
+	}
+	if got.CurrentContext != "prod" {
+		t.Errorf("current context of a new kubeconfig = %s, want prod", got.CurrentContext)
+	}
+}
+
+// TestCertPhaseDryRun runs the cert phase of kubei init with --dry-run and --backup,
+// the commands are recorded and nothing is written to the local disk.
+func TestCertPhaseDryRun(t *testing.T) {
+	dir := t.TempDir()
+	scripts := filepath.Join(dir, "scripts")
+
+	newCluster := func() *rundata.Cluster {
+		c := rundata.NewCluster()
+		c.DryRun.Enable = true
+		c.DryRun.Dir = scripts
+		c.Backup.Path = filepath.Join(dir, "backup")
+		c.Kubeadm.LocalAPIEndpoint.AdvertiseAddress = "172.16.0.111"
+		c.Kubeadm.LocalAPIEndpoint.BindPort = 6443
+		c.Kubeadm.Networking.ServiceSubnet = "10.96.0.0/12"
+		for i := 0; i < 2; i++ {
+			host := "172.16.0.11" + strconv.Itoa(i+1)
+			c.ClusterNodes.Masters = append(c.ClusterNodes.Masters, &rundata.Node{
+				Name:            "node" + strconv.Itoa(i),
+				HostInfo:        rundata.HostInfo{Host: host},
+				Executor:        rundata.NewRecorder(host, scripts, nil),
+				CertificateTree: rundata.CertificateTree{},
+			})
+		}
+		return c
+	}
+
+	c := newCluster()
+	if err := CreateCert(c); err != nil {
+		t.Fatalf("CreateCert() error = %v", err)
+	}
+	if err := SendCert(c); err != nil {
+		t.Fatalf("SendCert() error = %v", err)
+	}
+	if err := BackupCert(c); err != nil {
+		t.Fatalf("BackupCert() error = %v", err)
+	}
+	if err := GetKubeConfig(c); err != nil {
+		t.Fatalf("GetKubeConfig() error = %v", err)
+	}
+
+	if _, err := os.Stat(c.Backup.Path); !os.IsNotExist(err) {
+		t.Errorf("the backup %s is written with --dry-run", c.Backup.Path)
+	}
+	recorded, err := filepath.Glob(filepath.Join(scripts, "172.16.0.111", "*.sh"))
+	if err != nil || len(recorded) == 0 {
+		t.Fatalf("no commands recorded for master0: %v", err)
+	}
+	last, err := ioutil.ReadFile(recorded[len(recorded)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(last), "cat /etc/kubernetes/admin.conf") {
+		t.Errorf("the last command of master0 = %q, want the fetch of admin.conf for the backup", last)
+	}
+
+	// external CA mode, the certificate signing requests are not written
+	caDir := filepath.Join(dir, "pki")
+	if err := os.MkdirAll(caDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	caCert, _, err := pki.NewCertificateAuthority(&pki.CertConfig{
+		Config:       rundata.CertRootCA.Config.Config,
+		NotAfterTime: 24 * time.Hour * 365 * 20,
+	})
+	if err != nil {
+		t.Fatalf("NewCertificateAuthority() error = %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(caDir, rundata.CertRootCA.BaseName+".crt"), pki.EncodeCertPEM(caCert), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c = newCluster()
+	c.CertificatesDir = caDir
+	if err := CreateCert(c); err == nil || !strings.Contains(err.Error(), "without --dry-run") {
+		t.Errorf("CreateCert() in external CA mode = %v, want the error of the unsigned certificates", err)
+	}
+	if _, err := os.Stat(filepath.Join(caDir, "nodes")); !os.IsNotExist(err) {
+		t.Errorf("the certificate signing requests are written to %s with --dry-run", filepath.Join(caDir, "nodes"))
+	}
+}
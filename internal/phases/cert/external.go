@@ -0,0 +1,177 @@
+package cert
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+
+	"github.com/yuyicai/kubei/internal/rundata"
+	"github.com/yuyicai/kubei/pkg/pki"
+)
+
+// loadUserCA loads the CAs provided by the user from the certificates dir.
+// A CA whose certificate is absent is left out, so that it will be created by kubei,
+// and a CA whose key is absent is an external CA, the certs of which are signed outside kubei.
+func loadUserCA(dir string, notAfterTime time.Duration) (rundata.CertificateTree, error) {
+	certTree := rundata.CertificateTree{}
+
+	for _, ca := range rundata.GetDefaultCAList() {
+		certPEM, err := ioutil.ReadFile(filepath.Join(dir, ca.BaseName+".crt"))
+		if os.IsNotExist(err) {
+			klog.V(2).Infof("[cert] %q CA certificate not found in %s, it will be created", ca.Name, dir)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %q CA certificate", ca.Name)
+		}
+
+		ca.Cert, err = pki.ParseCertPEM(certPEM)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %q CA certificate", ca.Name)
+		}
+
+		keyPEM, err := ioutil.ReadFile(filepath.Join(dir, ca.BaseName+".key"))
+		switch {
+		case os.IsNotExist(err):
+			klog.V(2).Infof("[cert] %q CA key not found in %s, using external CA mode", ca.Name, dir)
+		case err != nil:
+			return nil, errors.Wrapf(err, "failed to read %q CA key", ca.Name)
+		default:
+			ca.Key, err = pki.ParsePrivateKeyPEM(keyPEM)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse %q CA key", ca.Name)
+			}
+		}
+
+		if err := validateCA(ca, notAfterTime); err != nil {
+			return nil, errors.Wrapf(err, "invalid %q CA in %s", ca.Name, dir)
+		}
+
+		certTree[ca] = rundata.Certificates{}
+	}
+
+	return certTree, nil
+}
+
+// validateCA validates the CA provided by the user. A CA expiring before the requested lifetime of the certs is
+// still used, the certs signed by it expire together with it.
+func validateCA(ca *rundata.Cert, notAfterTime time.Duration) error {
+	if err := pki.ValidateCA(ca.Cert, ca.Key); err != nil {
+		return err
+	}
+
+	if ca.Key != nil && ca.Cert.NotAfter.Before(time.Now().Add(notAfterTime)) {
+		klog.Warningf("[cert] %q CA expires at %v, the certificates signed by it expire at the same time instead of after %v",
+			ca.Name, ca.Cert.NotAfter, notAfterTime)
+	}
+	return nil
+}
+
+// signByExternalCA sets the certs of the external CAs on the node.
+// The certs signed by the external CA are loaded from <dir>/nodes/<node name>/<cert name>.crt,
+// for the certs which are not signed yet, a key and a certificate signing request are written there instead,
+// nothing is written with --dry-run. It returns true if any cert is still waiting to be signed.
+func signByExternalCA(node *rundata.Node, ic *kubeadmapi.InitConfiguration, dir string) (bool, error) {
+	nodeDir := filepath.Join(dir, "nodes", node.Name)
+	pending := false
+
+	for ca, leaves := range node.CertificateTree {
+		if ca.Key != nil {
+			continue
+		}
+
+		for _, leaf := range leaves {
+			signed, err := loadSignedCert(leaf, ca, nodeDir)
+			if err != nil {
+				return false, errors.Wrapf(err, "[%s] [cert] Failed to load %q certificate", node.HostInfo.Host, leaf.Name)
+			}
+			if signed {
+				continue
+			}
+
+			if err := writeCSR(node, ic, leaf, nodeDir); err != nil {
+				return false, errors.Wrapf(err, "[%s] [cert] Failed to write %q certificate signing request", node.HostInfo.Host, leaf.Name)
+			}
+			pending = true
+		}
+	}
+
+	return pending, nil
+}
+
+func loadSignedCert(leaf, ca *rundata.Cert, dir string) (bool, error) {
+	certPEM, err := ioutil.ReadFile(filepath.Join(dir, leaf.Name+".crt"))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	keyPEM, err := ioutil.ReadFile(filepath.Join(dir, leaf.Name+".key"))
+	if err != nil {
+		return false, err
+	}
+
+	if leaf.Cert, err = pki.ParseCertPEM(certPEM); err != nil {
+		return false, err
+	}
+	if leaf.Key, err = pki.ParsePrivateKeyPEM(keyPEM); err != nil {
+		return false, err
+	}
+
+	if err := leaf.Cert.CheckSignatureFrom(ca.Cert); err != nil {
+		return false, errors.Wrapf(err, "the certificate is not signed by the %q CA", ca.Name)
+	}
+	if time.Now().After(leaf.Cert.NotAfter) {
+		return false, errors.Errorf("the certificate expired at %v", leaf.Cert.NotAfter)
+	}
+	return true, pki.ValidateKey(leaf.Cert, leaf.Key)
+}
+
+func writeCSR(node *rundata.Node, ic *kubeadmapi.InitConfiguration, leaf *rundata.Cert, dir string) error {
+	keyFile := filepath.Join(dir, leaf.Name+".key")
+	csrFile := filepath.Join(dir, leaf.Name+".csr")
+
+	// nothing is written to the local disk with --dry-run
+	if node.DryRun() {
+		fmt.Printf("[%s] [cert] [dry-run] skip writing certificate signing request: %s\n", node.HostInfo.Host, csrFile)
+		return nil
+	}
+
+	// keep the key of the certificate signing request which may be being signed
+	if _, err := os.Stat(csrFile); err == nil {
+		if _, err := os.Stat(keyFile); err == nil {
+			return nil
+		}
+	}
+
+	csr, err := leaf.CreateCSR(node, ic)
+	if err != nil {
+		return err
+	}
+
+	encodedKey, err := pki.EncodePrivateKeyPEM(leaf.Key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(keyFile, encodedKey, 0600); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(csrFile, pki.EncodeCSRPEM(csr), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("[%s] [cert] write certificate signing request: %s\n", node.HostInfo.Host, csrFile)
+	return nil
+}
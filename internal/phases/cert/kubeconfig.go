@@ -0,0 +1,218 @@
+package cert
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/klog"
+	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
+
+	"github.com/yuyicai/kubei/internal/operator"
+	"github.com/yuyicai/kubei/internal/rundata"
+	"github.com/yuyicai/kubei/pkg/pki"
+)
+
+// GetKubeConfig fetches admin.conf from master0 and merges it into the local kubeconfig file of the operator.
+// If a client name is set, a short-lived client certificate signed by the cluster CA is used instead of the admin identity.
+func GetKubeConfig(c *rundata.Cluster) error {
+	// there is no admin.conf to fetch without the nodes
+	if c.DryRun.Enable {
+		klog.Info("[kubeconfig] Skip getting kubeconfig for the operator with --dry-run")
+		return nil
+	}
+
+	color.HiBlue("Getting kubeconfig for the operator 📘")
+
+	k := c.Kubeconfig
+	var admin *clientcmdapi.Config
+	var authInfo *clientcmdapi.AuthInfo
+	var master0 string
+
+	if err := operator.RunOnFirstMaster(c, func(node *rundata.Node, c *rundata.Cluster) error {
+		master0 = node.HostInfo.Host
+
+		klog.V(2).Infof("[%s] [kubeconfig] Fetching %s", node.HostInfo.Host, kubeadmconstants.AdminKubeConfigFileName)
+		content, err := node.RunOut(fmt.Sprintf("cat %s", kubeadmconstants.GetAdminKubeConfigPath()))
+		if err != nil {
+			return fmt.Errorf("[%s] [kubeconfig] Failed to fetch %s: %v", node.HostInfo.Host, kubeadmconstants.AdminKubeConfigFileName, err)
+		}
+		if admin, err = clientcmd.Load(content); err != nil {
+			return fmt.Errorf("[%s] [kubeconfig] Failed to parse %s: %v", node.HostInfo.Host, kubeadmconstants.AdminKubeConfigFileName, err)
+		}
+
+		if k.ClientName == "" {
+			return nil
+		}
+
+		klog.V(2).Infof("[%s] [kubeconfig] Fetching CA to sign the client certificate for %q", node.HostInfo.Host, k.ClientName)
+		ca := &rundata.Cert{Name: rundata.CertRootCA.Name, BaseName: rundata.CertRootCA.BaseName}
+		if err := fetchCA(node, ca); err != nil {
+			return errors.Wrapf(err, "[%s] [kubeconfig] Failed to fetch %q CA", node.HostInfo.Host, ca.Name)
+		}
+
+		authInfo, err = newClientAuthInfo(ca, k, pki.KeyAlgorithm(c.CertKeyAlgorithm))
+		return err
+	}); err != nil {
+		return err
+	}
+
+	cluster, adminAuthInfo, err := getCurrentClusterAndAuthInfo(admin)
+	if err != nil {
+		return err
+	}
+	if authInfo == nil {
+		authInfo = adminAuthInfo
+	}
+
+	server, err := getKubeConfigServer(cluster.Server, k.Server, master0, int(c.Kubeadm.LocalAPIEndpoint.BindPort))
+	if err != nil {
+		return err
+	}
+	if server != cluster.Server {
+		// the cert of apiserver is still verified with the original host, which is in the SANs of the cert
+		if host, err := getHost(cluster.Server); err == nil && net.ParseIP(host) == nil {
+			cluster.TLSServerName = host
+		}
+		cluster.Server = server
+	}
+
+	context := k.Context
+	if context == "" {
+		context = c.Kubeadm.ClusterName
+	}
+	user := context + "-admin"
+	if k.ClientName != "" {
+		user = context + "-" + k.ClientName
+	}
+
+	if err := mergeKubeConfig(k.Path, context, user, cluster, authInfo, k.UseContext); err != nil {
+		return errors.Wrapf(err, "[kubeconfig] Failed to merge kubeconfig into %s", k.Path)
+	}
+
+	fmt.Printf("[kubeconfig] merge context %q (server: %s) into %s: %s\n", context, server, k.Path, color.HiGreenString("done✅️"))
+	return nil
+}
+
+// newClientAuthInfo creates a short-lived client certificate signed by the CA.
+func newClientAuthInfo(ca *rundata.Cert, k rundata.Kubeconfig, keyAlgorithm pki.KeyAlgorithm) (*clientcmdapi.AuthInfo, error) {
+	client := &rundata.Cert{
+		Name:     k.ClientName,
+		BaseName: k.ClientName,
+		CAName:   ca.Name,
+		Config: pki.CertConfig{
+			Config: certutil.Config{
+				CommonName:   k.ClientName,
+				Organization: k.ClientGroups,
+				Usages:       []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			},
+			NotAfterTime: k.ClientCertTTL,
+			KeyAlgorithm: keyAlgorithm,
+		},
+	}
+
+	if err := client.CreateFromCA(nil, nil, ca.Cert, ca.Key); err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := pki.EncodePrivateKeyPEM(client.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &clientcmdapi.AuthInfo{
+		ClientCertificateData: pki.EncodeCertPEM(client.Cert),
+		ClientKeyData:         keyPEM,
+	}, nil
+}
+
+func getCurrentClusterAndAuthInfo(config *clientcmdapi.Config) (*clientcmdapi.Cluster, *clientcmdapi.AuthInfo, error) {
+	context, ok := config.Contexts[config.CurrentContext]
+	if !ok {
+		return nil, nil, errors.Errorf("[kubeconfig] current context %q not found in %s", config.CurrentContext, kubeadmconstants.AdminKubeConfigFileName)
+	}
+
+	cluster, ok := config.Clusters[context.Cluster]
+	if !ok {
+		return nil, nil, errors.Errorf("[kubeconfig] cluster %q not found in %s", context.Cluster, kubeadmconstants.AdminKubeConfigFileName)
+	}
+
+	authInfo, ok := config.AuthInfos[context.AuthInfo]
+	if !ok {
+		return nil, nil, errors.Errorf("[kubeconfig] user %q not found in %s", context.AuthInfo, kubeadmconstants.AdminKubeConfigFileName)
+	}
+
+	return cluster.DeepCopy(), authInfo.DeepCopy(), nil
+}
+
+// getKubeConfigServer returns the server set by the user if any.
+// Otherwise, if the host of the server in admin.conf can not be resolved from here, e.g. it is the default control plane endpoint
+// which is only resolved by /etc/hosts of the nodes, the apiserver of master0 is used.
+func getKubeConfigServer(server, userServer, master0 string, bindPort int) (string, error) {
+	if userServer != "" {
+		if _, err := getHost(userServer); err != nil {
+			return "", errors.Wrapf(err, "[kubeconfig] invalid server %q", userServer)
+		}
+		return userServer, nil
+	}
+
+	host, err := getHost(server)
+	if err != nil {
+		return "", errors.Wrapf(err, "[kubeconfig] invalid server %q in %s", server, kubeadmconstants.AdminKubeConfigFileName)
+	}
+	if net.ParseIP(host) != nil {
+		return server, nil
+	}
+	if _, err := net.LookupHost(host); err == nil {
+		return server, nil
+	}
+
+	klog.V(2).Infof("[kubeconfig] %s can not be resolved, use the apiserver of master0 %s instead", host, master0)
+	return "https://" + net.JoinHostPort(master0, strconv.Itoa(bindPort)), nil
+}
+
+func getHost(server string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "https" || u.Hostname() == "" {
+		return "", errors.New("server must be an https URL")
+	}
+	return u.Hostname(), nil
+}
+
+// mergeKubeConfig merges the cluster and the user into the kubeconfig file under the context.
+// The current context is switched to the context only if useContext is set or there is no current context,
+// so that the kubectl commands against another cluster are not redirected silently.
+func mergeKubeConfig(path, context, user string, cluster *clientcmdapi.Cluster, authInfo *clientcmdapi.AuthInfo, useContext bool) error {
+	config, err := clientcmd.LoadFromFile(path)
+	if os.IsNotExist(err) {
+		config = clientcmdapi.NewConfig()
+	} else if err != nil {
+		return err
+	}
+
+	config.Clusters[context] = cluster
+	config.AuthInfos[user] = authInfo
+	config.Contexts[context] = &clientcmdapi.Context{
+		Cluster:  context,
+		AuthInfo: user,
+	}
+	if useContext || config.CurrentContext == "" {
+		config.CurrentContext = context
+	} else if config.CurrentContext != context {
+		klog.Infof("[kubeconfig] The current context is still %q, run \"kubectl config use-context %s\" to switch to the cluster",
+			config.CurrentContext, context)
+	}
+
+	return clientcmd.WriteToFile(*config, path)
+}
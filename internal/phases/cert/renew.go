@@ -0,0 +1,161 @@
+package cert
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+
+	"github.com/yuyicai/kubei/internal/constants"
+	"github.com/yuyicai/kubei/internal/operator"
+	kubeadmphases "github.com/yuyicai/kubei/internal/phases/kubeadm"
+	"github.com/yuyicai/kubei/internal/phases/system"
+	"github.com/yuyicai/kubei/internal/rundata"
+	"github.com/yuyicai/kubei/internal/tmpl"
+	"github.com/yuyicai/kubei/pkg/pki"
+)
+
+// RenewCert re-signs the certificates and kubeconfig files of the masters with the existing CAs,
+// then sends them to the masters and restarts the control plane and kubelet one master at a time.
+func RenewCert(c *rundata.Cluster) error {
+	color.HiBlue("Renewing certificates for kubernetes and etcd 📘")
+
+	certTree, err := loadCA(c)
+	if err != nil {
+		return err
+	}
+
+	certNotAfterTime := constants.Year * time.Duration(c.CertNotAfterTime)
+
+	if err := operator.RunOnMasters(c, func(node *rundata.Node, c *rundata.Cluster) error {
+		klog.V(2).Infof("[%s] [cert] Renewing certificate", node.HostInfo.Host)
+		klog.V(3).Infof("[%s] [cert] The cert not after time is %v", node.HostInfo.Host, certNotAfterTime)
+		if err := CreatePKIAssets(node, &c.Kubeadm.InitConfiguration, certNotAfterTime, pki.KeyAlgorithm(c.CertKeyAlgorithm), certTree); err != nil {
+			return err
+		}
+
+		return node.CertificateTree.CreateKubeConfig(&c.Kubeadm.InitConfiguration)
+	}); err != nil {
+		return err
+	}
+
+	return operator.RunOnMastersOneByOne(c, func(node *rundata.Node, c *rundata.Cluster) error {
+		if err := sendCertAndKubeConfig(node); err != nil {
+			return errors.Wrapf(err, "[%s] [cert] Failed to send certificates", node.HostInfo.Host)
+		}
+
+		if err := restartControlPlane(node, int(c.Kubeadm.LocalAPIEndpoint.BindPort)); err != nil {
+			return err
+		}
+
+		// kubelet reads its client certificate in kubelet.conf only when it starts
+		if err := system.Restart("kubelet", node); err != nil {
+			return err
+		}
+
+		if err := kubeadmphases.CopyAdminConfig(node); err != nil {
+			return err
+		}
+
+		fmt.Printf("[%s] [cert] renew certificates: %s\n", node.HostInfo.Host, color.HiGreenString("done✅️"))
+		return nil
+	})
+}
+
+// loadCA loads the CAs from the local certificates dir if it is set, otherwise from master0.
+func loadCA(c *rundata.Cluster) (rundata.CertificateTree, error) {
+	certTree := rundata.CertificateTree{}
+
+	if c.CertificatesDir != "" {
+		klog.V(2).Infof("[cert] Loading CA from %s", c.CertificatesDir)
+		for _, ca := range rundata.GetDefaultCAList() {
+			if err := loadCAFromDir(ca, c.CertificatesDir); err != nil {
+				return nil, err
+			}
+			if err := validateCA(ca, constants.Year*time.Duration(c.CertNotAfterTime)); err != nil {
+				return nil, errors.Wrapf(err, "invalid %q CA in %s", ca.Name, c.CertificatesDir)
+			}
+			certTree[ca] = rundata.Certificates{}
+		}
+		return certTree, nil
+	}
+
+	if err := operator.RunOnFirstMaster(c, func(node *rundata.Node, c *rundata.Cluster) error {
+		klog.V(2).Infof("[%s] [cert] Fetching CA from master0", node.HostInfo.Host)
+		for _, ca := range rundata.GetDefaultCAList() {
+			if err := fetchCA(node, ca); err != nil {
+				return errors.Wrapf(err, "[%s] [cert] Failed to fetch %q CA", node.HostInfo.Host, ca.Name)
+			}
+			certTree[ca] = rundata.Certificates{}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return certTree, nil
+}
+
+func loadCAFromDir(ca *rundata.Cert, dir string) error {
+	certPEM, err := ioutil.ReadFile(filepath.Join(dir, ca.BaseName+".crt"))
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %q CA certificate", ca.Name)
+	}
+
+	keyPEM, err := ioutil.ReadFile(filepath.Join(dir, ca.BaseName+".key"))
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %q CA key", ca.Name)
+	}
+
+	return setCA(ca, certPEM, keyPEM)
+}
+
+func fetchCA(node *rundata.Node, ca *rundata.Cert) error {
+	certPEM, err := node.RunOut(fmt.Sprintf("cat /etc/kubernetes/pki/%s.crt", ca.BaseName))
+	if err != nil {
+		return err
+	}
+
+	keyPEM, err := node.RunOut(fmt.Sprintf("cat /etc/kubernetes/pki/%s.key", ca.BaseName))
+	if err != nil {
+		return err
+	}
+
+	return setCA(ca, certPEM, keyPEM)
+}
+
+func setCA(ca *rundata.Cert, certPEM, keyPEM []byte) error {
+	var err error
+	ca.Cert, err = pki.ParseCertPEM(certPEM)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse %q CA certificate", ca.Name)
+	}
+
+	ca.Key, err = pki.ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse %q CA key", ca.Name)
+	}
+	return nil
+}
+
+func restartControlPlane(node *rundata.Node, bindPort int) error {
+	klog.V(2).Infof("[%s] [restart] Restart the control plane static pods", node.HostInfo.Host)
+	if err := node.Run(tmpl.RestartControlPlane()); err != nil {
+		return fmt.Errorf("[%s] [restart] Failed to restart the control plane: %v", node.HostInfo.Host, err)
+	}
+
+	klog.V(2).Infof("[%s] [restart] Waiting for the control plane to become healthy. This can take up to %v", node.HostInfo.Host, constants.DefaultControlPlaneTimeout)
+	url := fmt.Sprintf("https://%s:%d/healthz", constants.LoopbackAddress, bindPort)
+	if err := wait.PollImmediate(constants.DefaultControlPlaneInterval, constants.DefaultControlPlaneTimeout, func() (done bool, err error) {
+		output, _ := node.RunOut(fmt.Sprintf("curl -sk %s", url))
+		return string(output) == "ok", nil
+	}); err != nil {
+		return fmt.Errorf("[%s] [restart] The control plane did not become healthy: %v", node.HostInfo.Host, err)
+	}
+	return nil
+}
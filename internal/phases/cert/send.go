@@ -1,14 +1,19 @@
 package cert
 
 import (
-	"crypto/x509"
 	"encoding/base64"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	"github.com/fatih/color"
+	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	clientcmdlatest "k8s.io/client-go/tools/clientcmd/api/latest"
+	"k8s.io/klog"
+	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
 
 	"github.com/yuyicai/kubei/internal/operator"
 	"github.com/yuyicai/kubei/internal/rundata"
@@ -16,7 +21,7 @@ import (
 )
 
 func SendCert(c *rundata.Cluster) error {
-	encodedPrivatKey, encodedPublicKey, err := CreateEncodeServiceAccountKeyAndPublicKey(x509.RSA)
+	encodedPrivatKey, encodedPublicKey, err := getEncodeServiceAccountKeyAndPublicKey(c)
 	if err != nil {
 		return err
 	}
@@ -39,6 +44,32 @@ func SendCert(c *rundata.Cluster) error {
 
 }
 
+// getEncodeServiceAccountKeyAndPublicKey loads the service account key from the certificates dir if it is there,
+// e.g. the dir is a backup of the cluster, otherwise creates a new one.
+func getEncodeServiceAccountKeyAndPublicKey(c *rundata.Cluster) ([]byte, []byte, error) {
+	if c.CertificatesDir != "" {
+		keyPEM, err := ioutil.ReadFile(filepath.Join(c.CertificatesDir, kubeadmconstants.ServiceAccountPrivateKeyName))
+		switch {
+		case os.IsNotExist(err):
+			klog.V(2).Infof("[cert] Service account key not found in %s, it will be created", c.CertificatesDir)
+		case err != nil:
+			return nil, nil, errors.Wrap(err, "failed to read service account key")
+		default:
+			key, err := pki.ParsePrivateKeyPEM(keyPEM)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "failed to parse service account key")
+			}
+			publicKeyPEM, err := pki.EncodePublicKeyPEM(key.Public())
+			if err != nil {
+				return nil, nil, err
+			}
+			return keyPEM, publicKeyPEM, nil
+		}
+	}
+
+	return CreateEncodeServiceAccountKeyAndPublicKey(pki.KeyAlgorithm(c.CertKeyAlgorithm))
+}
+
 func sendCertAndKubeConfig(node *rundata.Node) error {
 
 	if err := node.Run("mkdir -p /etc/kubernetes/pki/etcd"); err != nil {
@@ -77,6 +108,11 @@ func sendCert(node *rundata.Node, c *rundata.Cert) error {
 		return err
 	}
 
+	// the key of an external CA is not provided
+	if c.Key == nil {
+		return nil
+	}
+
 	// send key
 	encodedKey, err := pki.EncodePrivateKeyPEM(c.Key)
 	if err != nil {
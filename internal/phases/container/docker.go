@@ -6,6 +6,7 @@ import (
 	"github.com/fatih/color"
 	"k8s.io/klog"
 
+	"github.com/yuyicai/kubei/internal/constants"
 	"github.com/yuyicai/kubei/internal/operator"
 	"github.com/yuyicai/kubei/internal/phases/system"
 	"github.com/yuyicai/kubei/internal/rundata"
@@ -16,11 +17,29 @@ func InstallDocker(c *rundata.Cluster) error {
 
 	color.HiBlue("Installing Docker on all nodes 🐳")
 	return operator.RunOnAllNodes(c, func(node *rundata.Node, c *rundata.Cluster) error {
+		// the proxy is needed by apt and yum to install docker, and by docker to pull the images
+		if c.Proxy.Enabled() {
+			if err := system.SetProxy(node, c.Proxy, c.NoProxy()); err != nil {
+				return err
+			}
+		}
+
+		files := append([]string{constants.DockerDaemonJSON, constants.KubeletDockerConfigFile,
+			constants.RootDockerConfigFile, constants.ContainerdConfigFile}, tmpl.DockerRepoFiles()...)
+		if err := system.Journal(node, files...); err != nil {
+			return err
+		}
+
 		klog.V(2).Infof("[%s] [container-engine] Installing Docker", node.HostInfo.Host)
-		if err := installDocker(node, c.ContainerEngine.Docker); err != nil {
+		if err := installDocker(node, c.ContainerEngine.Docker, c.ContainerEngine.Registries, c.PackageRepos.Docker); err != nil {
 			return fmt.Errorf("[%s] [container-engine] Failed to install Docker: %v", node.HostInfo.Host, err)
 		}
 
+		klog.V(2).Infof("[%s] [container-engine] Configuring the registries", node.HostInfo.Host)
+		if err := setupRegistries(node, c.ContainerEngine.Registries); err != nil {
+			return fmt.Errorf("[%s] [container-engine] Failed to configure the registries: %v", node.HostInfo.Host, err)
+		}
+
 		if err := system.Restart("docker", node); err != nil {
 			return err
 		}
@@ -29,9 +48,9 @@ func InstallDocker(c *rundata.Cluster) error {
 	})
 }
 
-func installDocker(node *rundata.Node, d rundata.Docker) error {
-	cmdTmpl := tmpl.NewContainerEngineText(node.PackageManagementType)
-	cmd, err := cmdTmpl.Docker(node.InstallType, d)
+func installDocker(node *rundata.Node, d rundata.Docker, r rundata.Registries, repo rundata.PackageRepo) error {
+	cmdTmpl := tmpl.NewContainerEngineText(node.OS)
+	cmd, err := cmdTmpl.Docker(node.InstallType, d, r, repo)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,65 @@
+package container
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/yuyicai/kubei/internal/phases/system"
+	"github.com/yuyicai/kubei/internal/rundata"
+	"github.com/yuyicai/kubei/internal/tmpl"
+)
+
+// setupRegistries writes the CAs, the containerd hosts.toml and the kubelet credentials of the registries to the node,
+// the mirrors and the insecure registries of docker are in daemon.json written when installing docker.
+func setupRegistries(node *rundata.Node, r rundata.Registries) error {
+	cas, err := readRegistryCAs(r.CAs)
+	if err != nil {
+		return err
+	}
+
+	if r.Auths, err = readRegistryAuths(r.AuthFiles); err != nil {
+		return err
+	}
+
+	cmd, err := tmpl.Registries(r, cas)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(cmd) == "" {
+		return nil
+	}
+
+	if files := tmpl.RegistryFiles(r, cas); len(files) > 0 {
+		if err := system.Journal(node, files...); err != nil {
+			return err
+		}
+	}
+
+	return node.Run(cmd)
+}
+
+func readRegistryAuths(files map[string]string) (map[string]rundata.RegistryAuth, error) {
+	auths := map[string]rundata.RegistryAuth{}
+	for registry, file := range files {
+		auth, err := rundata.ReadRegistryAuth(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read credentials of registry %s", registry)
+		}
+		auths[registry] = auth
+	}
+	return auths, nil
+}
+
+func readRegistryCAs(files map[string]string) (map[string]string, error) {
+	cas := map[string]string{}
+	for registry, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read CA of registry %s", registry)
+		}
+		cas[registry] = string(data)
+	}
+	return cas, nil
+}
@@ -2,36 +2,83 @@ package download
 
 import (
 	"fmt"
-	"github.com/fatih/color"
-	"path"
 	"path/filepath"
+	"strings"
 
+	"github.com/fatih/color"
 	"github.com/mitchellh/go-homedir"
 
+	"github.com/yuyicai/kubei/internal/phases/offline"
+	"github.com/yuyicai/kubei/internal/rundata"
 	"github.com/yuyicai/kubei/pkg/registry"
 )
 
-const (
-	Registry   = "registry.aliyuncs.com"
-	Repository = "kubebin"
-	ImageName  = "kube-files"
-)
+// Download downloads the offline files, or the images if --images is set, of the Kubernetes version.
+func Download(c *rundata.Cluster) error {
+	if c.Download.Images {
+		return Images(c)
+	}
+	return KubeFiles(c)
+}
 
-func KubeFiles(tag, destPath string) error {
-	imageUrl := fmt.Sprintf("%s:%s", path.Join(Registry, Repository, ImageName), tag)
+// KubeFiles downloads the offline files of the Kubernetes version, which are packed in an image of the registry.
+func KubeFiles(c *rundata.Cluster) error {
+	tag := "v" + c.Kubernetes.Version
+	imageUrl := fmt.Sprintf("%s/%s:%s", strings.TrimSuffix(c.Download.Registry, "/"), c.Download.Repository, tag)
 
-	home, err := homedir.Dir()
+	destPath, err := getDestPath(c.Download.Dest, tag)
 	if err != nil {
 		return err
 	}
-	if destPath == "" {
-		destPath = filepath.Join(home, ".kubei", tag)
+
+	color.HiBlack("Downloading %s to %s", imageUrl, destPath)
+	if err := registry.DownloadFile(imageUrl, c.Download.RegistryOptions(), destPath); err != nil {
+		return err
 	}
+	color.HiGreen("done✅️")
+	return nil
+}
+
+// Images downloads the images needed by the Kubernetes version into the images/master, images/node
+// and images/registry dirs, the same as the images of the offline package.
+func Images(c *rundata.Cluster) error {
+	tag := "v" + c.Kubernetes.Version
+	bom := offline.DefaultBOM(tag, c)
 
-	color.HiBlack("Downloading %s.tar.gz to %s", ImageName, destPath)
-	if err := registry.DownloadFile(imageUrl, "", "", destPath); err != nil {
+	destPath, err := getDestPath(c.Download.Dest, tag)
+	if err != nil {
 		return err
 	}
+
+	for _, nodeType := range []string{"master", "node", "registry"} {
+		images := bom.Images.Master
+		switch nodeType {
+		case "node":
+			images = bom.Images.Node
+		case "registry":
+			images = bom.Images.Registry
+		}
+
+		dir := filepath.Join(destPath, "images", nodeType)
+		for _, image := range images {
+			color.HiBlack("Downloading %s to %s", image, dir)
+			if err := registry.DownloadImage(image, c.Download.RegistryOptions(), dir); err != nil {
+				return err
+			}
+		}
+	}
 	color.HiGreen("done✅️")
 	return nil
 }
+
+func getDestPath(dest, tag string) (string, error) {
+	if dest != "" {
+		return dest, nil
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".kubei", tag), nil
+}
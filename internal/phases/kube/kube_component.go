@@ -15,8 +15,12 @@ import (
 func InstallKubeComponent(c *rundata.Cluster) error {
 	color.HiBlue("Installing Kubernetes component ☸️")
 	return operator.RunOnAllNodes(c, func(node *rundata.Node, c *rundata.Cluster) error {
+		if err := system.Journal(node, tmpl.KubeRepoFiles()...); err != nil {
+			return err
+		}
+
 		klog.V(2).Infof("[%s] [kube] Installing Kubernetes component", node.HostInfo.Host)
-		if err := installKubeComponent(c.Kubernetes.Version, node); err != nil {
+		if err := installKubeComponent(c.Kubernetes.Version, c.PackageRepos.Kubernetes, node); err != nil {
 			return fmt.Errorf("[%s] [kube] Failed to install Kubernetes component: %v", node.HostInfo.Host, err)
 		}
 
@@ -28,10 +32,10 @@ func InstallKubeComponent(c *rundata.Cluster) error {
 	})
 }
 
-func installKubeComponent(version string, node *rundata.Node) error {
+func installKubeComponent(version string, repo rundata.PackageRepo, node *rundata.Node) error {
 
-	cmdTmpl := tmpl.NewKubeText(node.PackageManagementType)
-	cmd, err := cmdTmpl.KubeComponent(version, node.InstallType)
+	cmdTmpl := tmpl.NewKubeText(node.OS)
+	cmd, err := cmdTmpl.KubeComponent(version, node.InstallType, repo)
 	if err != nil {
 		return err
 	}
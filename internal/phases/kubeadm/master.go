@@ -15,6 +15,13 @@ import (
 	"github.com/yuyicai/kubei/internal/tmpl"
 )
 
+// dryRunToken is the token of the join commands recorded with --dry-run
+var dryRunToken = rundata.Token{
+	Token:          "<token>",
+	CaCertHash:     "<ca-cert-hash>",
+	CertificateKey: "<certificate-key>",
+}
+
 // InitMaster init master0
 func InitMaster(c *rundata.Cluster) error {
 	color.HiBlue("Initializing master0 ☸️")
@@ -39,12 +46,18 @@ func InitMaster(c *rundata.Cluster) error {
 			return err
 		}
 
-		if err := copyAdminConfig(node); err != nil {
+		if err := CopyAdminConfig(node); err != nil {
 			return err
 		}
 
 		fmt.Printf("[%s] [kubeadm-init] init master0: %s\n", node.HostInfo.Host, color.HiGreenString("done✅️"))
 
+		// there is no output of kubeadm init with --dry-run, the join commands are recorded with placeholders
+		if node.DryRun() {
+			c.Kubernetes.Token = dryRunToken
+			return nil
+		}
+
 		klog.V(2).Infof("[%s] [token] Getting token from master init output", node.HostInfo.Host)
 		getToken(string(output), &c.Kubernetes.Token)
 
@@ -88,7 +101,7 @@ func JoinControlPlane(c *rundata.Cluster) error {
 
 		fmt.Printf("[%s] [kubeadm-join] join to masters: %s\n", node.HostInfo.Host, color.HiGreenString("done✅️"))
 
-		if err := copyAdminConfig(node); err != nil {
+		if err := CopyAdminConfig(node); err != nil {
 			return err
 		}
 
@@ -124,7 +137,8 @@ func getToken(str string, token *rundata.Token) {
 	}
 }
 
-func copyAdminConfig(node *rundata.Node) error {
+// CopyAdminConfig copies admin.conf to $HOME/.kube/config on the node
+func CopyAdminConfig(node *rundata.Node) error {
 	klog.V(2).Infof("[%s] [kubectl-config] Copy admin.conf to $HOME/.kube/config", node.HostInfo.Host)
 	if err := node.Run(tmpl.CopyAdminConfig()); err != nil {
 		return fmt.Errorf("[%s] [kubectl-config] Failed to copy admin.conf to $HOME/.kube/config: %v", node.HostInfo.Host, err)
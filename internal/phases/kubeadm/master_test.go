@@ -0,0 +1,221 @@
+package kubeadm
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yuyicai/kubei/internal/constants"
+	"github.com/yuyicai/kubei/internal/rundata"
+	"github.com/yuyicai/kubei/internal/tmpl"
+	"github.com/yuyicai/kubei/pkg/ssh"
+	"github.com/yuyicai/kubei/pkg/ssh/sshtest"
+)
+
+const (
+	testToken          = "abcdef.0123456789abcdef"
+	testCaCertHash     = "8cb2de97839780a412b93877f8507ad6c94f73add17d5d7058e91741c9d5ec78"
+	testCertificateKey = "f8902e114ef118304e561c3ecd4d0b543adc226b7a07f675f56564185ffe0c07"
+)
+
+// initOutput is the end of the output of kubeadm init
+var initOutput = fmt.Sprintf(`Your Kubernetes control-plane has initialized successfully!
+
+You can now join any number of the control-plane node running the following command on each as root:
+
+  kubeadm join k8s.api:6443 --token %s \
+    --discovery-token-ca-cert-hash sha256:%s \
+    --control-plane --certificate-key %s
+
+Then you can join any number of worker nodes by running the following on each as root:
+
+kubeadm join k8s.api:6443 --token %s \
+    --discovery-token-ca-cert-hash sha256:%s
+`, testToken, testCaCertHash, testCertificateKey, testToken, testCaCertHash)
+
+func newNode(t *testing.T, s *sshtest.Server, name, host string) *rundata.Node {
+	client, err := ssh.Connect(s.Host(), s.Port(), s.User, s.Password, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return &rundata.Node{
+		Name:     name,
+		Executor: client,
+		HostInfo: rundata.HostInfo{Host: host, Port: s.Port(), User: s.User, Password: s.Password},
+	}
+}
+
+// newCluster returns a cluster of the masters and the workers, each node is a sshtest server
+// and the ip of the nodes are 10.0.0.1, 10.0.0.2 ... in order.
+func newCluster(t *testing.T, masters, workers int, user string) (*rundata.Cluster, []*sshtest.Server) {
+	c := rundata.NewCluster()
+	c.Kubeadm.ControlPlaneEndpoint = "k8s.api:6443"
+	c.Kubeadm.ImageRepository = "registry.aliyuncs.com/k8sxio"
+	c.Kubeadm.Networking.PodSubnet = "10.244.0.0/16"
+	c.Kubeadm.Networking.ServiceSubnet = "10.96.0.0/12"
+	c.Kubei.HA.Type = constants.HATypeNone
+
+	var servers []*sshtest.Server
+	for i := 0; i < masters+workers; i++ {
+		s := sshtest.NewServer(t, user, "secret")
+		servers = append(servers, s)
+
+		host := fmt.Sprintf("10.0.0.%d", i+1)
+		if i < masters {
+			c.ClusterNodes.Masters = append(c.ClusterNodes.Masters, newNode(t, s, fmt.Sprintf("master%d", i), host))
+		} else {
+			c.ClusterNodes.Workers = append(c.ClusterNodes.Workers, newNode(t, s, fmt.Sprintf("node%d", i-masters), host))
+		}
+	}
+	return c, servers
+}
+
+func journal(t *testing.T, files ...string) string {
+	cmd, err := tmpl.Journal(files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+func kubeadmCmd(t *testing.T, name, nodeName string, c *rundata.Cluster) string {
+	cmd, err := tmpl.Kubeadm(name, nodeName, c.Kubernetes, *c.Kubeadm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+func TestInitMaster(t *testing.T) {
+	c, servers := newCluster(t, 1, 0, "ubuntu")
+	servers[0].Reply(`kubeadm init`, initOutput, 0)
+
+	if err := InitMaster(c); err != nil {
+		t.Fatal(err)
+	}
+
+	want := rundata.Token{Token: testToken, CaCertHash: testCaCertHash, CertificateKey: testCertificateKey}
+	if c.Kubernetes.Token != want {
+		t.Errorf("token = %+v, want %+v", c.Kubernetes.Token, want)
+	}
+
+	wantScripts := []string{
+		journal(t, constants.HostsFile),
+		tmpl.SetHosts(constants.LoopbackAddress, "k8s.api"),
+		journal(t, constants.FstabFile),
+		tmpl.SwapOff(),
+		journal(t, constants.K8sSysctlConf),
+		tmpl.Iptables(),
+		kubeadmCmd(t, tmpl.Init, "master0", c),
+		tmpl.CopyAdminConfig(),
+		tmpl.ChownKubectlConfig(),
+	}
+	if scripts := servers[0].Scripts(); !reflect.DeepEqual(scripts, wantScripts) {
+		t.Errorf("scripts = %q, want %q", scripts, wantScripts)
+	}
+}
+
+func TestInitMasterFailed(t *testing.T) {
+	c, servers := newCluster(t, 1, 0, "root")
+	servers[0].Reply(`kubeadm init`, "", 1)
+
+	err := InitMaster(c)
+	if err == nil || !strings.Contains(err.Error(), "[10.0.0.1] [kubeadm-init] Failed to Initialize master0") {
+		t.Fatalf("InitMaster() = %v, want the init error of 10.0.0.1", err)
+	}
+	if c.Kubernetes.Token != (rundata.Token{}) {
+		t.Errorf("token = %+v, want empty", c.Kubernetes.Token)
+	}
+	// admin.conf is not copied without a control plane
+	for _, script := range servers[0].Scripts() {
+		if script == tmpl.CopyAdminConfig() {
+			t.Error("admin.conf is copied after kubeadm init failed")
+		}
+	}
+}
+
+func TestJoinControlPlane(t *testing.T) {
+	c, servers := newCluster(t, 3, 0, "root")
+	c.Kubernetes.Token = rundata.Token{Token: testToken, CaCertHash: testCaCertHash, CertificateKey: testCertificateKey}
+
+	if err := JoinControlPlane(c); err != nil {
+		t.Fatal(err)
+	}
+
+	// master0 is initialized by InitMaster
+	if scripts := servers[0].Scripts(); len(scripts) != 0 {
+		t.Errorf("scripts of master0 = %q, want none", scripts)
+	}
+	for i, s := range servers[1:] {
+		name := fmt.Sprintf("master%d", i+1)
+		// the api server is reached through master0 until the control plane of the node is up
+		want := []string{
+			journal(t, constants.HostsFile),
+			tmpl.SetHosts("10.0.0.1", "k8s.api"),
+			journal(t, constants.FstabFile),
+			tmpl.SwapOff(),
+			journal(t, constants.K8sSysctlConf),
+			tmpl.Iptables(),
+			kubeadmCmd(t, tmpl.JoinControlPlane, name, c),
+			tmpl.CopyAdminConfig(),
+			journal(t, constants.HostsFile),
+			tmpl.SetHosts(constants.LoopbackAddress, "k8s.api"),
+		}
+		if scripts := s.Scripts(); !reflect.DeepEqual(scripts, want) {
+			t.Errorf("scripts of %s = %q, want %q", name, scripts, want)
+		}
+		if !strings.Contains(want[6], "--certificate-key "+testCertificateKey) {
+			t.Errorf("the join command of %s = %q, want the certificate key", name, want[6])
+		}
+	}
+}
+
+func TestJoinDryRun(t *testing.T) {
+	c := rundata.NewCluster()
+	c.Kubeadm.ControlPlaneEndpoint = "k8s.api:6443"
+	c.Kubei.HA.Type = constants.HATypeNone
+
+	var out bytes.Buffer
+	for i := 0; i < 3; i++ {
+		host := fmt.Sprintf("10.0.0.%d", i+1)
+		node := &rundata.Node{HostInfo: rundata.HostInfo{Host: host, User: "root"}, Executor: rundata.NewRecorder(host, "", &out)}
+		if i < 2 {
+			node.Name = fmt.Sprintf("master%d", i)
+			c.ClusterNodes.Masters = append(c.ClusterNodes.Masters, node)
+		} else {
+			node.Name = "node0"
+			c.ClusterNodes.Workers = append(c.ClusterNodes.Workers, node)
+		}
+	}
+
+	if err := InitMaster(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := JoinControlPlane(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := JoinNode(c); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		kubeadmCmd(t, tmpl.JoinControlPlane, "master1", c),
+		kubeadmCmd(t, tmpl.JoinNode, "node0", c),
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("the recorded scripts don't contain %q", want)
+		}
+		for _, placeholder := range []string{"--token <token>", "sha256:<ca-cert-hash>"} {
+			if !strings.Contains(want, placeholder) {
+				t.Errorf("the join command %q doesn't contain %q", want, placeholder)
+			}
+		}
+	}
+	if join := kubeadmCmd(t, tmpl.JoinControlPlane, "master1", c); !strings.Contains(join, "--certificate-key <certificate-key>") {
+		t.Errorf("the join command %q doesn't contain the placeholder of the certificate key", join)
+	}
+}
@@ -93,6 +93,10 @@ func nginx(node *rundata.Node, n *rundata.Nginx, masters []string, kcfg *rundata
 		return err
 	}
 
+	if err := system.Journal(node, constants.KubeletHADropIn); err != nil {
+		return err
+	}
+
 	if err := node.Run(tmpl.KubeletUnitFile(fmt.Sprintf("%s/%s", kcfg.ImageRepository, "pause:3.1"))); err != nil {
 		return err
 	}
@@ -116,6 +120,9 @@ func nginx(node *rundata.Node, n *rundata.Nginx, masters []string, kcfg *rundata
 }
 
 func checkHealth(node *rundata.Node, url string, interval, timeout time.Duration) error {
+	if node.DryRun() {
+		return node.Run(fmt.Sprintf("curl -k %s", url))
+	}
 	return wait.PollImmediate(interval, timeout, func() (done bool, err error) {
 		var output []byte
 		output, _ = node.RunOut(fmt.Sprintf("curl -k %s", url))
@@ -128,6 +135,10 @@ func checkHealth(node *rundata.Node, url string, interval, timeout time.Duration
 }
 
 func iptables(node *rundata.Node) error {
+	if err := system.Journal(node, constants.K8sSysctlConf); err != nil {
+		return err
+	}
+
 	klog.V(2).Infof("[%s] [iptables] set up iptables", node.HostInfo.Host)
 	if err := node.Run(tmpl.Iptables()); err != nil {
 		return fmt.Errorf("[%s] [iptables] Failed set up iptables: %v", node.HostInfo.Host, err)
@@ -167,6 +178,10 @@ func CheckNodesReady(c *rundata.Cluster) error {
 }
 
 func checkNodesReady(node *rundata.Node, nodes []*rundata.Node, interval, timeout time.Duration) (string, error) {
+	if node.DryRun() {
+		return "", node.Run("kubectl get nodes -owide")
+	}
+
 	var str string
 	color.HiBlue("Waiting for all nodes to become ready. This can take up to %v⏳\n", timeout)
 	if err := wait.PollImmediate(interval, timeout, func() (done bool, err error) {
@@ -189,6 +204,10 @@ func checkNodesReady(node *rundata.Node, nodes []*rundata.Node, interval, timeou
 }
 
 func checkNodesWithNotNetWorkPlugin(node *rundata.Node, nodes []*rundata.Node, interval, timeout time.Duration) (string, error) {
+	if node.DryRun() {
+		return "", node.Run("kubectl get nodes -owide")
+	}
+
 	var str string
 	color.HiBlue("Waiting for all nodes join to Kubernetes cluster. This can take up to %v⏳\n", timeout)
 	if err := wait.PollImmediate(interval, timeout, func() (done bool, err error) {
@@ -208,6 +227,12 @@ func checkNodesWithNotNetWorkPlugin(node *rundata.Node, nodes []*rundata.Node, i
 }
 
 func LoadOfflineImages(c *rundata.Cluster) error {
+	// with the local registry, only master0 loads the images and the other nodes pull them from the registry
+	if c.LocalRegistry.Enable {
+		return operator.RunOnFirstMaster(c, func(node *rundata.Node, c *rundata.Cluster) error {
+			return loadLocalRegistryImages(node, c.LocalRegistry.Address())
+		})
+	}
 
 	g := errgroup.WithCancel(context.Background())
 	g.Go(func(ctx context.Context) error {
@@ -0,0 +1,60 @@
+package kubeadm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yuyicai/kubei/internal/constants"
+	"github.com/yuyicai/kubei/internal/rundata"
+	"github.com/yuyicai/kubei/internal/tmpl"
+)
+
+func TestJoinNode(t *testing.T) {
+	c, servers := newCluster(t, 1, 2, "root")
+	c.Kubernetes.Token = rundata.Token{Token: testToken, CaCertHash: testCaCertHash}
+
+	if err := JoinNode(c); err != nil {
+		t.Fatal(err)
+	}
+
+	if scripts := servers[0].Scripts(); len(scripts) != 0 {
+		t.Errorf("scripts of master0 = %q, want none", scripts)
+	}
+	for i, s := range servers[1:] {
+		node := c.ClusterNodes.Workers[i]
+		// without a load balancer the workers reach the api server on master0
+		want := []string{
+			journal(t, constants.FstabFile),
+			tmpl.SwapOff(),
+			journal(t, constants.K8sSysctlConf),
+			tmpl.Iptables(),
+			journal(t, constants.HostsFile),
+			tmpl.SetHosts("10.0.0.1", "k8s.api"),
+			kubeadmCmd(t, tmpl.JoinNode, node.Name, c),
+		}
+		if scripts := s.Scripts(); !reflect.DeepEqual(scripts, want) {
+			t.Errorf("scripts of %s = %q, want %q", node.Name, scripts, want)
+		}
+		if !strings.Contains(want[6], "--token "+testToken) {
+			t.Errorf("the join command of %s = %q, want the token", node.Name, want[6])
+		}
+	}
+}
+
+func TestJoinNodeFailed(t *testing.T) {
+	c, servers := newCluster(t, 1, 2, "root")
+	c.Kubernetes.Token = rundata.Token{Token: testToken, CaCertHash: testCaCertHash}
+	servers[2].Reply(`kubeadm join`, "", 1)
+
+	err := JoinNode(c)
+	if err == nil || !strings.Contains(err.Error(), "[10.0.0.3] Failed to join") {
+		t.Fatalf("JoinNode() = %v, want the join error of 10.0.0.3", err)
+	}
+
+	// the other worker is joined all the same
+	scripts := servers[1].Scripts()
+	if last := scripts[len(scripts)-1]; last != kubeadmCmd(t, tmpl.JoinNode, "node0", c) {
+		t.Errorf("the last script of node0 = %q, want kubeadm join", last)
+	}
+}
@@ -0,0 +1,77 @@
+package kubeadm
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"k8s.io/klog"
+
+	"github.com/yuyicai/kubei/internal/constants"
+	"github.com/yuyicai/kubei/internal/operator"
+	"github.com/yuyicai/kubei/internal/rundata"
+	"github.com/yuyicai/kubei/internal/tmpl"
+)
+
+// SeedLocalRegistry runs the local registry on master0 as a static Pod, and pushes the offline images to it,
+// it must be done before the other nodes join, they pull the images from the local registry.
+func SeedLocalRegistry(c *rundata.Cluster) error {
+	if !c.LocalRegistry.Enable {
+		return nil
+	}
+
+	color.HiBlue("Seeding the local registry 📦")
+	return operator.RunOnFirstMaster(c, func(node *rundata.Node, c *rundata.Cluster) error {
+		l := c.LocalRegistry
+
+		klog.V(2).Infof("[%s] [local-registry] Running the local registry as static Pod", node.HostInfo.Host)
+		if err := runLocalRegistry(node, l, false); err != nil {
+			return err
+		}
+
+		klog.V(2).Infof("[%s] [local-registry] Waiting for the local registry and pushing the images to it. This can take up to %v for the registry",
+			node.HostInfo.Host, constants.DefaultLocalRegistryWait)
+		cmd, err := tmpl.PushLocalRegistryImages(l.Address(), int(constants.DefaultLocalRegistryWait.Seconds()))
+		if err != nil {
+			return fmt.Errorf("[%s] [local-registry] Failed to render the push commands: %v", node.HostInfo.Host, err)
+		}
+		if err := node.Run(cmd); err != nil {
+			return fmt.Errorf("[%s] [local-registry] Failed to push the images to the local registry %s: %v", node.HostInfo.Host, l.Address(), err)
+		}
+
+		// the seeded images can't be overwritten by anyone reaching the registry
+		klog.V(2).Infof("[%s] [local-registry] Making the local registry read-only", node.HostInfo.Host)
+		if err := runLocalRegistry(node, l, true); err != nil {
+			return err
+		}
+
+		fmt.Printf("[%s] [local-registry] seed the local registry %s: %s\n", node.HostInfo.Host, l.Address(), color.HiGreenString("done✅️"))
+		return nil
+	})
+}
+
+func runLocalRegistry(node *rundata.Node, l rundata.LocalRegistry, readOnly bool) error {
+	cmd, err := tmpl.LocalRegistry(l.Image, l.Address(), readOnly)
+	if err != nil {
+		return fmt.Errorf("[%s] [local-registry] Failed to render the local registry manifest: %v", node.HostInfo.Host, err)
+	}
+	if err := node.Run(cmd); err != nil {
+		return fmt.Errorf("[%s] [local-registry] Failed to write the local registry manifest: %v", node.HostInfo.Host, err)
+	}
+	return nil
+}
+
+func loadLocalRegistryImages(node *rundata.Node, address string) error {
+	if node.InstallType != constants.InstallTypeOffline {
+		return nil
+	}
+
+	klog.V(2).Infof("[%s] [local-registry] Loading the offline images and tagging them with %s", node.HostInfo.Host, address)
+	cmd, err := tmpl.LoadLocalRegistryImages(address)
+	if err != nil {
+		return fmt.Errorf("[%s] [local-registry] Failed to render the load commands: %v", node.HostInfo.Host, err)
+	}
+	if err := node.Run(cmd); err != nil {
+		return fmt.Errorf("[%s] [local-registry] Failed to load the offline images: %v", node.HostInfo.Host, err)
+	}
+	return nil
+}
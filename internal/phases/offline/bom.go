@@ -0,0 +1,217 @@
+package offline
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+	kubeadmconstants "k8s.io/kubernetes/cmd/kubeadm/app/constants"
+	"k8s.io/kubernetes/cmd/kubeadm/app/images"
+	"sigs.k8s.io/yaml"
+
+	"github.com/yuyicai/kubei/internal/constants"
+	"github.com/yuyicai/kubei/internal/rundata"
+)
+
+var (
+	// the file names and the install dirs are used in the install scripts, so only the safe characters are allowed
+	fileNameRegexp  = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+	installToRegexp = regexp.MustCompile(`^/[A-Za-z0-9._/+-]*$`)
+	sha256Regexp    = regexp.MustCompile(`^[A-Fa-f0-9]{64}$`)
+)
+
+// GetBOM returns the bill of materials of the offline package,
+// it is loaded from the BOM file if it is set, otherwise the default one of the Kubernetes version is used.
+func GetBOM(c *rundata.Cluster) (*rundata.BOM, error) {
+	version := c.Kubernetes.Version
+	if version != "" {
+		version = "v" + version
+	}
+
+	var bom *rundata.BOM
+	if c.OfflineBuild.BOMFile != "" {
+		var err error
+		if bom, err = LoadBOM(c.OfflineBuild.BOMFile); err != nil {
+			return nil, err
+		}
+		switch {
+		case bom.KubernetesVersion == "":
+			bom.KubernetesVersion = version
+		case version != "" && bom.KubernetesVersion != version:
+			return nil, errors.Errorf("[offline] the Kubernetes version of the BOM %s is %s, but --kubernetes-version is %s",
+				c.OfflineBuild.BOMFile, bom.KubernetesVersion, version)
+		}
+		if len(bom.Archs) == 0 {
+			bom.Archs = c.OfflineBuild.Archs
+		}
+	} else {
+		if version == "" {
+			return nil, errors.New("[offline] --kubernetes-version is required if the BOM file is not set")
+		}
+		bom = DefaultBOM(version, c)
+	}
+
+	if err := ValidateBOM(bom); err != nil {
+		return nil, err
+	}
+	return bom, nil
+}
+
+// LoadBOM loads the bill of materials from a yaml or json file.
+func LoadBOM(file string) (*rundata.BOM, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[offline] failed to read BOM file %s", file)
+	}
+
+	bom := &rundata.BOM{}
+	if err := yaml.UnmarshalStrict(data, bom); err != nil {
+		return nil, errors.Wrapf(err, "[offline] failed to parse BOM file %s", file)
+	}
+	return bom, nil
+}
+
+// DefaultBOM returns the bill of materials with the static binaries of Kubernetes, CNI plugins, crictl and Docker
+// of each architecture of --archs, and the images used by kubeadm and kubei.
+func DefaultBOM(version string, c *rundata.Cluster) *rundata.BOM {
+	dockerVersion := c.ContainerEngine.Docker.Version
+	if dockerVersion == "" {
+		dockerVersion = constants.DefaultOfflineDockerVersion
+	}
+
+	bom := &rundata.BOM{
+		KubernetesVersion: version,
+		Archs:             c.OfflineBuild.Archs,
+	}
+
+	for _, arch := range bom.Archs {
+		bom.ContainerEngine = append(bom.ContainerEngine, rundata.BOMFile{
+			URL:             fmt.Sprintf("https://download.docker.com/linux/static/stable/%s/docker-%s.tgz", unameArch(arch), dockerVersion),
+			InstallTo:       "/usr/bin",
+			StripComponents: 1,
+			Arch:            arch,
+		})
+
+		for _, component := range []string{"kubeadm", "kubelet", "kubectl"} {
+			url := fmt.Sprintf("https://dl.k8s.io/release/%s/bin/linux/%s/%s", version, arch, component)
+			bom.Kube = append(bom.Kube, rundata.BOMFile{
+				URL:       url,
+				SHA256URL: url + ".sha256",
+				InstallTo: "/usr/bin",
+				Arch:      arch,
+			})
+		}
+
+		cniURL := fmt.Sprintf("https://github.com/containernetworking/plugins/releases/download/%s/cni-plugins-linux-%s-%s.tgz",
+			constants.DefaultOfflineCNIVersion, arch, constants.DefaultOfflineCNIVersion)
+		crictlURL := fmt.Sprintf("https://github.com/kubernetes-sigs/cri-tools/releases/download/%s/crictl-%s-linux-%s.tar.gz",
+			constants.DefaultOfflineCrictlVersion, constants.DefaultOfflineCrictlVersion, arch)
+		bom.Kube = append(bom.Kube,
+			rundata.BOMFile{URL: cniURL, SHA256URL: cniURL + ".sha256", InstallTo: "/opt/cni/bin", Arch: arch},
+			rundata.BOMFile{URL: crictlURL, SHA256URL: crictlURL + ".sha256", InstallTo: "/usr/bin", Arch: arch},
+		)
+	}
+
+	cfg := &kubeadmapi.ClusterConfiguration{
+		KubernetesVersion: version,
+		ImageRepository:   c.Kubeadm.ImageRepository,
+	}
+	if cfg.ImageRepository == "" {
+		cfg.ImageRepository = constants.DefaultImageRepository
+	}
+
+	bom.Images.Master = []string{
+		images.GetKubernetesImage(kubeadmconstants.KubeAPIServer, cfg),
+		images.GetKubernetesImage(kubeadmconstants.KubeControllerManager, cfg),
+		images.GetKubernetesImage(kubeadmconstants.KubeScheduler, cfg),
+		images.GetEtcdImage(cfg),
+	}
+	bom.Images.Node = []string{
+		images.GetKubernetesImage(kubeadmconstants.KubeProxy, cfg),
+		images.GetPauseImage(cfg),
+		images.GetDNSImage(cfg),
+		c.HA.LocalSLB.Nginx.Image.GetImage(),
+	}
+	if c.NetworkPlugins.Type == "flannel" {
+		bom.Images.Node = append(bom.Images.Node, c.NetworkPlugins.Flannel.Image.GetImage())
+	}
+	bom.Images.Registry = []string{constants.DefaultLocalRegistryImage}
+
+	return bom
+}
+
+// ValidateBOM checks that the bill of materials can be built into an offline package.
+func ValidateBOM(bom *rundata.BOM) error {
+	if bom.KubernetesVersion == "" {
+		return errors.New("[offline] the Kubernetes version of the BOM is required")
+	}
+
+	if len(bom.Archs) == 0 {
+		return errors.New("[offline] the architectures of the BOM are required")
+	}
+	archs := map[string]bool{}
+	for _, arch := range bom.Archs {
+		if !fileNameRegexp.MatchString(arch) {
+			return errors.Errorf("[offline] invalid architecture %q of BOM", arch)
+		}
+		archs[arch] = true
+	}
+
+	names := map[string]bool{}
+	for dir, files := range map[string][]rundata.BOMFile{"container_engine": bom.ContainerEngine, "kube": bom.Kube} {
+		for _, f := range files {
+			if !strings.HasPrefix(f.URL, "https://") && !strings.HasPrefix(f.URL, "http://") {
+				return errors.Errorf("[offline] invalid URL %q of BOM file: must be an http(s) URL", f.URL)
+			}
+
+			name := f.GetName()
+			if !fileNameRegexp.MatchString(name) {
+				return errors.Errorf("[offline] invalid name %q of BOM file %s", name, f.URL)
+			}
+			if f.Arch != "" && !archs[f.Arch] {
+				return errors.Errorf("[offline] the architecture %q of BOM file %s is not in the architectures of the BOM", f.Arch, name)
+			}
+			if names[path.Join(dir, f.Path())] {
+				return errors.Errorf("[offline] duplicate BOM file %s", path.Join(dir, f.Path()))
+			}
+			names[path.Join(dir, f.Path())] = true
+
+			if f.SHA256 != "" && !sha256Regexp.MatchString(f.SHA256) {
+				return errors.Errorf("[offline] invalid sha256 %q of BOM file %s", f.SHA256, name)
+			}
+
+			kind := f.Kind()
+			if kind == rundata.BOMFileKindDeb || kind == rundata.BOMFileKindRpm {
+				continue
+			}
+			if !installToRegexp.MatchString(f.InstallTo) {
+				return errors.Errorf("[offline] invalid installTo %q of BOM file %s: must be an absolute path", f.InstallTo, name)
+			}
+			if f.StripComponents < 0 {
+				return errors.Errorf("[offline] invalid stripComponents %d of BOM file %s", f.StripComponents, name)
+			}
+		}
+	}
+
+	for _, image := range bom.Images.All() {
+		if image == "" || strings.ContainsAny(image, " \t\n") {
+			return errors.Errorf("[offline] invalid image %q of BOM", image)
+		}
+	}
+	return nil
+}
+
+// unameArch returns the architecture in the form of uname -m, which is used by the docker static binaries.
+func unameArch(arch string) string {
+	switch arch {
+	case constants.ArchAMD64:
+		return "x86_64"
+	case constants.ArchARM64:
+		return "aarch64"
+	}
+	return arch
+}
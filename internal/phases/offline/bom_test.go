@@ -0,0 +1,146 @@
+package offline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yuyicai/kubei/internal/rundata"
+)
+
+func TestGetBOM(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubei-bom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bomFile := filepath.Join(dir, "bom.yaml")
+	if err := ioutil.WriteFile(bomFile, []byte(`
+kubernetesVersion: v1.22.4
+containerEngine:
+- url: https://example.com/docker-ce_20.10.11_amd64.deb
+kube:
+- url: https://example.com/kubeadm
+  sha256: 0000000000000000000000000000000000000000000000000000000000000000
+  installTo: /usr/bin
+images:
+  master:
+  - k8s.gcr.io/kube-apiserver:v1.22.4
+  node:
+  - k8s.gcr.io/pause:3.5
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		bomFile string
+		version string
+		wantErr string
+	}{
+		{
+			name:    "default BOM",
+			version: "1.22.4",
+		},
+		{
+			name:    "default BOM without version",
+			wantErr: "--kubernetes-version is required",
+		},
+		{
+			name:    "BOM file",
+			bomFile: bomFile,
+			version: "1.22.4",
+		},
+		{
+			name:    "BOM file without version",
+			bomFile: bomFile,
+		},
+		{
+			name:    "version mismatch",
+			bomFile: bomFile,
+			version: "1.21.0",
+			wantErr: "but --kubernetes-version is v1.21.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := rundata.NewCluster()
+			c.Kubernetes.Version = tt.version
+			c.OfflineBuild.BOMFile = tt.bomFile
+			rundata.DefaultKubeiCfg(c.Kubei)
+
+			bom, err := GetBOM(c)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetBOM() error = %v, wantErr %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetBOM() error = %v", err)
+			}
+			if bom.KubernetesVersion != "v1.22.4" {
+				t.Errorf("GetBOM() KubernetesVersion = %s, want v1.22.4", bom.KubernetesVersion)
+			}
+			if len(bom.Kube) == 0 || len(bom.Images.Master) == 0 || len(bom.Images.Node) == 0 {
+				t.Errorf("GetBOM() = %+v, want kube files and images", bom)
+			}
+		})
+	}
+}
+
+func TestValidateBOM(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    rundata.BOMFile
+		wantErr bool
+	}{
+		{
+			name: "binary",
+			file: rundata.BOMFile{URL: "https://example.com/kubeadm", InstallTo: "/usr/bin"},
+		},
+		{
+			name: "deb without installTo",
+			file: rundata.BOMFile{URL: "https://example.com/kubeadm_1.22.4-00_amd64.deb"},
+		},
+		{
+			name:    "binary without installTo",
+			file:    rundata.BOMFile{URL: "https://example.com/kubeadm"},
+			wantErr: true,
+		},
+		{
+			name:    "relative installTo",
+			file:    rundata.BOMFile{URL: "https://example.com/cni.tgz", InstallTo: "opt/cni/bin"},
+			wantErr: true,
+		},
+		{
+			name:    "unsafe name",
+			file:    rundata.BOMFile{URL: "https://example.com/kubeadm", Name: "kubeadm;reboot", InstallTo: "/usr/bin"},
+			wantErr: true,
+		},
+		{
+			name:    "not an http URL",
+			file:    rundata.BOMFile{URL: "file:///tmp/kubeadm", InstallTo: "/usr/bin"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid sha256",
+			file:    rundata.BOMFile{URL: "https://example.com/kubeadm", SHA256: "abc", InstallTo: "/usr/bin"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bom := &rundata.BOM{
+				KubernetesVersion: "v1.22.4",
+				Kube:              []rundata.BOMFile{tt.file},
+			}
+			if err := ValidateBOM(bom); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateBOM() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
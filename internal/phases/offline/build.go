@@ -0,0 +1,268 @@
+package offline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"github.com/vbauerster/mpb/v6"
+	"github.com/vbauerster/mpb/v6/decor"
+	"k8s.io/klog"
+
+	"github.com/yuyicai/kubei/internal/rundata"
+	"github.com/yuyicai/kubei/internal/tmpl"
+	"github.com/yuyicai/kubei/pkg/archive"
+	"github.com/yuyicai/kubei/pkg/registry"
+)
+
+const (
+	containerEngineDir = "container_engine"
+	kubeDir            = "kube"
+	imagesDir          = "images"
+)
+
+// Build downloads the files and the images of the BOM, and packs them into the offline package,
+// with the layout expected by the offline installation: container_engine/default.sh, kube/default.sh,
+// images/master.sh and images/node.sh, and kubei-manifest.json with the checksums of all files.
+// The files and the images of an architecture are in the sub dirs of the architecture, the scripts
+// install the ones of the architecture of the node.
+func Build(c *rundata.Cluster) error {
+	bom, err := GetBOM(c)
+	if err != nil {
+		return err
+	}
+
+	output := c.OfflineBuild.Output
+	if output == "" {
+		output = fmt.Sprintf("kubei-offline-%s.tar.gz", bom.KubernetesVersion)
+	}
+
+	dir, err := ioutil.TempDir("", "kubei-offline-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	color.HiBlue("Building offline package of Kubernetes %s 📦", bom.KubernetesVersion)
+
+	if err := downloadFiles(filepath.Join(dir, containerEngineDir), bom.ContainerEngine); err != nil {
+		return err
+	}
+	if err := downloadFiles(filepath.Join(dir, kubeDir), bom.Kube); err != nil {
+		return err
+	}
+
+	// the images are downloaded for each architecture into images/<type>/<arch>
+	for _, arch := range bom.Archs {
+		opts := c.Download.RegistryOptions()
+		opts.Arch, opts.Platform = arch, ""
+		if err := downloadImages(filepath.Join(dir, imagesDir, "master", arch), bom.Images.Master, opts); err != nil {
+			return err
+		}
+		if err := downloadImages(filepath.Join(dir, imagesDir, "node", arch), bom.Images.Node, opts); err != nil {
+			return err
+		}
+		if err := downloadImages(filepath.Join(dir, imagesDir, "registry", arch), bom.Images.Registry, opts); err != nil {
+			return err
+		}
+	}
+
+	if err := writeScripts(dir, bom); err != nil {
+		return err
+	}
+
+	m, err := NewManifest(dir, bom)
+	if err != nil {
+		return err
+	}
+	if err := WriteManifest(dir, m); err != nil {
+		return errors.Wrap(err, "[offline] failed to write manifest")
+	}
+
+	if err := pack(dir, output); err != nil {
+		return errors.Wrapf(err, "[offline] failed to write offline package %s", output)
+	}
+	if err := WriteChecksumFile(output); err != nil {
+		return errors.Wrapf(err, "[offline] failed to write checksum file %s", ChecksumFile(output))
+	}
+
+	fmt.Printf("[offline] Built offline package %s: %s\n", output, color.HiGreenString("done✅️"))
+	return nil
+}
+
+func writeScripts(dir string, bom *rundata.BOM) error {
+	containerEngine, err := tmpl.OfflineContainerEngine(bom.ContainerEngine)
+	if err != nil {
+		return err
+	}
+	kube, err := tmpl.OfflineKubeComponent(bom.Kube)
+	if err != nil {
+		return err
+	}
+
+	scripts := map[string]string{
+		filepath.Join(containerEngineDir, "default.sh"): containerEngine,
+		filepath.Join(kubeDir, "default.sh"):            kube,
+		filepath.Join(imagesDir, "master.sh"):           tmpl.OfflineImages("master"),
+		filepath.Join(imagesDir, "node.sh"):             tmpl.OfflineImages("node"),
+		filepath.Join(imagesDir, "registry.sh"):         tmpl.OfflineImages("registry"),
+	}
+	for name, script := range scripts {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(file, []byte(script), 0755); err != nil {
+			return errors.Wrapf(err, "[offline] failed to write %s", name)
+		}
+	}
+	return nil
+}
+
+// pack writes the package to a temporary file first, so that a broken package is never left in the output path.
+func pack(dir, output string) (err error) {
+	tmpFile := output + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(tmpFile)
+		}
+	}()
+
+	if err := archive.TarGzDir(dir, f); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, output)
+}
+
+func downloadImages(dir string, images []string, opts registry.Options) error {
+	for _, image := range images {
+		fmt.Printf("[offline] Downloading image %s\n", image)
+		if err := registry.DownloadImage(image, opts, dir); err != nil {
+			return errors.Wrapf(err, "[offline] failed to download image %s", image)
+		}
+	}
+	return nil
+}
+
+func downloadFiles(dir string, files []rundata.BOMFile) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		sum := strings.ToLower(f.SHA256)
+		if sum == "" && f.SHA256URL != "" {
+			var err error
+			if sum, err = getSHA256(f.SHA256URL); err != nil {
+				return err
+			}
+		}
+		if sum == "" {
+			klog.Warningf("[offline] no sha256 checksum of %s, the file is not verified", f.URL)
+		}
+
+		file := filepath.Join(dir, filepath.FromSlash(f.Path()))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+
+		fmt.Printf("[offline] Downloading %s\n", f.URL)
+		if err := downloadFile(f.URL, file, sum); err != nil {
+			return errors.Wrapf(err, "[offline] failed to download %s", f.URL)
+		}
+	}
+	return nil
+}
+
+func downloadFile(url, file, sum string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status %s", resp.Status)
+	}
+
+	fw, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer fw.Close()
+
+	p := mpb.New(
+		mpb.WithWidth(60),
+		mpb.WithRefreshRate(180*time.Millisecond),
+	)
+	bar := p.Add(
+		resp.ContentLength,
+		mpb.NewBarFiller("[=>-|"),
+		mpb.PrependDecorators(
+			decor.Name(filepath.Base(file)),
+		),
+		mpb.AppendDecorators(
+			decor.Percentage(decor.WC{}),
+			decor.Name(" ] "),
+			decor.EwmaSpeed(decor.UnitKiB, "% .2f", 60),
+		),
+	)
+	proxyReader := bar.ProxyReader(resp.Body)
+	defer proxyReader.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(fw, h), proxyReader)
+	if err != nil {
+		bar.Abort(true)
+		p.Wait()
+		return err
+	}
+	if resp.ContentLength <= 0 {
+		// the size is unknown until the download is finished
+		bar.SetTotal(n, true)
+	}
+	p.Wait()
+
+	if got := hex.EncodeToString(h.Sum(nil)); sum != "" && got != sum {
+		return errors.Errorf("sha256 mismatch: expected %s, got %s", sum, got)
+	}
+	return fw.Close()
+}
+
+// getSHA256 gets the checksum from a sha256 file, which contains the checksum optionally followed by the file name.
+func getSHA256(url string) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", errors.Wrapf(err, "[offline] failed to get sha256 %s", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("[offline] failed to get sha256 %s: unexpected status %s", url, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", errors.Wrapf(err, "[offline] failed to get sha256 %s", url)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+		return "", errors.Errorf("[offline] invalid sha256 %s", url)
+	}
+	return strings.ToLower(fields[0]), nil
+}
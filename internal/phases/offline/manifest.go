@@ -0,0 +1,84 @@
+package offline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/yuyicai/kubei/internal/constants"
+	"github.com/yuyicai/kubei/internal/rundata"
+)
+
+// Manifest is written into the offline package as kubei-manifest.json,
+// it records what the package is built from and the sha256 checksums of all files in it.
+type Manifest struct {
+	KubernetesVersion string            `json:"kubernetesVersion"`
+	Images            rundata.BOMImages `json:"images"`
+	// Archs are the architectures the package has files and images for
+	Archs []string `json:"archs,omitempty"`
+	// Files are the sha256 checksums of the files, the keys are the slash separated paths relative to the package root
+	Files map[string]string `json:"files"`
+}
+
+// NewManifest computes the checksums of all files in the dir, except the manifest itself.
+func NewManifest(dir string, bom *rundata.BOM) (*Manifest, error) {
+	m := &Manifest{
+		KubernetesVersion: bom.KubernetesVersion,
+		Images:            bom.Images,
+		Archs:             bom.Archs,
+		Files:             map[string]string{},
+	}
+
+	if err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == constants.OfflineManifestFile {
+			return nil
+		}
+
+		sum, err := fileSHA256(file)
+		if err != nil {
+			return err
+		}
+		m.Files[rel] = sum
+		return nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, "[offline] failed to compute checksums of %s", dir)
+	}
+	return m, nil
+}
+
+// WriteManifest writes the manifest into the dir.
+func WriteManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, constants.OfflineManifestFile), data, 0644)
+}
+
+func fileSHA256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
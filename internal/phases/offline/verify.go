@@ -0,0 +1,180 @@
+package offline
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	"github.com/yuyicai/kubei/internal/constants"
+)
+
+// ChecksumFile returns the path of the sha256 checksum file written next to the offline package.
+func ChecksumFile(pkg string) string {
+	return pkg + ".sha256"
+}
+
+// VerifyPackage reads the whole offline package, verifies the files in it against kubei-manifest.json,
+// checks that it is built for the Kubernetes version if the version is set and for all the archs of the nodes,
+// and verifies the package against the checksum file next to it if there is one.
+// It returns the sha256 checksum of the package, which is used to verify the uploaded packages on the nodes.
+func VerifyPackage(pkg, version string, archs []string) (string, error) {
+	f, err := os.Open(pkg)
+	if err != nil {
+		return "", errors.Wrapf(err, "[offline] failed to open offline package %s", pkg)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	r := bufio.NewReader(io.TeeReader(f, h))
+
+	sums, m, err := readPackage(r)
+	if err != nil {
+		return "", errors.Wrapf(err, "[offline] failed to read offline package %s, it may be truncated", pkg)
+	}
+	// the rest of the package, e.g. the padding of the tar, is also included in the checksum
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return "", errors.Wrapf(err, "[offline] failed to read offline package %s", pkg)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	if err := verifyChecksumFile(pkg, sum); err != nil {
+		return "", err
+	}
+
+	if m == nil {
+		klog.Warningf("[offline] there is no %s in offline package %s, the files and the Kubernetes version of it are not verified",
+			constants.OfflineManifestFile, pkg)
+		return sum, nil
+	}
+	if err := verifyManifest(m, sums, version, archs); err != nil {
+		return "", errors.Wrapf(err, "[offline] invalid offline package %s", pkg)
+	}
+
+	klog.V(2).Infof("[offline] offline package %s is verified, sha256: %s", pkg, sum)
+	return sum, nil
+}
+
+// readPackage returns the sha256 checksums of the regular files in the package and the manifest in it.
+// The package may be compressed by gzip or not, as `tar xf` handles both.
+func readPackage(r *bufio.Reader) (map[string]string, *Manifest, error) {
+	var tr *tar.Reader
+	if magic, err := r.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer gr.Close()
+		tr = tar.NewReader(gr)
+	} else {
+		tr = tar.NewReader(r)
+	}
+
+	var m *Manifest
+	sums := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := strings.TrimPrefix(path.Clean(hdr.Name), "./")
+		if name == constants.OfflineManifestFile {
+			m = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(m); err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to parse %s", constants.OfflineManifestFile)
+			}
+			continue
+		}
+
+		h := sha256.New()
+		if _, err := io.Copy(h, tr); err != nil {
+			return nil, nil, err
+		}
+		sums[name] = hex.EncodeToString(h.Sum(nil))
+	}
+	return sums, m, nil
+}
+
+func verifyManifest(m *Manifest, sums map[string]string, version string, archs []string) error {
+	if version != "" && strings.TrimPrefix(m.KubernetesVersion, "v") != strings.TrimPrefix(version, "v") {
+		return fmt.Errorf("it is built for Kubernetes %s, but --kubernetes-version is %s", m.KubernetesVersion, version)
+	}
+
+	// the packages built before the archs are recorded are amd64 only, the install scripts fail on the other archs
+	if len(m.Archs) > 0 {
+		built := map[string]bool{}
+		for _, arch := range m.Archs {
+			built[arch] = true
+		}
+		for _, arch := range archs {
+			if !built[arch] {
+				return fmt.Errorf("it is built for %s, but there are %s nodes, rebuild it with --archs=%s",
+					strings.Join(m.Archs, ","), arch, strings.Join(append(m.Archs, arch), ","))
+			}
+		}
+	}
+
+	for name, want := range m.Files {
+		got, ok := sums[name]
+		if !ok {
+			return fmt.Errorf("file %s in %s is missing", name, constants.OfflineManifestFile)
+		}
+		if got != want {
+			return fmt.Errorf("sha256 mismatch for %s: expected %s, got %s", name, want, got)
+		}
+	}
+	for name := range sums {
+		if _, ok := m.Files[name]; !ok {
+			return fmt.Errorf("file %s is not in %s", name, constants.OfflineManifestFile)
+		}
+	}
+	return nil
+}
+
+func verifyChecksumFile(pkg, sum string) error {
+	data, err := ioutil.ReadFile(ChecksumFile(pkg))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "[offline] failed to read checksum file %s", ChecksumFile(pkg))
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 || !sha256Regexp.MatchString(fields[0]) {
+		return errors.Errorf("[offline] invalid checksum file %s", ChecksumFile(pkg))
+	}
+	if want := strings.ToLower(fields[0]); want != sum {
+		return errors.Errorf("[offline] sha256 mismatch for offline package %s: expected %s in %s, got %s",
+			pkg, want, ChecksumFile(pkg), sum)
+	}
+	return nil
+}
+
+// WriteChecksumFile writes the sha256 checksum of the offline package next to it, in the format of sha256sum.
+func WriteChecksumFile(pkg string) error {
+	sum, err := fileSHA256(pkg)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(ChecksumFile(pkg), []byte(fmt.Sprintf("%s  %s\n", sum, filepath.Base(pkg))), 0644)
+}
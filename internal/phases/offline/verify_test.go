@@ -0,0 +1,122 @@
+package offline
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yuyicai/kubei/internal/rundata"
+)
+
+func TestVerifyPackage(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "kubei-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	newPackage := func(name string, tamper func(dir string)) string {
+		dir := filepath.Join(tmp, name)
+		for file, content := range map[string]string{
+			"kube/default.sh":   "#!/bin/sh\n",
+			"kube/kubeadm":      "kubeadm",
+			"images/node/a.tar": "image",
+		} {
+			if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(file)), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		m, err := NewManifest(dir, &rundata.BOM{KubernetesVersion: "v1.22.4", Archs: []string{"amd64"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteManifest(dir, m); err != nil {
+			t.Fatal(err)
+		}
+		if tamper != nil {
+			tamper(dir)
+		}
+
+		pkg := filepath.Join(tmp, name+".tar.gz")
+		if err := pack(dir, pkg); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteChecksumFile(pkg); err != nil {
+			t.Fatal(err)
+		}
+		return pkg
+	}
+
+	good := newPackage("good", nil)
+	modified := newPackage("modified", func(dir string) {
+		_ = ioutil.WriteFile(filepath.Join(dir, "kube", "kubeadm"), []byte("evil"), 0644)
+	})
+	extra := newPackage("extra", func(dir string) {
+		_ = ioutil.WriteFile(filepath.Join(dir, "kube", "extra"), []byte("extra"), 0644)
+	})
+	missing := newPackage("missing", func(dir string) {
+		_ = os.Remove(filepath.Join(dir, "images", "node", "a.tar"))
+	})
+
+	data, err := ioutil.ReadFile(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(tmp, "truncated.tar.gz")
+	if err := ioutil.WriteFile(truncated, data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	wrongChecksum := filepath.Join(tmp, "wrong-checksum.tar.gz")
+	if err := ioutil.WriteFile(wrongChecksum, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(ChecksumFile(wrongChecksum), []byte(strings.Repeat("0", 64)+"  wrong-checksum.tar.gz\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		pkg     string
+		version string
+		archs   []string
+		wantErr string
+	}{
+		{name: "good", pkg: good, version: "1.22.4", archs: []string{"amd64"}},
+		{name: "without version", pkg: good},
+		{name: "arch mismatch", pkg: good, archs: []string{"amd64", "arm64"}, wantErr: "built for amd64, but there are arm64 nodes"},
+		{name: "version mismatch", pkg: good, version: "1.21.0", wantErr: "built for Kubernetes v1.22.4"},
+		{name: "modified file", pkg: modified, wantErr: "sha256 mismatch for kube/kubeadm"},
+		{name: "extra file", pkg: extra, wantErr: "file kube/extra is not in"},
+		{name: "missing file", pkg: missing, wantErr: "file images/node/a.tar in"},
+		{name: "truncated", pkg: truncated, wantErr: "may be truncated"},
+		{name: "wrong checksum file", pkg: wrongChecksum, wantErr: "sha256 mismatch for offline package"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum, err := VerifyPackage(tt.pkg, tt.version, tt.archs)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("VerifyPackage() error = %v, wantErr %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyPackage() error = %v", err)
+			}
+
+			want, err := fileSHA256(tt.pkg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sum != want {
+				t.Errorf("VerifyPackage() = %s, want %s", sum, want)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"k8s.io/klog"
 
 	"github.com/yuyicai/kubei/internal/operator"
+	"github.com/yuyicai/kubei/internal/phases/system"
 	"github.com/yuyicai/kubei/internal/rundata"
 	"github.com/yuyicai/kubei/internal/tmpl"
 )
@@ -28,7 +29,30 @@ func resetkubeadmOnNode(node *rundata.Node, apiDomainName string) error {
 		return err
 	}
 
-	return node.Run(tmpl.ResetHosts(apiDomainName))
+	if err := node.Run(tmpl.ResetHosts(apiDomainName)); err != nil {
+		return err
+	}
+
+	if err := node.Run(tmpl.ResetLocalRegistry()); err != nil {
+		return err
+	}
+
+	if err := node.Run(tmpl.ResetRegistryAuth()); err != nil {
+		return err
+	}
+
+	return system.RemoveProxy(node)
+}
+
+// RestoreHost rolls back the files of the nodes changed by kubei, it runs after the container engine is removed.
+func RestoreHost(c *rundata.Cluster) error {
+	return operator.RunOnAllNodes(c, func(node *rundata.Node, c *rundata.Cluster) error {
+		if err := system.RestoreHost(node); err != nil {
+			return err
+		}
+		klog.Infof("[%s] [journal] Successfully restore the host", node.HostInfo.Host)
+		return nil
+	})
 }
 
 func RemoveKubeComponente(c *rundata.Cluster) error {
@@ -47,7 +71,7 @@ func removeKubeComponente(node *rundata.Node) error {
 }
 
 func removeKubeComponentOnNode(node *rundata.Node) error {
-	cmdTmpl := tmpl.NewKubeText(node.PackageManagementType)
+	cmdTmpl := tmpl.NewKubeText(node.OS)
 	return node.Run(cmdTmpl.RemoveKubeComponent())
 }
 
@@ -67,6 +91,6 @@ func removeContainerEngine(node *rundata.Node) error {
 }
 
 func removeContainerEngineOnNode(node *rundata.Node) error {
-	cmdTmpl := tmpl.NewContainerEngineText(node.PackageManagementType)
+	cmdTmpl := tmpl.NewContainerEngineText(node.OS)
 	return node.Run(cmdTmpl.RemoveDocker())
 }
@@ -0,0 +1,160 @@
+package send
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/pkg/sync/errgroup"
+	"github.com/vbauerster/mpb/v6"
+	"k8s.io/klog"
+
+	"github.com/yuyicai/kubei/internal/constants"
+	"github.com/yuyicai/kubei/internal/operator"
+	"github.com/yuyicai/kubei/internal/rundata"
+	"github.com/yuyicai/kubei/internal/tmpl"
+)
+
+// seeds are the nodes which have the package and serve it by http,
+// each of them is in the pool fanout times, so that it serves at most that many nodes at a time.
+type seeds struct {
+	pool    chan *rundata.Node
+	fanout  int
+	mu      sync.Mutex
+	serving []*rundata.Node
+}
+
+// sendP2P uploads the package to the first node only, and the other nodes copy it from the nodes which already have it.
+// The number of the seeds doubles as the copies finish, so the package goes through the link between kubei and the nodes,
+// e.g. a jump server, only once.
+func sendP2P(c *rundata.Cluster, sum string) error {
+	var nodes []*rundata.Node
+	for _, node := range c.ClusterNodes.GetAllNodes() {
+		if node.InstallType == constants.InstallTypeOffline && !node.IsSend {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	file := dstFile(c.Kubei)
+	first := nodes[0]
+
+	p := mpb.New(
+		mpb.WithWidth(60),
+		mpb.WithRefreshRate(180*time.Millisecond),
+	)
+	err := sendFile(file, c.OfflineFile, first, p)
+	p.Wait()
+	if err != nil {
+		return fmt.Errorf("[%s] [send] failed to send %s: %v", first.HostInfo.Host, c.OfflineFile, err)
+	}
+	if err := verify(file, sum, first); err != nil {
+		return err
+	}
+
+	s := newSeeds(len(nodes), c.Distribution.Fanout)
+	defer s.stop()
+
+	if len(nodes) > 1 {
+		if err := s.serve(first, file, c.Distribution.Port); err != nil {
+			return err
+		}
+
+		g := errgroup.WithCancel(context.Background())
+		g.GOMAXPROCS(constants.DefaultGOMAXPROCS)
+		for _, node := range nodes[1:] {
+			node := node
+			g.Go(func(ctx context.Context) error {
+				if err := s.copy(ctx, node, file, sum, c.Distribution.Port); err != nil {
+					return err
+				}
+				return s.serve(node, file, c.Distribution.Port)
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return err
+		}
+	}
+
+	return operator.RunOnAllNodes(c, func(node *rundata.Node, c *rundata.Cluster) error {
+		if node.InstallType != constants.InstallTypeOffline || node.IsSend {
+			return nil
+		}
+		if err := tar(file, node); err != nil {
+			return fmt.Errorf("[%s] [tar] failed to Decompress the file %s: %v", node.HostInfo.Host, file, err)
+		}
+		node.IsSend = true
+		return nil
+	})
+}
+
+func newSeeds(nodes, fanout int) *seeds {
+	return &seeds{pool: make(chan *rundata.Node, nodes*fanout), fanout: fanout}
+}
+
+// copy downloads the package from a seed, it waits until a seed is available.
+func (s *seeds) copy(ctx context.Context, node *rundata.Node, file, sum string, port int) error {
+	if got, err := remoteSHA256(file, node); err == nil && got == sum {
+		klog.V(2).Infof("[%s] [send] %s is up to date, skip the copy", node.HostInfo.Host, file)
+		return nil
+	}
+
+	var seed *rundata.Node
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case seed = <-s.pool:
+	}
+	defer func() { s.pool <- seed }()
+
+	url := fmt.Sprintf("http://%s/%s", net.JoinHostPort(seed.HostInfo.Host, strconv.Itoa(port)), path.Base(file))
+	cmd, err := tmpl.FetchFile(url, file, sum)
+	if err != nil {
+		return err
+	}
+
+	klog.V(2).Infof("[%s] [send] copy %s from %s", node.HostInfo.Host, file, seed.HostInfo.Host)
+	if err := node.Run(cmd); err != nil {
+		return fmt.Errorf("[%s] [send] failed to copy %s from %s: %v", node.HostInfo.Host, file, seed.HostInfo.Host, err)
+	}
+	return verify(file, sum, node)
+}
+
+// serve starts the http server on the node and adds it to the seeds.
+func (s *seeds) serve(node *rundata.Node, file string, port int) error {
+	cmd, err := tmpl.ServeFile(file, path.Base(file), node.HostInfo.Host, port)
+	if err != nil {
+		return err
+	}
+	if err := node.Run(cmd); err != nil {
+		return fmt.Errorf("[%s] [send] failed to serve %s on port %d: %v", node.HostInfo.Host, file, port, err)
+	}
+
+	s.mu.Lock()
+	s.serving = append(s.serving, node)
+	s.mu.Unlock()
+
+	for i := 0; i < s.fanout; i++ {
+		s.pool <- node
+	}
+	return nil
+}
+
+// stop stops the http servers of the seeds, the errors are only logged as the package has been sent.
+func (s *seeds) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, node := range s.serving {
+		if err := node.Run(tmpl.StopServeFile()); err != nil {
+			klog.Warningf("[%s] [send] failed to stop serving the offline package: %v", node.HostInfo.Host, err)
+		}
+	}
+	s.serving = nil
+}
@@ -0,0 +1,144 @@
+package send
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yuyicai/kubei/internal/rundata"
+	"github.com/yuyicai/kubei/pkg/ssh/sshtest"
+)
+
+var fetchURL = regexp.MustCompile(`curl -fsS -o \S+ http://([0-9.]+):18088/kube\.tar\.gz`)
+
+// downloads records the copies from each seed, the seeds are told apart by the host in the url.
+type downloads struct {
+	mu      sync.Mutex
+	active  map[string]int
+	max     map[string]int
+	sources []string
+}
+
+func (d *downloads) handler(data []byte) sshtest.Handler {
+	return func(e *sshtest.Exec) int {
+		seed := fetchURL.FindStringSubmatch(e.Script)[1]
+
+		d.mu.Lock()
+		d.active[seed]++
+		if d.active[seed] > d.max[seed] {
+			d.max[seed] = d.active[seed]
+		}
+		d.sources = append(d.sources, seed)
+		d.mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		e.FS.WriteFile("/tmp/.kubei/kube.tar.gz", data, 0644)
+
+		d.mu.Lock()
+		d.active[seed]--
+		d.mu.Unlock()
+		return 0
+	}
+}
+
+func newP2PCluster(t *testing.T, n, fanout int, data []byte) (*rundata.Cluster, []*sshtest.Server) {
+	src := filepath.Join(t.TempDir(), "kube.tar.gz")
+	if err := ioutil.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := rundata.NewCluster()
+	c.OfflineFile = src
+	c.Distribution = rundata.Distribution{Mode: "p2p", Port: 18088, Fanout: fanout}
+
+	var servers []*sshtest.Server
+	for i := 0; i < n; i++ {
+		s := sshtest.NewServer(t, "root", "secret")
+		node := newNode(t, s)
+		node.HostInfo.Host = fmt.Sprintf("10.0.0.%d", i+1)
+		c.ClusterNodes.Workers = append(c.ClusterNodes.Workers, node)
+		servers = append(servers, s)
+	}
+	return c, servers
+}
+
+func TestSendP2P(t *testing.T) {
+	data := []byte(strings.Repeat("kubernetes offline pkg", 100))
+	sum := sha256.Sum256(data)
+
+	for _, fanout := range []int{1, 2, 3} {
+		t.Run(fmt.Sprintf("fanout %d", fanout), func(t *testing.T) {
+			c, servers := newP2PCluster(t, 8, fanout, data)
+			d := &downloads{active: map[string]int{}, max: map[string]int{}}
+			for _, s := range servers[1:] {
+				s.Handle(`curl -fsS`, d.handler(data))
+			}
+
+			if err := sendP2P(c, hex.EncodeToString(sum[:])); err != nil {
+				t.Fatal(err)
+			}
+
+			// the package is uploaded to the first node only, which is the first seed
+			if len(d.sources) != len(servers)-1 || d.sources[0] != "10.0.0.1" {
+				t.Errorf("sources = %q, want 7 copies and the first one from 10.0.0.1", d.sources)
+			}
+			for seed, max := range d.max {
+				if max > fanout {
+					t.Errorf("%s served %d nodes at a time, want at most %d", seed, max, fanout)
+				}
+			}
+
+			for i, s := range servers {
+				node := c.ClusterNodes.Workers[i]
+				if !node.IsSend {
+					t.Errorf("[%s] IsSend = false, want true", node.HostInfo.Host)
+				}
+				if got, _ := s.FS.ReadFile("/tmp/.kubei/kube.tar.gz"); string(got) != string(data) {
+					t.Errorf("[%s] the package has %d bytes, want %d bytes", node.HostInfo.Host, len(got), len(data))
+				}
+
+				// every node serves the package on its own ip only, and stops serving it at the end
+				var served, stopped bool
+				for _, script := range s.Scripts() {
+					served = served || strings.Contains(script, "python3 -m http.server --bind "+node.HostInfo.Host+" 18088")
+					stopped = stopped || strings.Contains(script, "rm -rf /tmp/.kubei/.dist")
+				}
+				if !served || !stopped {
+					t.Errorf("[%s] served = %v, stopped = %v, want the package served on the node ip and stopped", node.HostInfo.Host, served, stopped)
+				}
+			}
+		})
+	}
+}
+
+func TestSendP2PCopyFailed(t *testing.T) {
+	data := []byte("kubernetes offline pkg")
+	sum := sha256.Sum256(data)
+
+	c, servers := newP2PCluster(t, 2, 2, data)
+	servers[1].Handle(`curl -fsS`, func(e *sshtest.Exec) int {
+		io.WriteString(e.Stderr, "sha256 mismatch for http://10.0.0.1:18088/kube.tar.gz\n")
+		return 1
+	})
+
+	err := sendP2P(c, hex.EncodeToString(sum[:]))
+	if err == nil || !strings.Contains(err.Error(), "[10.0.0.2] [send] failed to copy") {
+		t.Fatalf("sendP2P() = %v, want the copy error of 10.0.0.2", err)
+	}
+	if c.ClusterNodes.Workers[1].IsSend {
+		t.Error("IsSend of 10.0.0.2 = true, want false")
+	}
+	// the first node stops serving the package after the failure
+	scripts := servers[0].Scripts()
+	if last := scripts[len(scripts)-1]; !strings.Contains(last, "rm -rf /tmp/.kubei/.dist") {
+		t.Errorf("the last script of 10.0.0.1 = %q, want the server stopped", last)
+	}
+}
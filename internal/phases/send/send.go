@@ -4,37 +4,87 @@ import (
 	"fmt"
 	"path"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/vbauerster/mpb/v6"
 	"k8s.io/klog"
 
 	"github.com/yuyicai/kubei/internal/constants"
 	"github.com/yuyicai/kubei/internal/operator"
+	"github.com/yuyicai/kubei/internal/phases/offline"
 	"github.com/yuyicai/kubei/internal/rundata"
 )
 
 func Send(c *rundata.Cluster) error {
+	// the package is verified once locally, the uploaded packages are verified against its checksum
+	sum, err := offline.VerifyPackage(c.OfflineFile, c.Kubernetes.Version, nodeArchs(c))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[send] verify kubernetes offline pkg %s: %s\n", c.OfflineFile, color.HiGreenString("done✅️"))
+
 	color.HiBlue("Sending Kubernetes offline pkg to nodes ✉️")
-	return operator.RunOnAllNodes(c, func(node *rundata.Node, c *rundata.Cluster) error {
-		if err := send(node, c.Kubei); err != nil {
-			return err
-		}
+	if c.Distribution.Mode == constants.DistributionModeP2P {
+		err = sendP2P(c, sum)
+	} else {
+		err = sendDirect(c, sum)
+	}
+	if err != nil {
+		return err
+	}
 
+	for _, node := range c.ClusterNodes.GetAllNodes() {
 		fmt.Printf("[%s] [send] send kubernetes offline pkg: %s\n", node.HostInfo.Host, color.HiGreenString("done✅️"))
-		return nil
+	}
+	return nil
+}
+
+// nodeArchs returns the archs of the nodes detected by the preflight.
+func nodeArchs(c *rundata.Cluster) []string {
+	var archs []string
+	seen := map[string]bool{}
+	for _, node := range c.ClusterNodes.GetAllNodes() {
+		if arch := node.OS.Arch; arch != "" && !seen[arch] {
+			seen[arch] = true
+			archs = append(archs, arch)
+		}
+	}
+	return archs
+}
+
+// sendDirect uploads the package to every node.
+func sendDirect(c *rundata.Cluster, sum string) error {
+	p := mpb.New(
+		mpb.WithWidth(60),
+		mpb.WithRefreshRate(180*time.Millisecond),
+	)
+	err := operator.RunOnAllNodes(c, func(node *rundata.Node, c *rundata.Cluster) error {
+		return send(node, c.Kubei, sum, p)
 	})
+	// the progress bars are rendered until all uploads are finished, so the results are printed after them
+	p.Wait()
+	return err
+}
+
+func send(node *rundata.Node, cfg *rundata.Kubei, sum string, p *mpb.Progress) error {
+	return sendAndtar(dstFile(cfg), cfg.OfflineFile, sum, node, p)
 }
 
-func send(node *rundata.Node, cfg *rundata.Kubei) error {
-	return sendAndtar(path.Join("/tmp/.kubei", filepath.Base(cfg.OfflineFile)), cfg.OfflineFile, node)
+func dstFile(cfg *rundata.Kubei) string {
+	return path.Join("/tmp/.kubei", filepath.Base(cfg.OfflineFile))
 }
 
-func sendAndtar(dstFile, srcFile string, node *rundata.Node) error {
+func sendAndtar(dstFile, srcFile, sum string, node *rundata.Node, p *mpb.Progress) error {
 	if node.InstallType == constants.InstallTypeOffline && !node.IsSend {
-		if err := sendFile(dstFile, srcFile, node); err != nil {
-			return err
+		if err := sendFile(dstFile, srcFile, node, p); err != nil {
+			return fmt.Errorf("[%s] [send] failed to send %s: %v", node.HostInfo.Host, srcFile, err)
 		}
 		klog.V(3).Infof("[%s] [send] send pkg to %s, ", node.HostInfo.Host, dstFile)
+		if err := verify(dstFile, sum, node); err != nil {
+			return err
+		}
 		if err := tar(dstFile, node); err != nil {
 			return fmt.Errorf("[%s] [tar] failed to Decompress the file %s: %v", node.HostInfo.Host, dstFile, err)
 		}
@@ -45,8 +95,44 @@ func sendAndtar(dstFile, srcFile string, node *rundata.Node) error {
 	return nil
 }
 
-func sendFile(dstFile, srcFile string, node *rundata.Node) error {
-	return node.SSH.SendFile(dstFile, srcFile)
+func sendFile(dstFile, srcFile string, node *rundata.Node, p *mpb.Progress) error {
+	return node.SendFile(dstFile, srcFile, p)
+}
+
+// verify checks the sha256 checksum of the uploaded package, a broken package is removed from the node.
+func verify(file, sum string, node *rundata.Node) error {
+	if node.DryRun() {
+		return node.Run(fmt.Sprintf("sha256sum %s", file))
+	}
+
+	got, err := remoteSHA256(file, node)
+	if err != nil {
+		return err
+	}
+
+	if got != sum {
+		if err := node.Run(fmt.Sprintf("rm -f %s", file)); err != nil {
+			klog.Warningf("[%s] [send] failed to remove %s: %v", node.HostInfo.Host, file, err)
+		}
+		return fmt.Errorf("[%s] [send] sha256 mismatch for %s, the upload may be truncated: expected %s, got %q",
+			node.HostInfo.Host, file, sum, got)
+	}
+
+	klog.V(3).Infof("[%s] [send] verified the sha256 checksum of %s", node.HostInfo.Host, file)
+	return nil
+}
+
+func remoteSHA256(file string, node *rundata.Node) (string, error) {
+	out, err := node.RunOut(fmt.Sprintf("sha256sum %s", file))
+	if err != nil {
+		return "", fmt.Errorf("[%s] [send] failed to get the sha256 checksum of %s: %v", node.HostInfo.Host, file, err)
+	}
+
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return fields[0], nil
 }
 
 func tar(file string, node *rundata.Node) error {
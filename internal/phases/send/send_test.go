@@ -0,0 +1,83 @@
+package send
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yuyicai/kubei/internal/constants"
+	"github.com/yuyicai/kubei/internal/rundata"
+	"github.com/yuyicai/kubei/pkg/ssh"
+	"github.com/yuyicai/kubei/pkg/ssh/sshtest"
+)
+
+func newNode(t *testing.T, s *sshtest.Server) *rundata.Node {
+	client, err := ssh.Connect(s.Host(), s.Port(), s.User, s.Password, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return &rundata.Node{
+		Executor:    client,
+		HostInfo:    rundata.HostInfo{Host: s.Host(), Port: s.Port(), User: s.User, Password: s.Password},
+		InstallType: constants.InstallTypeOffline,
+	}
+}
+
+func TestSendAndtar(t *testing.T) {
+	data := []byte("kubernetes offline pkg")
+	src := filepath.Join(t.TempDir(), "kube.tar.gz")
+	if err := ioutil.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	dst := "/tmp/.kubei/kube.tar.gz"
+
+	s := sshtest.NewServer(t, "ubuntu", "secret")
+	node := newNode(t, s)
+
+	if err := sendAndtar(dst, src, hex.EncodeToString(sum[:]), node, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !node.IsSend {
+		t.Error("IsSend = false, want true")
+	}
+	if got, _ := s.FS.ReadFile(dst); string(got) != string(data) {
+		t.Errorf("the sent file = %q, want %q", got, data)
+	}
+	want := []string{"sha256sum " + dst, "tar xf " + dst + " -C /tmp/.kubei"}
+	if scripts := s.Scripts(); !reflect.DeepEqual(scripts, want) {
+		t.Errorf("scripts = %q, want %q", scripts, want)
+	}
+}
+
+func TestSendAndtarChecksumMismatch(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "kube.tar.gz")
+	if err := ioutil.WriteFile(src, []byte("truncated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst := "/tmp/.kubei/kube.tar.gz"
+
+	s := sshtest.NewServer(t, "root", "secret")
+	node := newNode(t, s)
+
+	err := sendAndtar(dst, src, strings.Repeat("0", 64), node, nil)
+	if err == nil || !strings.Contains(err.Error(), "sha256 mismatch") {
+		t.Fatalf("sendAndtar() = %v, want the sha256 mismatch error", err)
+	}
+	if node.IsSend {
+		t.Error("IsSend = true, want false")
+	}
+	if _, ok := s.FS.ReadFile(dst); ok {
+		t.Errorf("the broken %s is left on the node", dst)
+	}
+	want := []string{"sha256sum " + dst, "rm -f " + dst}
+	if scripts := s.Scripts(); !reflect.DeepEqual(scripts, want) {
+		t.Errorf("scripts = %q, want %q", scripts, want)
+	}
+}
@@ -2,12 +2,46 @@ package system
 
 import (
 	"fmt"
+	"strings"
+
+	"k8s.io/klog"
+
+	"github.com/yuyicai/kubei/internal/constants"
 	"github.com/yuyicai/kubei/internal/rundata"
 	"github.com/yuyicai/kubei/internal/tmpl"
-	"k8s.io/klog"
 )
 
+// Journal records the files of the node before they are changed, they are rolled back by RestoreHost.
+func Journal(node *rundata.Node, files ...string) error {
+	klog.V(3).Infof("[%s] [journal] Record %s", node.HostInfo.Host, strings.Join(files, ", "))
+	cmd, err := tmpl.Journal(files...)
+	if err != nil {
+		return fmt.Errorf("[%s] [journal] Failed to record %s: %v", node.HostInfo.Host, strings.Join(files, ", "), err)
+	}
+	if err := node.Run(cmd); err != nil {
+		return fmt.Errorf("[%s] [journal] Failed to record %s: %v", node.HostInfo.Host, strings.Join(files, ", "), err)
+	}
+	return nil
+}
+
+// RestoreHost rolls back the files recorded by Journal.
+func RestoreHost(node *rundata.Node) error {
+	klog.V(2).Infof("[%s] [journal] Restore the files changed by kubei", node.HostInfo.Host)
+	cmd, err := tmpl.RestoreHost()
+	if err != nil {
+		return fmt.Errorf("[%s] [journal] Failed to restore the host: %v", node.HostInfo.Host, err)
+	}
+	if err := node.Run(cmd); err != nil {
+		return fmt.Errorf("[%s] [journal] Failed to restore the host: %v", node.HostInfo.Host, err)
+	}
+	return nil
+}
+
 func SetHost(node *rundata.Node, ip, apiDomainName string) error {
+	if err := Journal(node, constants.HostsFile); err != nil {
+		return err
+	}
+
 	klog.V(2).Infof("[%s] [host] Add \"%s %s\" to /etc/hosts", node.HostInfo.Host, ip, apiDomainName)
 	if err := node.Run(tmpl.SetHosts(ip, apiDomainName)); err != nil {
 		return fmt.Errorf("[%s] [host] Failed to set /etc/hosts: %v", node.HostInfo.Host, err)
@@ -16,6 +50,10 @@ func SetHost(node *rundata.Node, ip, apiDomainName string) error {
 }
 
 func SwapOff(node *rundata.Node) error {
+	if err := Journal(node, constants.FstabFile); err != nil {
+		return err
+	}
+
 	klog.V(2).Infof("[%s] [swap] Disable swap", node.HostInfo.Host)
 	if err := node.Run(tmpl.SwapOff()); err != nil {
 		return fmt.Errorf("[%s] [swap]  Failed to disable swap: %v", node.HostInfo.Host, err)
@@ -30,3 +68,31 @@ func Restart(name string, node *rundata.Node) error {
 	}
 	return nil
 }
+
+func SetProxy(node *rundata.Node, p rundata.Proxy, noProxy string) error {
+	if err := Journal(node, tmpl.ProxyFiles()...); err != nil {
+		return err
+	}
+
+	klog.V(2).Infof("[%s] [proxy] Set the http proxy of apt, yum, docker, containerd and kubelet", node.HostInfo.Host)
+	cmd, err := tmpl.Proxy(p.HTTPProxy, p.HTTPSProxy, noProxy)
+	if err != nil {
+		return fmt.Errorf("[%s] [proxy] Failed to set the http proxy: %v", node.HostInfo.Host, err)
+	}
+	if err := node.Run(cmd); err != nil {
+		return fmt.Errorf("[%s] [proxy] Failed to set the http proxy: %v", node.HostInfo.Host, err)
+	}
+	return nil
+}
+
+func RemoveProxy(node *rundata.Node) error {
+	klog.V(2).Infof("[%s] [proxy] Remove the http proxy set by kubei", node.HostInfo.Host)
+	cmd, err := tmpl.RemoveProxy()
+	if err != nil {
+		return fmt.Errorf("[%s] [proxy] Failed to remove the http proxy: %v", node.HostInfo.Host, err)
+	}
+	if err := node.Run(cmd); err != nil {
+		return fmt.Errorf("[%s] [proxy] Failed to remove the http proxy: %v", node.HostInfo.Host, err)
+	}
+	return nil
+}
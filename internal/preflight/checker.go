@@ -0,0 +1,339 @@
+package preflight
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/yuyicai/kubei/internal/constants"
+	"github.com/yuyicai/kubei/internal/rundata"
+)
+
+const (
+	minKernelMajor = 3
+	minKernelMinor = 10
+	// the same as kubeadm, https://github.com/kubernetes/kubernetes/blob/v1.18.0/cmd/kubeadm/app/constants/constants.go
+	controlPlaneNumCPU = 2
+	controlPlaneMemMB  = 1700
+)
+
+// Checker validates the facts of a node, the errors fail the preflight unless they are ignored.
+type Checker interface {
+	Name() string
+	Check(f *Facts) (warnings, errorList []error)
+}
+
+// ClusterChecker validates the facts of all nodes, e.g. the product_uuid of each node must be unique.
+type ClusterChecker interface {
+	Name() string
+	Check(facts []*Facts) []Issue
+}
+
+// Issue is a warning or an error found by a check.
+type Issue struct {
+	Host    string
+	Check   string
+	Level   string
+	Message string
+}
+
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	// LevelIgnored is an error ignored by --ignore-preflight-errors
+	LevelIgnored = "ignored"
+)
+
+// nodeCheckers returns the checks of a node, the checks of the masters are the same as "kubeadm init".
+func nodeCheckers(node *rundata.Node, isMaster bool, c *rundata.Cluster) []Checker {
+	checkers := []Checker{
+		osCheck{},
+		kernelCheck{},
+		brNetfilterCheck{},
+		commandCheck{name: "conntrack"},
+		commandCheck{name: "ip"},
+		commandCheck{name: "iptables"},
+		commandCheck{name: "mount"},
+		commandCheck{name: "nsenter"},
+		commandCheck{name: "ebtables", warning: true},
+		commandCheck{name: "ethtool", warning: true},
+		commandCheck{name: "socat", warning: true},
+		commandCheck{name: "tc", warning: true},
+		portCheck{port: 10250},
+	}
+
+	if isMaster {
+		checkers = append(checkers,
+			numCPUCheck{min: controlPlaneNumCPU},
+			memCheck{minMB: controlPlaneMemMB},
+			portCheck{port: int(c.Kubeadm.LocalAPIEndpoint.BindPort)},
+			portCheck{port: 2379},
+			portCheck{port: 2380},
+			portCheck{port: 10251},
+			portCheck{port: 10252},
+		)
+	} else if c.HA.Type == constants.HATypeLocalSLB {
+		if port, err := strconv.Atoi(c.HA.LocalSLB.Nginx.Port); err == nil {
+			checkers = append(checkers, portCheck{port: port})
+		}
+	}
+
+	if c.LocalRegistry.Enable && node.HostInfo.Host == c.LocalRegistry.Node {
+		checkers = append(checkers, portCheck{port: c.LocalRegistry.Port})
+	}
+	if c.Distribution.Mode == constants.DistributionModeP2P {
+		checkers = append(checkers, portCheck{port: c.Distribution.Port})
+	}
+	return checkers
+}
+
+// clusterCheckers returns the checks of all nodes.
+func clusterCheckers(c *rundata.Cluster) []ClusterChecker {
+	checkers := []ClusterChecker{
+		duplicateCheck{name: "ProductUUID", label: "product_uuid", value: func(f *Facts) []string { return []string{f.ProductUUID} }},
+		duplicateCheck{name: "MAC", label: "MAC address", value: func(f *Facts) []string { return f.MACs }},
+		duplicateCheck{name: "Hostname", label: "hostname", value: func(f *Facts) []string { return []string{f.Hostname} }, warning: true},
+		timeSkewCheck{max: constants.DefaultPreflightMaxTimeSkew},
+	}
+
+	if c.LocalRegistry.Enable {
+		checkers = append(checkers, localRegistryArchCheck{node: c.LocalRegistry.Node})
+	}
+	return checkers
+}
+
+type osCheck struct{}
+
+func (osCheck) Name() string { return "OS" }
+
+func (osCheck) Check(f *Facts) ([]error, []error) {
+	if err := f.OS.Validate(); err != nil {
+		return nil, []error{err}
+	}
+	return nil, nil
+}
+
+type kernelCheck struct{}
+
+func (kernelCheck) Name() string { return "KernelVersion" }
+
+func (kernelCheck) Check(f *Facts) ([]error, []error) {
+	var major, minor int
+	if _, err := fmt.Sscanf(f.Kernel, "%d.%d", &major, &minor); err != nil {
+		return []error{errors.Errorf("unable to parse the kernel version %q", f.Kernel)}, nil
+	}
+	if major < minKernelMajor || major == minKernelMajor && minor < minKernelMinor {
+		return nil, []error{errors.Errorf("kernel %s is too old, %d.%d+ is required", f.Kernel, minKernelMajor, minKernelMinor)}
+	}
+	return nil, nil
+}
+
+type brNetfilterCheck struct{}
+
+func (brNetfilterCheck) Name() string { return "BrNetfilter" }
+
+func (brNetfilterCheck) Check(f *Facts) ([]error, []error) {
+	if !f.BrNetfilter {
+		return nil, []error{errors.New("the br_netfilter kernel module is not available, it is needed by kube-proxy and the network plugins")}
+	}
+	return nil, nil
+}
+
+// commandCheck checks that a command is in the PATH, the missing one is a warning if warning is true.
+type commandCheck struct {
+	name    string
+	warning bool
+}
+
+func (c commandCheck) Name() string { return "FileExisting-" + c.name }
+
+func (c commandCheck) Check(f *Facts) ([]error, []error) {
+	if f.Commands[c.name] {
+		return nil, nil
+	}
+	err := errors.Errorf("%s not found in system path", c.name)
+	if c.warning {
+		return []error{err}, nil
+	}
+	return nil, []error{err}
+}
+
+type portCheck struct {
+	port int
+}
+
+func (p portCheck) Name() string { return fmt.Sprintf("Port-%d", p.port) }
+
+func (p portCheck) Check(f *Facts) ([]error, []error) {
+	if f.Ports[p.port] {
+		return nil, []error{errors.Errorf("port %d is in use", p.port)}
+	}
+	return nil, nil
+}
+
+type numCPUCheck struct {
+	min int
+}
+
+func (numCPUCheck) Name() string { return "NumCPU" }
+
+func (n numCPUCheck) Check(f *Facts) ([]error, []error) {
+	if f.CPUs < n.min {
+		return nil, []error{errors.Errorf("the number of available CPUs %d is less than the required %d", f.CPUs, n.min)}
+	}
+	return nil, nil
+}
+
+type memCheck struct {
+	minMB int64
+}
+
+func (memCheck) Name() string { return "Mem" }
+
+func (m memCheck) Check(f *Facts) ([]error, []error) {
+	if mb := f.MemoryKB / 1024; mb < m.minMB {
+		return nil, []error{errors.Errorf("the system RAM (%d MB) is less than the minimum %d MB", mb, m.minMB)}
+	}
+	return nil, nil
+}
+
+// duplicateCheck checks that the values of the nodes are unique.
+type duplicateCheck struct {
+	name    string
+	label   string
+	value   func(f *Facts) []string
+	warning bool
+}
+
+func (d duplicateCheck) Name() string { return "Duplicate" + d.name }
+
+func (d duplicateCheck) Check(facts []*Facts) []Issue {
+	hosts := map[string][]string{}
+	for _, f := range facts {
+		for _, v := range d.value(f) {
+			// a node can have the same MAC on several interfaces, e.g. the slaves of a bond
+			if hs := hosts[v]; v != "" && (len(hs) == 0 || hs[len(hs)-1] != f.Host) {
+				hosts[v] = append(hs, f.Host)
+			}
+		}
+	}
+
+	level := LevelError
+	if d.warning {
+		level = LevelWarning
+	}
+	var issues []Issue
+	for v, hs := range hosts {
+		if len(hs) < 2 {
+			continue
+		}
+		for _, h := range hs {
+			issues = append(issues, Issue{
+				Host:    h,
+				Check:   d.Name(),
+				Level:   level,
+				Message: fmt.Sprintf("%s %s is the same on %s", d.label, v, strings.Join(hs, ", ")),
+			})
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Message < issues[j].Message })
+	return issues
+}
+
+// timeSkewCheck checks the clocks of the nodes, the certificates are signed by kubei with the local clock.
+type timeSkewCheck struct {
+	max time.Duration
+}
+
+func (timeSkewCheck) Name() string { return "TimeSkew" }
+
+func (t timeSkewCheck) Check(facts []*Facts) []Issue {
+	var issues []Issue
+	for _, f := range facts {
+		if f.TimeSkew > t.max || f.TimeSkew < -t.max {
+			issues = append(issues, Issue{
+				Host:    f.Host,
+				Check:   t.Name(),
+				Level:   LevelError,
+				Message: fmt.Sprintf("the clock differs from the local clock by %v, more than %v", f.TimeSkew, t.max),
+			})
+		}
+	}
+	return issues
+}
+
+// localRegistryArchCheck checks that all nodes have the same arch as the local registry node,
+// only the images of its arch are pushed to the local registry.
+type localRegistryArchCheck struct {
+	node string
+}
+
+func (localRegistryArchCheck) Name() string { return "LocalRegistryArch" }
+
+func (l localRegistryArchCheck) Check(facts []*Facts) []Issue {
+	var arch string
+	for _, f := range facts {
+		if f.Host == l.node {
+			arch = f.OS.Arch
+		}
+	}
+	if arch == "" {
+		return nil
+	}
+
+	var issues []Issue
+	for _, f := range facts {
+		if f.OS.Arch != "" && f.OS.Arch != arch {
+			issues = append(issues, Issue{
+				Host:    f.Host,
+				Check:   l.Name(),
+				Level:   LevelError,
+				Message: fmt.Sprintf("the arch %s differs from %s of the local registry node %s, the local registry can't serve a mixed-architecture cluster", f.OS.Arch, arch, l.node),
+			})
+		}
+	}
+	return issues
+}
+
+// runCheckers runs the checks on the facts of all nodes, the ignored errors become LevelIgnored.
+func runCheckers(c *rundata.Cluster, facts map[string]*Facts, ignore map[string]bool) []Issue {
+	var issues []Issue
+	var all []*Facts
+
+	for _, node := range c.ClusterNodes.GetAllNodes() {
+		f, ok := facts[node.HostInfo.Host]
+		if !ok {
+			continue
+		}
+		all = append(all, f)
+
+		isMaster := false
+		for _, m := range c.ClusterNodes.Masters {
+			isMaster = isMaster || m == node
+		}
+		for _, checker := range nodeCheckers(node, isMaster, c) {
+			warnings, errs := checker.Check(f)
+			for _, w := range warnings {
+				issues = append(issues, Issue{Host: f.Host, Check: checker.Name(), Level: LevelWarning, Message: w.Error()})
+			}
+			for _, e := range errs {
+				issues = append(issues, Issue{Host: f.Host, Check: checker.Name(), Level: LevelError, Message: e.Error()})
+			}
+		}
+	}
+
+	for _, checker := range clusterCheckers(c) {
+		issues = append(issues, checker.Check(all)...)
+	}
+
+	for i := range issues {
+		if issues[i].Level == LevelError && (ignore["all"] || ignore[strings.ToLower(issues[i].Check)]) {
+			issues[i].Level = LevelIgnored
+		}
+	}
+	return issues
+}
@@ -2,7 +2,12 @@ package preflight
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/pkg/errors"
@@ -11,29 +16,123 @@ import (
 	"github.com/yuyicai/kubei/internal/constants"
 	"github.com/yuyicai/kubei/internal/operator"
 	"github.com/yuyicai/kubei/internal/rundata"
+	"github.com/yuyicai/kubei/pkg/executor"
 	"github.com/yuyicai/kubei/pkg/ssh"
 )
 
+// InitPrepare connects to all nodes and detects their OS, the host checks are run by the preflight phase of init,
+// so that a single phase can be run again on the nodes of a running cluster.
 func InitPrepare(c *rundata.Cluster) error {
+	if c.DryRun.Enable {
+		return dryRunPrepare(c)
+	}
+
 	color.HiBlue("Checking SSH connect 🌐")
-	return operator.RunOnAllNodes(c, func(node *rundata.Node, c *rundata.Cluster) error {
+	if err := operator.RunOnAllNodes(c, func(node *rundata.Node, c *rundata.Cluster) error {
 		if err := setSSH(node, c.Kubei); err != nil {
 			return err
 		}
-		if err := checkCommandConntrack(node); err != nil {
-			return err
+		return detectOS(node)
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RunChecks gathers the facts of all nodes in parallel and checks them before any node is changed,
+// all the warnings and errors are printed in a table.
+func RunChecks(c *rundata.Cluster) error {
+	color.HiBlue("Running preflight checks 🔍")
+
+	var mu sync.Mutex
+	facts := map[string]*Facts{}
+	if err := operator.RunOnAllNodes(c, func(node *rundata.Node, c *rundata.Cluster) error {
+		klog.V(2).Infof("[%s] [preflight] Gathering the host facts", node.HostInfo.Host)
+		output, err := node.RunOut(factsCmd)
+		if err != nil {
+			return errors.Wrapf(err, "[%s] [preflight] Failed to gather the host facts", node.HostInfo.Host)
 		}
-		return checkPackageManagementType(node)
-	})
+		f, err := parseFacts(node.HostInfo.Host, output, time.Now())
+		if err != nil {
+			return errors.Wrapf(err, "[%s] [preflight] Failed to parse the host facts", node.HostInfo.Host)
+		}
+		f.OS = node.OS
+
+		mu.Lock()
+		defer mu.Unlock()
+		facts[node.HostInfo.Host] = f
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	ignore := map[string]bool{}
+	for _, name := range c.IgnorePreflightErrors {
+		ignore[strings.ToLower(name)] = true
+	}
+	issues := runCheckers(c, facts, ignore)
+	printIssues(os.Stdout, issues)
+
+	var failed []string
+	for _, issue := range issues {
+		if issue.Level == LevelError {
+			failed = append(failed, issue.Check)
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("[preflight] %d preflight check(s) failed, fix them or ignore them with --ignore-preflight-errors=%s",
+			len(failed), strings.Join(uniqueStrings(failed), ","))
+	}
+
+	fmt.Printf("[preflight] preflight checks: %s\n", color.HiGreenString("done✅️"))
+	return nil
+}
+
+// printIssues prints the issues in a table, nothing is printed if there is no issue.
+func printIssues(out io.Writer, issues []Issue) {
+	if len(issues) == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tCHECK\tLEVEL\tMESSAGE")
+	for _, issue := range issues {
+		level := issue.Level
+		switch level {
+		case LevelError:
+			level = color.HiRedString(level)
+		case LevelWarning, LevelIgnored:
+			level = color.HiYellowString(level)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", issue.Host, issue.Check, level, issue.Message)
+	}
+	w.Flush()
+}
+
+func uniqueStrings(s []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
 }
 
 func ResetPrepare(c *rundata.Cluster) error {
+	if c.DryRun.Enable {
+		return dryRunPrepare(c)
+	}
+
 	color.HiBlue("Checking SSH connect 🌐")
 	return operator.RunOnAllNodes(c, func(node *rundata.Node, c *rundata.Cluster) error {
 		if err := setSSH(node, c.Kubei); err != nil {
 			return err
 		}
-		return checkPackageManagementType(node)
+		return detectOS(node)
 	})
 }
 
@@ -41,20 +140,78 @@ func ExecPrepare(c *rundata.Cluster) error {
 	if err := nodesExistCheck(c); err != nil {
 		return err
 	}
+	if c.DryRun.Enable {
+		return dryRunPrepare(c)
+	}
+
 	color.HiBlue("Checking SSH connect 🌐")
 	return operator.RunOnAllNodes(c, func(node *rundata.Node, c *rundata.Cluster) error {
 		return setSSH(node, c.Kubei)
 	})
 }
 
-func CloseSSH(c *rundata.Cluster) error {
+// dryRunPrepare replaces the ssh connections of the nodes with recorders, the nodes are neither connected nor checked,
+// and the commands are rendered for the OS of --dry-run-os.
+func dryRunPrepare(c *rundata.Cluster) error {
+	color.HiBlue("Dry run, recording the commands instead of running them 📝")
+	return operator.RunOnAllNodes(c, func(node *rundata.Node, c *rundata.Cluster) error {
+		node.Executor = rundata.NewRecorder(node.HostInfo.Host, c.DryRun.Dir, os.Stdout)
+		node.OS = c.DryRun.OS
+		node.PackageManagementType = node.OS.PackageManagementType()
+		fmt.Printf("[%s] [preflight] dry run as %q: %s\n", node.HostInfo.Host, node.OS, color.HiGreenString("done✅️"))
+		return nil
+	})
+}
+
+func CertsPrepare(c *rundata.Cluster) error {
+	if err := mastersExistCheck(c); err != nil {
+		return err
+	}
+	color.HiBlue("Checking SSH connect 🌐")
+	return operator.RunOnMasters(c, func(node *rundata.Node, c *rundata.Cluster) error {
+		return setSSH(node, c.Kubei)
+	})
+}
+
+// CloseExecutors closes the executors of the nodes, e.g. the ssh connections.
+func CloseExecutors(c *rundata.Cluster) error {
 	return operator.RunOnAllNodes(c, func(node *rundata.Node, c *rundata.Cluster) error {
-		klog.V(1).Infof("[%s][close] Close ssh connect", node.HostInfo.Host)
-		return node.SSH.Close()
+		if node.Executor == nil {
+			return nil
+		}
+		klog.V(1).Infof("[%s][close] Close the %s executor", node.HostInfo.Host, executorName(node))
+		return node.Executor.Close()
 	})
 }
 
+func executorName(node *rundata.Node) string {
+	switch {
+	case node.DryRun():
+		return "dry-run"
+	case node.HostInfo.Executor == "":
+		return constants.ExecutorSSH
+	default:
+		return node.HostInfo.Executor
+	}
+}
+
 func setSSH(node *rundata.Node, cfg *rundata.Kubei) error {
+	// the local and docker executors don't connect to the node
+	switch node.HostInfo.Executor {
+	case constants.ExecutorLocal:
+		if node.Executor == nil {
+			node.Executor = executor.NewLocal(node.HostInfo.Host, becomePassword(node.HostInfo))
+		}
+		fmt.Printf("[%s] [preflight] local executor: %s\n", node.HostInfo.Host, color.HiGreenString("done✅️"))
+		return nil
+	case constants.ExecutorDocker:
+		if node.Executor == nil {
+			node.Executor = executor.NewDocker(node.HostInfo.Host, node.HostInfo.Container)
+		}
+		fmt.Printf("[%s] [preflight] docker executor (container %s): %s\n", node.HostInfo.Host, node.HostInfo.Container, color.HiGreenString("done✅️"))
+		return nil
+	}
+
 	if err := setJumpServer(&cfg.JumpServer); err != nil {
 		return fmt.Errorf("[preflight] Failed to set jump server: %v", err)
 	}
@@ -81,78 +238,64 @@ func setJumpServer(jumpServer *rundata.JumpServer) error {
 	return nil
 }
 
-func checkCommandConntrack(node *rundata.Node) error {
-	// https://github.com/kubernetes/kubernetes/blob/v1.18.0/cmd/kubeadm/app/preflight/checks.go#L1020
-	tmp := `
-if command -v %s >/dev/null 2>&1; then 
-  echo 'true' 
-else 
-  echo 'false' 
-fi
-`
-	out, err := node.RunOut(fmt.Sprintf(tmp, "conntrack"))
-	if err != nil {
-		return err
-	}
-
-	status := string(out)
-
-	if strings.Contains(status, "true") {
-		return nil
-	}
-	if strings.Contains(status, "false") {
-		klog.V(8).Info("conntrack no exists")
-		return errors.Errorf("can not find command: %s. you can install conntrack wiht \"yum install -y conntrack (CentOS) or apt update && apt install -y conntrack (Ubuntu)\"", "conntrack")
-	}
-	klog.V(8).Info("conntrack exists")
-	return nil
-}
-
 func setSSHConnect(node *rundata.Node, jumpServer *rundata.JumpServer) error {
-	if node.SSH == nil {
+	if node.Executor == nil {
 		return setNodeSSHConnect(node, jumpServer)
 	}
 	return nil
 }
 
 func setNodeSSHConnect(node *rundata.Node, jumpServer *rundata.JumpServer) error {
+	var client *ssh.Client
 	var err error
 	userInfo := node.HostInfo
 	//Set up ssh connection through jump server
 	if jumpServer.HostInfo.Host != "" {
 		fmt.Printf("[%s] [preflight] SSH connect (through jump server %s\n): %s", userInfo.Host, jumpServer.HostInfo.Host, color.HiGreenString("done✅️"))
-		node.SSH, err = ssh.ConnectByJumpServer(userInfo.Host, userInfo.Port, userInfo.User, userInfo.Password, userInfo.Key, jumpServer.Client)
-		return err
+		client, err = ssh.ConnectByJumpServer(userInfo.Host, userInfo.Port, userInfo.User, userInfo.Password, userInfo.Key, jumpServer.Client)
 	} else {
 		//Set up ssh connection direct
-		node.SSH, err = ssh.Connect(userInfo.Host, userInfo.Port, userInfo.User, userInfo.Password, userInfo.Key)
+		client, err = ssh.Connect(userInfo.Host, userInfo.Port, userInfo.User, userInfo.Password, userInfo.Key)
+	}
+	if err != nil {
 		return err
 	}
+	client.SetBecome(ssh.Become{Method: userInfo.Become, Password: userInfo.BecomePassword})
+	// a nil *ssh.Client must not be set as a non-nil executor
+	node.Executor = client
+	return nil
+}
+
+// becomePassword returns the password asked by sudo, it is the ssh password if --become-password is empty
+func becomePassword(h rundata.HostInfo) string {
+	if h.BecomePassword != "" {
+		return h.BecomePassword
+	}
+	return h.Password
 }
 
-func checkPackageManagementType(node *rundata.Node) error {
+// detectOS detects the distro, version and architecture of the node, the install strategy of the node is
+// selected by them. The unsupported versions and architectures are rejected by the OS preflight check.
+func detectOS(node *rundata.Node) error {
 	hostInfo := node.HostInfo
 
-	klog.V(2).Infof("[%s] [preflight] Checking package management", hostInfo.Host)
-	output, err := node.RunOut("cat /proc/version")
+	klog.V(2).Infof("[%s] [preflight] Detecting the OS", hostInfo.Host)
+	output, err := node.RunOut("uname -m; cat /etc/os-release")
 	if err != nil {
-		return err
+		return errors.Wrapf(err, "[%s] [preflight] Failed to detect the OS", hostInfo.Host)
 	}
 
-	outputStr := string(output)
-	switch true {
-	case strings.Contains(outputStr, "Debian"):
-		klog.V(5).Infof("[%s] [preflight] The package management is \"apt\"", hostInfo.Host)
-		node.PackageManagementType = constants.PackageManagementTypeApt
-	case strings.Contains(outputStr, "Ubuntu"):
-		klog.V(5).Infof("[%s] [preflight] The package management is \"apt\"", hostInfo.Host)
-		node.PackageManagementType = constants.PackageManagementTypeApt
-	case strings.Contains(outputStr, "Red"):
-		klog.V(5).Infof("[%s] [preflight] The package management is \"yum\"", hostInfo.Host)
-		node.PackageManagementType = constants.PackageManagementTypeYum
-	default:
-		return fmt.Errorf("[%s] [preflight] Unsupported this system", hostInfo.Host)
+	lines := strings.SplitN(string(output), "\n", 2)
+	if len(lines) != 2 {
+		return fmt.Errorf("[%s] [preflight] Failed to detect the OS: unexpected output %q", hostInfo.Host, output)
 	}
+	node.OS = rundata.ParseOSRelease(lines[1], lines[0])
+	node.PackageManagementType = node.OS.PackageManagementType()
+	if node.PackageManagementType == "" {
+		return fmt.Errorf("[%s] [preflight] Unsupported OS %q", hostInfo.Host, node.OS)
+	}
+
+	klog.V(5).Infof("[%s] [preflight] The OS is %q, the package management is %q", hostInfo.Host, node.OS, node.PackageManagementType)
 	return nil
 }
 
@@ -0,0 +1,80 @@
+package preflight
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yuyicai/kubei/internal/constants"
+	"github.com/yuyicai/kubei/internal/rundata"
+	"github.com/yuyicai/kubei/pkg/ssh/sshtest"
+)
+
+func TestDetectOS(t *testing.T) {
+	s := sshtest.NewServer(t, "ubuntu", "secret")
+	s.Reply(`^uname -m; cat /etc/os-release$`, "x86_64\nNAME=\"Ubuntu\"\nID=ubuntu\nID_LIKE=debian\nVERSION_ID=\"20.04\"\nVERSION_CODENAME=focal\n", 0)
+
+	node := &rundata.Node{HostInfo: rundata.HostInfo{Host: s.Host(), Port: s.Port(), User: "ubuntu", Password: "secret"}}
+	if err := setSSH(node, rundata.NewKubei()); err != nil {
+		t.Fatal(err)
+	}
+	defer node.Executor.Close()
+
+	if err := detectOS(node); err != nil {
+		t.Fatal(err)
+	}
+	if got := node.OS.String(); got != "ubuntu 20.04 amd64" {
+		t.Errorf("OS = %q, want %q", got, "ubuntu 20.04 amd64")
+	}
+	if node.PackageManagementType != constants.PackageManagementTypeApt {
+		t.Errorf("PackageManagementType = %q, want %q", node.PackageManagementType, constants.PackageManagementTypeApt)
+	}
+	if cmds := s.Commands(); len(cmds) != 1 || cmds[0].Become != "sudo" {
+		t.Errorf("commands = %+v, want one command with sudo", cmds)
+	}
+}
+
+func TestSetSSHBecome(t *testing.T) {
+	s := sshtest.NewServer(t, "alice", "secret")
+	s.BecomePassword = "rootpw"
+	s.Reply(`^uname -m; cat /etc/os-release$`, "aarch64\nID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\nVERSION_ID=\"8.5\"\n", 0)
+
+	node := &rundata.Node{HostInfo: rundata.HostInfo{
+		Host: s.Host(), Port: s.Port(), User: "alice", Password: "secret", Become: "su", BecomePassword: "rootpw",
+	}}
+	if err := setSSH(node, rundata.NewKubei()); err != nil {
+		t.Fatal(err)
+	}
+	defer node.Executor.Close()
+
+	if err := detectOS(node); err != nil {
+		t.Fatal(err)
+	}
+	if got := node.OS.String(); got != "rocky 8.5 arm64" {
+		t.Errorf("OS = %q, want %q", got, "rocky 8.5 arm64")
+	}
+	if cmds := s.Commands(); len(cmds) != 1 || cmds[0].Become != "su" || !cmds[0].TTY {
+		t.Errorf("commands = %+v, want one command by su with a pty", cmds)
+	}
+}
+
+func TestDetectOSFailed(t *testing.T) {
+	s := sshtest.NewServer(t, "root", "secret")
+	s.Reply(`^uname -m; cat /etc/os-release$`, "x86_64\nID=gentoo\n", 0)
+
+	node := &rundata.Node{HostInfo: rundata.HostInfo{Host: s.Host(), Port: s.Port(), User: "root", Password: "secret"}}
+	if err := setSSH(node, rundata.NewKubei()); err != nil {
+		t.Fatal(err)
+	}
+	defer node.Executor.Close()
+
+	if err := detectOS(node); err == nil || !strings.Contains(err.Error(), "Unsupported OS") {
+		t.Errorf("detectOS() = %v, want the unsupported OS error", err)
+	}
+
+	// the connection is refused after the server is closed
+	s.Close()
+	node = &rundata.Node{HostInfo: rundata.HostInfo{Host: s.Host(), Port: s.Port(), User: "root", Password: "secret"}}
+	if err := setSSH(node, rundata.NewKubei()); err == nil {
+		t.Error("setSSH() to a closed server, want error")
+	}
+}
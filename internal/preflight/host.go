@@ -0,0 +1,118 @@
+package preflight
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lithammer/dedent"
+
+	"github.com/yuyicai/kubei/internal/rundata"
+)
+
+// factsCmd gathers everything the checks need from a node in one command, the checks don't run commands themselves.
+// Every line is key=value, and the keys mac, port and command can repeat.
+var factsCmd = dedent.Dedent(`
+	echo "hostname=$(hostname)"
+	echo "kernel=$(uname -r)"
+	echo "cpus=$(nproc 2>/dev/null || grep -c ^processor /proc/cpuinfo)"
+	echo "memory_kb=$(awk '/^MemTotal:/ {print $2}' /proc/meminfo)"
+	echo "product_uuid=$(cat /sys/class/dmi/id/product_uuid 2>/dev/null)"
+	for i in /sys/class/net/*; do
+	  [ -e "$i/device" ] && echo "mac=$(cat "$i/address")"
+	done
+	if command -v ss >/dev/null 2>&1; then
+	  ss -ltn | tail -n +2
+	else
+	  netstat -ltn 2>/dev/null | tail -n +3
+	fi | awk '{print $4}' | sed 's/.*://' | sort -un | sed 's/^/port=/'
+	for c in conntrack ip iptables mount nsenter ebtables ethtool socat tc; do
+	  command -v $c >/dev/null 2>&1 && echo "command=$c"
+	done
+	if lsmod 2>/dev/null | grep -q '^br_netfilter' || [ -d /proc/sys/net/bridge ] || modinfo br_netfilter >/dev/null 2>&1; then
+	  echo "br_netfilter=true"
+	fi
+	echo "time=$(date +%s)"
+`)
+
+// Facts are the facts of a node used by the preflight checks.
+type Facts struct {
+	Host     string
+	Hostname string
+	Kernel   string
+	// OS is detected before the facts are gathered
+	OS          rundata.OS
+	CPUs        int
+	MemoryKB    int64
+	ProductUUID string
+	MACs        []string
+	// Ports are the listening tcp ports
+	Ports       map[int]bool
+	Commands    map[string]bool
+	BrNetfilter bool
+	// TimeSkew is the difference between the clock of the node and the local clock
+	TimeSkew time.Duration
+}
+
+// parseFacts parses the output of factsCmd, now is the local time when the command returns.
+func parseFacts(host string, output []byte, now time.Time) (*Facts, error) {
+	f := &Facts{
+		Host:     host,
+		Ports:    map[int]bool{},
+		Commands: map[string]bool{},
+	}
+
+	var remoteTime int64
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		kv := strings.SplitN(strings.TrimSpace(scanner.Text()), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := kv[0], strings.TrimSpace(kv[1])
+
+		var err error
+		switch key {
+		case "hostname":
+			f.Hostname = value
+		case "kernel":
+			f.Kernel = value
+		case "cpus":
+			f.CPUs, err = strconv.Atoi(value)
+		case "memory_kb":
+			f.MemoryKB, err = strconv.ParseInt(value, 10, 64)
+		case "product_uuid":
+			f.ProductUUID = strings.ToLower(value)
+		case "mac":
+			if value != "" && value != "00:00:00:00:00:00" {
+				f.MACs = append(f.MACs, strings.ToLower(value))
+			}
+		case "port":
+			var port int
+			if port, err = strconv.Atoi(value); err == nil {
+				f.Ports[port] = true
+			}
+		case "command":
+			f.Commands[value] = true
+		case "br_netfilter":
+			f.BrNetfilter = value == "true"
+		case "time":
+			remoteTime, err = strconv.ParseInt(value, 10, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %v", key, value, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if remoteTime > 0 {
+		f.TimeSkew = time.Unix(remoteTime, 0).Sub(now.Truncate(time.Second))
+	}
+	sort.Strings(f.MACs)
+	return f, nil
+}
@@ -0,0 +1,118 @@
+package preflight
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yuyicai/kubei/internal/rundata"
+)
+
+func TestParseFacts(t *testing.T) {
+	output := []byte(`hostname=node1
+kernel=5.4.0-42-generic
+cpus=4
+memory_kb=8023568
+product_uuid=4C4C4544-0042-3510-8054-B4C04F4E4432
+mac=52:54:00:AB:CD:EF
+mac=00:00:00:00:00:00
+port=22
+port=6443
+command=conntrack
+command=iptables
+br_netfilter=true
+time=1600000030
+`)
+	now := time.Unix(1600000000, 500)
+
+	f, err := parseFacts("10.0.0.1", output, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Facts{
+		Host:        "10.0.0.1",
+		Hostname:    "node1",
+		Kernel:      "5.4.0-42-generic",
+		CPUs:        4,
+		MemoryKB:    8023568,
+		ProductUUID: "4c4c4544-0042-3510-8054-b4c04f4e4432",
+		MACs:        []string{"52:54:00:ab:cd:ef"},
+		Ports:       map[int]bool{22: true, 6443: true},
+		Commands:    map[string]bool{"conntrack": true, "iptables": true},
+		BrNetfilter: true,
+		TimeSkew:    30 * time.Second,
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("parseFacts() = %+v, want %+v", f, want)
+	}
+
+	if _, err := parseFacts("10.0.0.1", []byte("cpus=many\n"), now); err == nil {
+		t.Error("parseFacts() with invalid cpus, want error")
+	}
+}
+
+func TestCheckers(t *testing.T) {
+	f := &Facts{
+		Host:     "10.0.0.1",
+		Kernel:   "3.8.13",
+		OS:       rundata.OS{ID: "rocky", IDLike: []string{"rhel", "centos", "fedora"}, Version: "8.5", Arch: "amd64"},
+		CPUs:     1,
+		MemoryKB: 1024 * 1024,
+		Ports:    map[int]bool{10250: true},
+		Commands: map[string]bool{},
+	}
+
+	tests := []struct {
+		checker  Checker
+		warnings int
+		errors   int
+	}{
+		{osCheck{}, 0, 0},
+		{kernelCheck{}, 0, 1},
+		{brNetfilterCheck{}, 0, 1},
+		{commandCheck{name: "conntrack"}, 0, 1},
+		{commandCheck{name: "socat", warning: true}, 1, 0},
+		{portCheck{port: 10250}, 0, 1},
+		{portCheck{port: 6443}, 0, 0},
+		{numCPUCheck{min: controlPlaneNumCPU}, 0, 1},
+		{memCheck{minMB: controlPlaneMemMB}, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.checker.Name(), func(t *testing.T) {
+			warnings, errs := tt.checker.Check(f)
+			if len(warnings) != tt.warnings || len(errs) != tt.errors {
+				t.Errorf("Check() = %v, %v, want %d warnings and %d errors", warnings, errs, tt.warnings, tt.errors)
+			}
+		})
+	}
+}
+
+func TestClusterCheckers(t *testing.T) {
+	facts := []*Facts{
+		{Host: "10.0.0.1", Hostname: "node", ProductUUID: "a", MACs: []string{"52:54:00:00:00:01", "52:54:00:00:00:01"}, OS: rundata.OS{Arch: "amd64"}},
+		{Host: "10.0.0.2", Hostname: "node", ProductUUID: "a", MACs: []string{"52:54:00:00:00:02"}, OS: rundata.OS{Arch: "amd64"}, TimeSkew: -time.Minute},
+		{Host: "10.0.0.3", Hostname: "node3", ProductUUID: "b", MACs: []string{"52:54:00:00:00:03"}, OS: rundata.OS{Arch: "arm64"}},
+	}
+
+	c := rundata.NewCluster()
+	c.LocalRegistry.Enable = true
+	c.LocalRegistry.Node = "10.0.0.1"
+
+	var issues []Issue
+	for _, checker := range clusterCheckers(c) {
+		issues = append(issues, checker.Check(facts)...)
+	}
+
+	want := []Issue{
+		{Host: "10.0.0.1", Check: "DuplicateProductUUID", Level: LevelError, Message: "product_uuid a is the same on 10.0.0.1, 10.0.0.2"},
+		{Host: "10.0.0.2", Check: "DuplicateProductUUID", Level: LevelError, Message: "product_uuid a is the same on 10.0.0.1, 10.0.0.2"},
+		{Host: "10.0.0.1", Check: "DuplicateHostname", Level: LevelWarning, Message: "hostname node is the same on 10.0.0.1, 10.0.0.2"},
+		{Host: "10.0.0.2", Check: "DuplicateHostname", Level: LevelWarning, Message: "hostname node is the same on 10.0.0.1, 10.0.0.2"},
+		{Host: "10.0.0.2", Check: "TimeSkew", Level: LevelError, Message: "the clock differs from the local clock by -1m0s, more than 30s"},
+		{Host: "10.0.0.3", Check: "LocalRegistryArch", Level: LevelError, Message: "the arch arm64 differs from amd64 of the local registry node 10.0.0.1, the local registry can't serve a mixed-architecture cluster"},
+	}
+	if !reflect.DeepEqual(issues, want) {
+		t.Errorf("cluster checks = %+v, want %+v", issues, want)
+	}
+}
@@ -47,7 +47,6 @@ func (c *Cert) GetConfig(node *Node, ic *kubeadmapi.InitConfiguration) (*pkiutil
 		}
 	}
 
-	c.Config.PublicKeyAlgorithm = ic.ClusterConfiguration.PublicKeyAlgorithm()
 	return &c.Config, nil
 }
 
@@ -78,6 +77,21 @@ func (c *Cert) CreateAsCA(node *Node, ic *kubeadmapi.InitConfiguration) error {
 	return nil
 }
 
+// CreateCSR creates a key and a certificate signing request, for the certificate to be signed by an external CA.
+func (c *Cert) CreateCSR(node *Node, ic *kubeadmapi.InitConfiguration) (*x509.CertificateRequest, error) {
+	cfg, err := c.GetConfig(node, ic)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't get configuration for %q certificate", c.Name)
+	}
+	csr, key, err := pkiutil.NewCSRAndKey(cfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't generate %q certificate signing request", c.Name)
+	}
+	c.Key = key
+
+	return csr, nil
+}
+
 func (c *Cert) CreateKubeConfig(ic *kubeadmapi.InitConfiguration, caCert *x509.Certificate) error {
 
 	if !c.IsKubeConfig {
@@ -109,8 +123,13 @@ func (c *Cert) CreateKubeConfig(ic *kubeadmapi.InitConfiguration, caCert *x509.C
 type CertificateTree map[*Cert]Certificates
 
 // Create creates the CAs, certs signed by the CAs.
+// The certs of an external CA, which has a cert but no key, are not created.
 func (t CertificateTree) Create(node *Node, ic *kubeadmapi.InitConfiguration, notAfterTime time.Duration) error {
 	for ca, leaves := range t {
+		if ca.Cert != nil && ca.Key == nil {
+			continue
+		}
+
 		if ca.Cert == nil {
 
 			if notAfterTime < constants.DefaultCertNotAfterTime {
@@ -433,6 +452,17 @@ func GetDefaultCertList() Certificates {
 	}
 }
 
+// GetDefaultCAList returns the certificate authorities of the default cert list
+func GetDefaultCAList() Certificates {
+	var cas Certificates
+	for _, cert := range GetDefaultCertList() {
+		if cert.CAName == "" {
+			cas = append(cas, cert)
+		}
+	}
+	return cas
+}
+
 func makeAltNamesMutator(f func(*Node, *kubeadmapi.InitConfiguration) (*certutil.AltNames, error)) ConfigMutatorsFunc {
 	return func(node *Node, mc *kubeadmapi.InitConfiguration, cc *pkiutil.CertConfig) error {
 		altNames, err := f(node, mc)
@@ -1,8 +1,16 @@
 package rundata
 
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
 type ContainerEngine struct {
-	Type   string
-	Docker Docker
+	Type       string
+	Docker     Docker
+	Registries Registries
 }
 
 type Docker struct {
@@ -12,3 +20,60 @@ type Docker struct {
 	LogOptsMaxSize string
 	StorageDriver  string
 }
+
+// Registries is the config of the registries which the container engines pull the images from,
+// the keys of the maps are the registries, e.g. docker.io or registry.example.com:5000.
+type Registries struct {
+	// Mirrors are the mirrors of the upstream registries, the mirrors are tried in order before the upstream one
+	Mirrors map[string][]string
+	// Insecure are the registries accessed by http or by https without verifying the certificate
+	Insecure []string
+	// Auths are the credentials of the registries used by kubelet to pull the images, they are read from AuthFiles
+	Auths map[string]RegistryAuth
+	// AuthFiles are the local paths to the files of <username>:<password> of the registries,
+	// so that the passwords are not in the command line
+	AuthFiles map[string]string
+	// CAs are the local paths to the CA certificates of the registries, they are copied to every node
+	CAs map[string]string
+}
+
+type RegistryAuth struct {
+	Username string
+	Password string
+}
+
+// ReadRegistryAuth reads the credentials of a registry from the file of <username>:<password>.
+func ReadRegistryAuth(file string) (RegistryAuth, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return RegistryAuth{}, err
+	}
+
+	userPassword := strings.SplitN(strings.TrimRight(string(data), "\r\n"), ":", 2)
+	if len(userPassword) != 2 || userPassword[0] == "" || userPassword[1] == "" {
+		return RegistryAuth{}, errors.Errorf("the content of %s must be <username>:<password>", file)
+	}
+	return RegistryAuth{Username: userPassword[0], Password: userPassword[1]}, nil
+}
+
+// NormalizeRegistry returns the registry without the scheme and the path,
+// docker.io is used for all the names of Docker Hub.
+func NormalizeRegistry(registry string) string {
+	registry = strings.TrimPrefix(strings.TrimPrefix(registry, "https://"), "http://")
+	registry = strings.SplitN(registry, "/", 2)[0]
+	switch registry {
+	case "index.docker.io", "registry-1.docker.io":
+		return "docker.io"
+	}
+	return registry
+}
+
+// IsInsecure returns true if the registry is an insecure registry.
+func (r *Registries) IsInsecure(registry string) bool {
+	for _, i := range r.Insecure {
+		if NormalizeRegistry(i) == NormalizeRegistry(registry) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package rundata
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadRegistryAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    RegistryAuth
+		wantErr bool
+	}{
+		{name: "username and password", content: "admin:password\n", want: RegistryAuth{Username: "admin", Password: "password"}},
+		{name: "password with colon", content: "admin:pass:word", want: RegistryAuth{Username: "admin", Password: "pass:word"}},
+		{name: "no password", content: "admin\n", wantErr: true},
+		{name: "empty password", content: "admin:\n", wantErr: true},
+		{name: "empty", content: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "auth")
+			if err := ioutil.WriteFile(file, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ReadRegistryAuth(file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadRegistryAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReadRegistryAuth() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ReadRegistryAuth(filepath.Join(t.TempDir(), "absent")); err == nil {
+		t.Error("ReadRegistryAuth() of an absent file, want error")
+	}
+}
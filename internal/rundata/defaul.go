@@ -1,6 +1,12 @@
 package rundata
 
-import "github.com/yuyicai/kubei/internal/constants"
+import (
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/yuyicai/kubei/internal/constants"
+)
 
 func DefaultkubeadmCfg(k *Kubeadm, ki *Kubei) {
 	if k.LocalAPIEndpoint.BindPort == 0 {
@@ -14,6 +20,10 @@ func DefaultkubeadmCfg(k *Kubeadm, ki *Kubei) {
 		setToEmptyString(&k.LocalAPIEndpoint.AdvertiseAddress, ki.ClusterNodes.Masters[0].HostInfo.Host)
 	}
 
+	if ki.LocalRegistry.Enable {
+		k.ImageRepository = ki.LocalRegistry.Address()
+	}
+
 }
 
 func DefaultKubeiCfg(k *Kubei) {
@@ -23,6 +33,79 @@ func DefaultKubeiCfg(k *Kubei) {
 	haCfg(&k.HA)
 	clusterNodesCfg(&k.ClusterNodes)
 	certCfg(&k.CertNotAfterTime)
+	setToEmptyString(&k.CertKeyAlgorithm, constants.DefaultCertKeyAlgorithm)
+	kubeconfigCfg(&k.Kubeconfig)
+	distributionCfg(&k.Distribution)
+	offlineBuildCfg(&k.OfflineBuild)
+	packageReposCfg(&k.PackageRepos)
+	localRegistryCfg(k)
+	dryRunCfg(&k.DryRun)
+}
+
+func dryRunCfg(d *DryRun) {
+	if d.Enable && d.OS.ID == "" {
+		d.OS = ParseOS(constants.DefaultDryRunOS)
+	}
+}
+
+func packageReposCfg(r *PackageRepos) {
+	setToEmptyString(&r.Docker.BaseURL, constants.DefaultDockerRepo)
+	setToEmptyString(&r.Kubernetes.BaseURL, constants.DefaultKubernetesRepo)
+}
+
+// localRegistryCfg moves the registry node to master0, and lets all the images be pulled from the registry
+func localRegistryCfg(k *Kubei) {
+	l := &k.LocalRegistry
+	if !l.Enable {
+		return
+	}
+
+	if l.Port == 0 {
+		l.Port = constants.DefaultLocalRegistryPort
+	}
+	setToEmptyString(&l.Image, constants.DefaultLocalRegistryImage)
+
+	masters := k.ClusterNodes.Masters
+	if len(masters) == 0 {
+		return
+	}
+	setToEmptyString(&l.Node, masters[0].HostInfo.Host)
+	for i, m := range masters {
+		if m.HostInfo.Host == l.Node {
+			masters[0], masters[i] = masters[i], masters[0]
+			break
+		}
+	}
+
+	// the registry is served by http
+	if !k.ContainerEngine.Registries.IsInsecure(l.Address()) {
+		k.ContainerEngine.Registries.Insecure = append(k.ContainerEngine.Registries.Insecure, l.Address())
+	}
+	k.NetworkPlugins.Flannel.Image.ImageRepository = l.Address()
+	k.HA.LocalSLB.Nginx.Image.ImageRepository = l.Address()
+}
+
+func offlineBuildCfg(o *OfflineBuild) {
+	if len(o.Archs) == 0 {
+		o.Archs = []string{constants.DefaultArch}
+	}
+}
+
+func distributionCfg(d *Distribution) {
+	setToEmptyString(&d.Mode, constants.DistributionModeDirect)
+	if d.Port == 0 {
+		d.Port = constants.DefaultDistributionPort
+	}
+	if d.Fanout == 0 {
+		d.Fanout = constants.DefaultDistributionFanout
+	}
+}
+
+func kubeconfigCfg(k *Kubeconfig) {
+	setToEmptyString(&k.Path, clientcmd.RecommendedHomeFile)
+	if k.ClientCertTTL == 0 {
+		k.ClientCertTTL = constants.DefaultKubeconfigClientCertTTL
+	}
 }
 
 func addonsCfg(a *Addons) {
@@ -106,6 +189,16 @@ func containerEngineCfg(c *ContainerEngine) {
 	}
 
 	dockerCfg(&c.Docker)
+	registriesCfg(&c.Registries)
+}
+
+func registriesCfg(r *Registries) {
+	// nil means the mirrors are not set, and an empty map means no mirror is used
+	if r.Mirrors == nil {
+		r.Mirrors = map[string][]string{
+			"docker.io": strings.Split(constants.DefaultDockerHubMirrors, ","),
+		}
+	}
 }
 
 func dockerCfg(d *Docker) {
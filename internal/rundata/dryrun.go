@@ -0,0 +1,81 @@
+package rundata
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/vbauerster/mpb/v6"
+)
+
+// DryRun renders all the commands of the nodes without connecting to them.
+type DryRun struct {
+	Enable bool
+	// Dir is the directory of the scripts, one sub directory per node, the scripts are printed if it is empty
+	Dir string
+	// OS is the OS of all nodes, it is used instead of the detected one to render the commands
+	OS OS
+}
+
+// outMu serializes the scripts printed by the nodes running in parallel
+var outMu sync.Mutex
+
+// Recorder records the commands of a node instead of running them, it is the executor of the nodes with --dry-run.
+type Recorder struct {
+	host string
+	dir  string
+	out  io.Writer
+
+	mu sync.Mutex
+	n  int
+}
+
+// NewRecorder returns the recorder of a node, the scripts are written to dir/<host>/ or printed to out if dir is empty.
+func NewRecorder(host, dir string, out io.Writer) *Recorder {
+	return &Recorder{host: host, dir: dir, out: out}
+}
+
+// Record records a command as a script of the node, in the order of the calls. The nodes run the same commands
+// with the stdin of /dev/null, by ssh the script is uploaded to a temp file and run by bash,
+// by sudo, su or doas if the user isn't root, and a marker line is printed to the stderr first.
+func (r *Recorder) Record(cmd string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.n++
+	script := fmt.Sprintf("#!/bin/bash\nexec </dev/null\n%s\n", strings.Trim(cmd, "\n"))
+	if r.dir == "" {
+		outMu.Lock()
+		defer outMu.Unlock()
+		_, err := fmt.Fprintf(r.out, "# [%s] [dry-run] %03d.sh\n%s\n", r.host, r.n, script)
+		return err
+	}
+
+	dir := filepath.Join(r.dir, r.host)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("%03d.sh", r.n)), []byte(script), 0644)
+}
+
+func (r *Recorder) Run(cmd string) error {
+	return r.Record(cmd)
+}
+
+// RunOut records the command, there is no output.
+func (r *Recorder) RunOut(cmd string) ([]byte, error) {
+	return nil, r.Record(cmd)
+}
+
+// SendFile records the file as a comment, e.g. "# send kube.tar.gz to /tmp/.kubei/kube.tar.gz".
+func (r *Recorder) SendFile(dstFile, srcFile string, p *mpb.Progress) error {
+	return r.Record(fmt.Sprintf("# send %s to %s", srcFile, dstFile))
+}
+
+func (r *Recorder) Close() error {
+	return nil
+}
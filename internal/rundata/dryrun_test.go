@@ -0,0 +1,33 @@
+package rundata
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	var out bytes.Buffer
+	r := NewRecorder("10.0.0.1", "", &out)
+	if err := r.Record("\necho 1\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Record("echo 2"); err != nil {
+		t.Fatal(err)
+	}
+	want := "# [10.0.0.1] [dry-run] 001.sh\n#!/bin/bash\nexec </dev/null\necho 1\n\n# [10.0.0.1] [dry-run] 002.sh\n#!/bin/bash\nexec </dev/null\necho 2\n\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+
+	dir := t.TempDir()
+	r = NewRecorder("10.0.0.2", dir, nil)
+	if err := r.Record("echo 1"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "10.0.0.2", "001.sh"))
+	if err != nil || string(b) != "#!/bin/bash\nexec </dev/null\necho 1\n" {
+		t.Errorf("got %q, %v", b, err)
+	}
+}
@@ -1,7 +1,9 @@
 package rundata
 
 import (
-	"github.com/yuyicai/kubei/pkg/ssh"
+	"github.com/vbauerster/mpb/v6"
+
+	"github.com/yuyicai/kubei/pkg/executor"
 )
 
 type ClusterNodes struct {
@@ -24,13 +26,16 @@ func (c *ClusterNodes) GetAllNodes() []*Node {
 // +k8s:deepcopy-gen=false
 
 type Node struct {
-	SSH                   *ssh.Client
+	// Executor runs the commands on the node, by ssh, on the local host, by docker exec, or records them with --dry-run
+	Executor              executor.Executor
 	HostInfo              HostInfo
 	CertificateTree       CertificateTree
 	Name                  string
 	PackageManagementType string
 	InstallType           string
 	IsSend                bool
+	// OS is detected from the node by the preflight
+	OS OS
 }
 
 type HostInfo struct {
@@ -39,12 +44,31 @@ type HostInfo struct {
 	Password string
 	Port     string
 	Key      string
+	// Executor is how the commands are run on the node, ssh, local or docker
+	Executor string
+	// Container is the container of the node with the docker executor
+	Container string
+	// Become is how the commands are run as root if the user isn't root, sudo, su or doas
+	Become string
+	// BecomePassword is the password asked by Become, the ssh password is used if it is empty
+	BecomePassword string
 }
 
 func (n *Node) Run(cmd string) error {
-	return n.SSH.Run(cmd)
+	return n.Executor.Run(cmd)
 }
 
+// RunOut runs the command and returns its stdout, there is no output with --dry-run.
 func (n *Node) RunOut(cmd string) ([]byte, error) {
-	return n.SSH.RunOut(cmd)
+	return n.Executor.RunOut(cmd)
+}
+
+func (n *Node) SendFile(dstFile, srcFile string, p *mpb.Progress) error {
+	return n.Executor.SendFile(dstFile, srcFile, p)
+}
+
+// DryRun returns true if the commands of the node are recorded instead of run.
+func (n *Node) DryRun() bool {
+	_, ok := n.Executor.(*Recorder)
+	return ok
 }
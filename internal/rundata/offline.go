@@ -0,0 +1,98 @@
+package rundata
+
+import (
+	"path"
+	"strings"
+)
+
+// OfflineBuild is the config of "kubei offline build".
+type OfflineBuild struct {
+	// BOMFile is the path to the bill of materials, the default one of the Kubernetes version is used if it is empty
+	BOMFile string
+	// Output is the path to the offline package
+	Output string
+	// Archs are the architectures of the files and the images in the offline package, e.g. amd64, arm64
+	Archs []string
+}
+
+// BOM is the bill of materials of the offline package.
+type BOM struct {
+	KubernetesVersion string    `json:"kubernetesVersion"`
+	ContainerEngine   []BOMFile `json:"containerEngine"`
+	Kube              []BOMFile `json:"kube"`
+	Images            BOMImages `json:"images"`
+	// Archs are the architectures of the offline package, the images are downloaded for each of them
+	Archs []string `json:"archs,omitempty"`
+}
+
+// BOMFile is a file downloaded into the offline package.
+// Packages (.deb, .rpm) are installed by the package manager of the nodes,
+// archives (.tgz, .tar.gz) are extracted to InstallTo, and other files are installed to InstallTo as executables.
+type BOMFile struct {
+	URL string `json:"url"`
+	// Name is the file name in the offline package, it is the base name of the URL by default
+	Name   string `json:"name,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	// SHA256URL is the URL of the sha256 checksum file, it is used if SHA256 is not set
+	SHA256URL       string `json:"sha256URL,omitempty"`
+	InstallTo       string `json:"installTo,omitempty"`
+	StripComponents int    `json:"stripComponents,omitempty"`
+	// Arch is the architecture of the file, it is only installed on the nodes of the architecture,
+	// the file is installed on all nodes if it is empty
+	Arch string `json:"arch,omitempty"`
+}
+
+// BOMImages are the images loaded by images/master.sh on the masters and by images/node.sh on all nodes,
+// the Registry images are only loaded on the node running the local registry.
+type BOMImages struct {
+	Master   []string `json:"master"`
+	Node     []string `json:"node"`
+	Registry []string `json:"registry,omitempty"`
+}
+
+// All returns all the images of the offline package.
+func (i BOMImages) All() []string {
+	var images []string
+	images = append(images, i.Master...)
+	images = append(images, i.Node...)
+	return append(images, i.Registry...)
+}
+
+const (
+	BOMFileKindDeb     = "deb"
+	BOMFileKindRpm     = "rpm"
+	BOMFileKindArchive = "archive"
+	BOMFileKindBinary  = "binary"
+)
+
+// GetName returns the file name in the offline package.
+func (f BOMFile) GetName() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return path.Base(f.URL)
+}
+
+// Path returns the path of the file relative to its dir in the offline package,
+// the files of an architecture are in the sub dir of the architecture.
+func (f BOMFile) Path() string {
+	if f.Arch != "" {
+		return path.Join(f.Arch, f.GetName())
+	}
+	return f.GetName()
+}
+
+// Kind returns how the file is installed on the nodes.
+func (f BOMFile) Kind() string {
+	name := f.GetName()
+	switch {
+	case strings.HasSuffix(name, ".deb"):
+		return BOMFileKindDeb
+	case strings.HasSuffix(name, ".rpm"):
+		return BOMFileKindRpm
+	case strings.HasSuffix(name, ".tgz"), strings.HasSuffix(name, ".tar.gz"):
+		return BOMFileKindArchive
+	default:
+		return BOMFileKindBinary
+	}
+}
@@ -0,0 +1,137 @@
+package rundata
+
+import (
+	"bufio"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/yuyicai/kubei/internal/constants"
+)
+
+// OS is the operating system of a node, detected from /etc/os-release and uname -m.
+type OS struct {
+	// ID is the lowercase ID of /etc/os-release, e.g. ubuntu, debian, centos, rhel, rocky, openeuler, kylin
+	ID     string
+	IDLike []string
+	// Version is the VERSION_ID of /etc/os-release, e.g. 20.04, 7, 8.5, V10
+	Version string
+	// Codename is the code name of the Debian family, e.g. focal, bullseye
+	Codename string
+	// Arch is the architecture in the Go and docker form, e.g. amd64, arm64
+	Arch string
+}
+
+// osSupport is the versions of a distro supported by kubei, and how the packages are installed on it
+type osSupport struct {
+	versions              []string
+	packageManagementType string
+}
+
+var supportedOS = map[string]osSupport{
+	constants.OSUbuntu:    {[]string{"16.04", "18.04", "20.04", "22.04"}, constants.PackageManagementTypeApt},
+	constants.OSDebian:    {[]string{"9", "10", "11"}, constants.PackageManagementTypeApt},
+	constants.OSCentOS:    {[]string{"7", "8"}, constants.PackageManagementTypeYum},
+	constants.OSRHEL:      {[]string{"7", "8"}, constants.PackageManagementTypeYum},
+	constants.OSRocky:     {[]string{"8", "9"}, constants.PackageManagementTypeYum},
+	constants.OSOpenEuler: {[]string{"20.03", "22.03"}, constants.PackageManagementTypeYum},
+	constants.OSKylin:     {[]string{"V10"}, constants.PackageManagementTypeYum},
+}
+
+var supportedArchs = []string{constants.ArchAMD64, constants.ArchARM64}
+
+// ParseOSRelease parses the content of /etc/os-release, arch is the output of uname -m.
+func ParseOSRelease(content, arch string) OS {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		kv := strings.SplitN(strings.TrimSpace(scanner.Text()), "=", 2)
+		if len(kv) != 2 || strings.HasPrefix(kv[0], "#") {
+			continue
+		}
+		values[kv[0]] = strings.Trim(kv[1], `"'`)
+	}
+
+	o := OS{
+		ID:       strings.ToLower(values["ID"]),
+		Version:  values["VERSION_ID"],
+		Codename: values["VERSION_CODENAME"],
+		Arch:     NormalizeArch(arch),
+	}
+	if o.Codename == "" {
+		o.Codename = values["UBUNTU_CODENAME"]
+	}
+	if like := values["ID_LIKE"]; like != "" {
+		o.IDLike = strings.Fields(strings.ToLower(like))
+	}
+	return o
+}
+
+// ParseOS parses the OS in the form of OS.String(), e.g. ubuntu 20.04 amd64, the missing fields are empty.
+func ParseOS(s string) OS {
+	fields := strings.Fields(s)
+	for len(fields) < 3 {
+		fields = append(fields, "")
+	}
+	return OS{ID: strings.ToLower(fields[0]), Version: fields[1], Arch: NormalizeArch(fields[2])}
+}
+
+// NormalizeArch converts the output of uname -m to the architecture in the Go and docker form.
+func NormalizeArch(arch string) string {
+	arch = strings.ToLower(strings.TrimSpace(arch))
+	switch arch {
+	case "x86_64", "x86-64", "amd64":
+		return constants.ArchAMD64
+	case "aarch64", "arm64", "armv8", "armv8l":
+		return constants.ArchARM64
+	}
+	return arch
+}
+
+// PackageManagementType returns how the packages are installed on the OS, apt or yum,
+// an unknown distro is treated as the family in its ID_LIKE.
+func (o OS) PackageManagementType() string {
+	if s, ok := supportedOS[o.ID]; ok {
+		return s.packageManagementType
+	}
+	for _, id := range o.IDLike {
+		if s, ok := supportedOS[id]; ok {
+			return s.packageManagementType
+		}
+	}
+	return ""
+}
+
+// Validate rejects the distros, versions and architectures which are not supported.
+func (o OS) Validate() error {
+	s, ok := supportedOS[o.ID]
+	if !ok {
+		return errors.Errorf("unsupported OS %q, the supported ones are %s", o.ID, strings.Join(supportedOSNames(), ", "))
+	}
+
+	supported := false
+	for _, v := range s.versions {
+		supported = supported || o.Version == v || strings.HasPrefix(o.Version, v+".")
+	}
+	if !supported {
+		return errors.Errorf("unsupported %s version %q, the supported ones are %s", o.ID, o.Version, strings.Join(s.versions, ", "))
+	}
+
+	for _, arch := range supportedArchs {
+		if o.Arch == arch {
+			return nil
+		}
+	}
+	return errors.Errorf("unsupported architecture %q, the supported ones are %s", o.Arch, strings.Join(supportedArchs, ", "))
+}
+
+// String returns the OS in the form of <id> <version> <arch>, e.g. ubuntu 20.04 amd64.
+func (o OS) String() string {
+	return strings.TrimSpace(strings.Join([]string{o.ID, o.Version, o.Arch}, " "))
+}
+
+func supportedOSNames() []string {
+	// keep the order of the docs
+	return []string{constants.OSUbuntu, constants.OSDebian, constants.OSCentOS, constants.OSRHEL,
+		constants.OSRocky, constants.OSOpenEuler, constants.OSKylin}
+}
@@ -0,0 +1,124 @@
+package rundata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOSRelease(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		arch    string
+		want    OS
+		pm      string
+		wantErr bool
+	}{
+		{
+			name: "ubuntu",
+			content: `NAME="Ubuntu"
+VERSION="20.04.3 LTS (Focal Fossa)"
+ID=ubuntu
+ID_LIKE=debian
+VERSION_ID="20.04"
+UBUNTU_CODENAME=focal
+`,
+			arch: "x86_64",
+			want: OS{ID: "ubuntu", IDLike: []string{"debian"}, Version: "20.04", Codename: "focal", Arch: "amd64"},
+			pm:   "apt",
+		},
+		{
+			name: "debian",
+			content: `PRETTY_NAME="Debian GNU/Linux 11 (bullseye)"
+VERSION_ID="11"
+VERSION_CODENAME=bullseye
+ID=debian
+`,
+			arch: "aarch64",
+			want: OS{ID: "debian", Version: "11", Codename: "bullseye", Arch: "arm64"},
+			pm:   "apt",
+		},
+		{
+			name: "rocky",
+			content: `NAME="Rocky Linux"
+ID="rocky"
+ID_LIKE="rhel centos fedora"
+VERSION_ID="8.5"
+`,
+			arch: "x86_64",
+			want: OS{ID: "rocky", IDLike: []string{"rhel", "centos", "fedora"}, Version: "8.5", Arch: "amd64"},
+			pm:   "yum",
+		},
+		{
+			name: "openEuler",
+			content: `NAME="openEuler"
+VERSION="20.03 (LTS-SP1)"
+ID="openEuler"
+VERSION_ID="20.03"
+`,
+			arch: "aarch64",
+			want: OS{ID: "openeuler", Version: "20.03", Arch: "arm64"},
+			pm:   "yum",
+		},
+		{
+			name: "unsupported version",
+			content: `ID="centos"
+ID_LIKE="rhel fedora"
+VERSION_ID="6"
+`,
+			arch:    "x86_64",
+			want:    OS{ID: "centos", IDLike: []string{"rhel", "fedora"}, Version: "6", Arch: "amd64"},
+			pm:      "yum",
+			wantErr: true,
+		},
+		{
+			name: "unsupported distro like a supported one",
+			content: `ID=linuxmint
+ID_LIKE="ubuntu debian"
+VERSION_ID="20.3"
+`,
+			arch:    "x86_64",
+			want:    OS{ID: "linuxmint", IDLike: []string{"ubuntu", "debian"}, Version: "20.3", Arch: "amd64"},
+			pm:      "apt",
+			wantErr: true,
+		},
+		{
+			name: "unsupported arch",
+			content: `ID=ubuntu
+VERSION_ID="20.04"
+`,
+			arch:    "ppc64le",
+			want:    OS{ID: "ubuntu", Version: "20.04", Arch: "ppc64le"},
+			pm:      "apt",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseOSRelease(tt.content, tt.arch)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOSRelease() = %+v, want %+v", got, tt.want)
+			}
+			if pm := got.PackageManagementType(); pm != tt.pm {
+				t.Errorf("PackageManagementType() = %q, want %q", pm, tt.pm)
+			}
+			if err := got.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseOS(t *testing.T) {
+	tests := map[string]OS{
+		"ubuntu 20.04 amd64": {ID: "ubuntu", Version: "20.04", Arch: "amd64"},
+		"CentOS 7 x86_64":    {ID: "centos", Version: "7", Arch: "amd64"},
+		"kylin V10 aarch64":  {ID: "kylin", Version: "V10", Arch: "arm64"},
+		"ubuntu":             {ID: "ubuntu"},
+	}
+	for s, want := range tests {
+		if got := ParseOS(s); !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseOS(%q) = %+v, want %+v", s, got, want)
+		}
+	}
+}
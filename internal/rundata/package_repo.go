@@ -0,0 +1,31 @@
+package rundata
+
+import "net/url"
+
+// PackageRepos are the package repositories of the online installation.
+type PackageRepos struct {
+	Docker     PackageRepo
+	Kubernetes PackageRepo
+}
+
+// PackageRepo is a package repository of the online installation, e.g. a public mirror,
+// or a proxy repository of Nexus or Artifactory.
+type PackageRepo struct {
+	// BaseURL has the same layout as the upstream repository, e.g. https://download.docker.com for docker-ce,
+	// and https://packages.cloud.google.com for kubernetes
+	BaseURL string
+	// GPGKey is the URL of the key the packages are signed with, the key under the BaseURL is used if it is empty
+	GPGKey string
+	// Proxy is the http proxy used to access the repository
+	Proxy string
+}
+
+// Hostname returns the host of the repository without the port, e.g. mirrors.aliyun.com,
+// the per-host options of apt are matched by it.
+func (r PackageRepo) Hostname() string {
+	u, err := url.Parse(r.BaseURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
@@ -0,0 +1,49 @@
+package rundata
+
+import (
+	"net"
+	"strings"
+)
+
+// Proxy is the http proxy of the nodes, it is used by apt, yum, docker, containerd and kubelet.
+type Proxy struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	// NoProxy are the extra hosts, domains and CIDRs which are accessed directly,
+	// the ones of the cluster are added by Cluster.NoProxy
+	NoProxy []string
+}
+
+// Enabled returns true if any proxy is set.
+func (p *Proxy) Enabled() bool {
+	return p.HTTPProxy != "" || p.HTTPSProxy != ""
+}
+
+// NoProxy returns NO_PROXY of the nodes, the cluster is accessed directly: the nodes, the control plane endpoint,
+// the pod and service CIDRs and the domain of the services, followed by the ones of --no-proxy.
+func (c *Cluster) NoProxy() string {
+	noProxy := []string{"localhost", "127.0.0.1"}
+	for _, node := range c.ClusterNodes.GetAllNodes() {
+		noProxy = append(noProxy, node.HostInfo.Host)
+	}
+	if host, _, err := net.SplitHostPort(c.Kubeadm.ControlPlaneEndpoint); err == nil {
+		noProxy = append(noProxy, host)
+	} else if c.Kubeadm.ControlPlaneEndpoint != "" {
+		noProxy = append(noProxy, c.Kubeadm.ControlPlaneEndpoint)
+	}
+	noProxy = append(noProxy, c.Kubeadm.Networking.PodSubnet, c.Kubeadm.Networking.ServiceSubnet, ".svc")
+	if c.Kubeadm.Networking.DNSDomain != "" {
+		noProxy = append(noProxy, "."+c.Kubeadm.Networking.DNSDomain)
+	}
+	noProxy = append(noProxy, c.Proxy.NoProxy...)
+
+	var unique []string
+	seen := map[string]bool{}
+	for _, s := range noProxy {
+		if s = strings.TrimSpace(s); s != "" && !seen[s] {
+			seen[s] = true
+			unique = append(unique, s)
+		}
+	}
+	return strings.Join(unique, ",")
+}
@@ -0,0 +1,62 @@
+package rundata
+
+import (
+	"testing"
+)
+
+func TestClusterNoProxy(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		domain   string
+		noProxy  []string
+		want     string
+	}{
+		{
+			name:     "endpoint with a port",
+			endpoint: "apiserver.k8s.local:6443",
+			domain:   "cluster.local",
+			want:     "localhost,127.0.0.1,10.0.0.1,10.0.0.2,apiserver.k8s.local,10.244.0.0/16,10.96.0.0/12,.svc,.cluster.local",
+		},
+		{
+			name:     "endpoint without a port",
+			endpoint: "apiserver.k8s.local",
+			domain:   "cluster.local",
+			want:     "localhost,127.0.0.1,10.0.0.1,10.0.0.2,apiserver.k8s.local,10.244.0.0/16,10.96.0.0/12,.svc,.cluster.local",
+		},
+		{
+			name:     "custom dns domain",
+			endpoint: "10.0.0.1:6443",
+			domain:   "k8s.example.com",
+			want:     "localhost,127.0.0.1,10.0.0.1,10.0.0.2,10.244.0.0/16,10.96.0.0/12,.svc,.k8s.example.com",
+		},
+		{
+			name:     "no dns domain",
+			endpoint: "apiserver.k8s.local:6443",
+			want:     "localhost,127.0.0.1,10.0.0.1,10.0.0.2,apiserver.k8s.local,10.244.0.0/16,10.96.0.0/12,.svc",
+		},
+		{
+			name:     "duplicated and blank no proxy",
+			endpoint: "apiserver.k8s.local:6443",
+			domain:   "cluster.local",
+			noProxy:  []string{"localhost", " 10.0.0.2", "", ".example.com", ".svc"},
+			want:     "localhost,127.0.0.1,10.0.0.1,10.0.0.2,apiserver.k8s.local,10.244.0.0/16,10.96.0.0/12,.svc,.cluster.local,.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCluster()
+			c.ClusterNodes.Masters = []*Node{{HostInfo: HostInfo{Host: "10.0.0.1"}}}
+			c.ClusterNodes.Workers = []*Node{{HostInfo: HostInfo{Host: "10.0.0.2"}}}
+			c.Kubeadm.ControlPlaneEndpoint = tt.endpoint
+			c.Kubeadm.Networking.PodSubnet = "10.244.0.0/16"
+			c.Kubeadm.Networking.ServiceSubnet = "10.96.0.0/12"
+			c.Kubeadm.Networking.DNSDomain = tt.domain
+			c.Proxy.NoProxy = tt.noProxy
+
+			if got := c.NoProxy(); got != tt.want {
+				t.Errorf("NoProxy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
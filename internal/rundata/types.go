@@ -2,10 +2,14 @@ package rundata
 
 import (
 	"fmt"
+	"net"
+	"strconv"
 	"sync"
+	"time"
 
 	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
 
+	"github.com/yuyicai/kubei/pkg/registry"
 	"github.com/yuyicai/kubei/pkg/ssh"
 )
 
@@ -32,6 +36,19 @@ type Kubei struct {
 	OfflineFile      string
 	Online           bool
 	CertNotAfterTime int
+	CertificatesDir  string
+	CertKeyAlgorithm string
+	Backup           Backup
+	Kubeconfig       Kubeconfig
+	OfflineBuild     OfflineBuild
+	Distribution     Distribution
+	Download         Download
+	LocalRegistry    LocalRegistry
+	PackageRepos     PackageRepos
+	Proxy            Proxy
+	DryRun           DryRun
+	// IgnorePreflightErrors are the names of the preflight checks whose errors are shown as warnings, "all" ignores all
+	IgnorePreflightErrors []string
 }
 
 type JumpServer struct {
@@ -42,6 +59,76 @@ type JumpServer struct {
 type Reset struct {
 	RemoveContainerEngine bool
 	RemoveKubeComponent   bool
+	RestoreHost           bool
+}
+
+type Backup struct {
+	Path           string
+	PassphraseFile string
+	Force          bool
+}
+
+// Download is the config of "kubei download".
+type Download struct {
+	// Registry and Repository are where the offline files are pulled from
+	Registry     string
+	Repository   string
+	Username     string
+	Password     string
+	DockerConfig string
+	Dest         string
+	Arch         string
+	// Platform is os/arch[/variant], it overrides Arch if it is set
+	Platform string
+	// Images downloads the images needed by the Kubernetes version instead of the offline files
+	Images bool
+}
+
+// RegistryOptions returns the options to pull from the registries.
+func (d *Download) RegistryOptions() registry.Options {
+	return registry.Options{
+		Username:     d.Username,
+		Password:     d.Password,
+		DockerConfig: d.DockerConfig,
+		Arch:         d.Arch,
+		Platform:     d.Platform,
+	}
+}
+
+// Distribution is how the offline package is sent to the nodes.
+type Distribution struct {
+	// Mode is direct or p2p, the package is uploaded to every node in direct mode,
+	// and is uploaded once to master0 and copied between the nodes in p2p mode
+	Mode string
+	// Port is the http port of the nodes serving the package in p2p mode
+	Port int
+	// Fanout is the max number of nodes a node serves the package to at a time in p2p mode
+	Fanout int
+}
+
+// LocalRegistry is the registry running as a static pod on master0, the offline images are pushed to it once,
+// and all nodes pull the images from it instead of loading all the images.
+type LocalRegistry struct {
+	Enable bool
+	// Node is the master running the registry, it becomes master0
+	Node  string
+	Port  int
+	Image string
+}
+
+// Address returns the address of the registry used in the image names, e.g. 10.0.0.1:5000.
+func (l *LocalRegistry) Address() string {
+	return net.JoinHostPort(l.Node, strconv.Itoa(l.Port))
+}
+
+type Kubeconfig struct {
+	Path          string
+	Server        string
+	Context       string
+	UseContext    bool
+	ClientName    string
+	ClientGroups  []string
+	ClientCertTTL time.Duration
 }
 
 type Install struct {
@@ -0,0 +1,228 @@
+package rundata
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/yuyicai/kubei/internal/constants"
+	pkiutil "github.com/yuyicai/kubei/pkg/pki"
+	"github.com/yuyicai/kubei/pkg/ssh"
+)
+
+// ValidateCertCfg validates the configuration of the certificates
+func ValidateCertCfg(k *Kubei, kc *Kubeadm) error {
+	if err := pkiutil.ValidateKeyAlgorithm(pkiutil.KeyAlgorithm(k.CertKeyAlgorithm)); err != nil {
+		return err
+	}
+
+	if err := ValidateCertSANs(kc.APIServer.CertSANs); err != nil {
+		return errors.Wrap(err, "invalid API server cert extra SANs")
+	}
+
+	if k.Kubeconfig.ClientName != "" && k.Kubeconfig.ClientCertTTL <= 0 {
+		return errors.Errorf("invalid kubeconfig client cert ttl %v: must be positive", k.Kubeconfig.ClientCertTTL)
+	}
+	return nil
+}
+
+// ValidateCertSANs checks that the SANs are valid IP addresses, RFC-1123 compliant DNS names or wildcard DNS names
+func ValidateCertSANs(SANs []string) error {
+	for _, altname := range SANs {
+		if net.ParseIP(altname) != nil {
+			continue
+		}
+		if len(validation.IsDNS1123Subdomain(altname)) == 0 || len(validation.IsWildcardDNS1123Subdomain(altname)) == 0 {
+			continue
+		}
+		return errors.Errorf("%q is not a valid IP address or RFC-1123 compliant DNS name", altname)
+	}
+	return nil
+}
+
+// ValidateRegistries validates the mirrors, the insecure registries, the credentials and the CAs of the registries
+func ValidateRegistries(r *Registries) error {
+	for registry, mirrors := range r.Mirrors {
+		if err := validateRegistryHost(registry); err != nil {
+			return errors.Wrap(err, "invalid registry of mirrors")
+		}
+		for _, mirror := range mirrors {
+			u, err := url.Parse(mirror)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return errors.Errorf("invalid mirror %q of registry %s: must be a http or https url, e.g. https://mirror.example.com", mirror, registry)
+			}
+		}
+	}
+	for _, registry := range r.Insecure {
+		if err := validateRegistryHost(registry); err != nil {
+			return errors.Wrap(err, "invalid insecure registry")
+		}
+	}
+	for registry, file := range r.AuthFiles {
+		if err := validateRegistryHost(registry); err != nil {
+			return errors.Wrap(err, "invalid registry of credentials")
+		}
+		if _, err := ReadRegistryAuth(file); err != nil {
+			return errors.Wrapf(err, "invalid credentials of registry %s", registry)
+		}
+	}
+	for registry, ca := range r.CAs {
+		if err := validateRegistryHost(registry); err != nil {
+			return errors.Wrap(err, "invalid registry of CA")
+		}
+		if _, err := os.Stat(ca); err != nil {
+			return errors.Wrapf(err, "invalid CA of registry %s", registry)
+		}
+	}
+	return nil
+}
+
+// ValidatePackageRepos validates the URLs of the package repositories of the online installation
+func ValidatePackageRepos(r *PackageRepos) error {
+	for name, repo := range map[string]PackageRepo{"docker": r.Docker, "kubernetes": r.Kubernetes} {
+		if !isHTTPURL(repo.BaseURL) {
+			return errors.Errorf("invalid %s repo %q: must be a http or https url, e.g. https://mirrors.example.com/%s", name, repo.BaseURL, name)
+		}
+		if repo.GPGKey != "" && !isHTTPURL(repo.GPGKey) {
+			return errors.Errorf("invalid %s repo gpg key %q: must be a http or https url", name, repo.GPGKey)
+		}
+		if repo.Proxy != "" && !isHTTPURL(repo.Proxy) {
+			return errors.Errorf("invalid %s repo proxy %q: must be a http or https url, e.g. http://proxy.example.com:3128", name, repo.Proxy)
+		}
+	}
+	return nil
+}
+
+// ValidateProxy validates the http proxy of the nodes
+func ValidateProxy(p *Proxy) error {
+	if p.HTTPProxy != "" && !isHTTPURL(p.HTTPProxy) {
+		return errors.Errorf("invalid http proxy %q: must be a http or https url, e.g. http://proxy.example.com:3128", p.HTTPProxy)
+	}
+	if p.HTTPSProxy != "" && !isHTTPURL(p.HTTPSProxy) {
+		return errors.Errorf("invalid https proxy %q: must be a http or https url, e.g. http://proxy.example.com:3128", p.HTTPSProxy)
+	}
+	for _, s := range p.NoProxy {
+		if strings.TrimSpace(s) == "" || strings.ContainsAny(s, " \t\"'") {
+			return errors.Errorf("invalid no proxy %q: must be a host, a domain or a CIDR", s)
+		}
+	}
+	return nil
+}
+
+// ValidateExecutors validates the nodes run locally or by docker exec, the cluster needs the IPs of them
+func ValidateExecutors(nodes []*Node) error {
+	for _, node := range nodes {
+		h := node.HostInfo
+		switch h.Executor {
+		case constants.ExecutorLocal:
+			if net.ParseIP(h.Host) == nil {
+				return errors.Errorf("invalid local node %q: must be local://<ip>, e.g. local://10.0.0.1", h.Host)
+			}
+		case constants.ExecutorDocker:
+			if h.Container == "" || net.ParseIP(h.Host) == nil {
+				return errors.Errorf("invalid docker node %q: must be docker://<container>@<ip>, e.g. docker://kubei-master0@172.17.0.2", h.Host)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateBecome validates how the commands are run as root by the users who aren't root
+func ValidateBecome(nodes []*Node) error {
+	for _, node := range nodes {
+		h := node.HostInfo
+		switch h.Become {
+		case ssh.BecomeSudo:
+		case ssh.BecomeSu, ssh.BecomeDoas:
+			if h.Executor == constants.ExecutorLocal {
+				return errors.Errorf("invalid become %q of the local node %s: the local node only supports sudo", h.Become, h.Host)
+			}
+		default:
+			return errors.Errorf("invalid become %q of the node %s: must be one of sudo, su or doas", h.Become, h.Host)
+		}
+	}
+	return nil
+}
+
+// ValidateDryRun validates the OS which the commands are rendered for with --dry-run
+func ValidateDryRun(d *DryRun) error {
+	if !d.Enable {
+		return nil
+	}
+	if err := d.OS.Validate(); err != nil {
+		return errors.Wrap(err, "invalid dry run OS, e.g. ubuntu 20.04 amd64")
+	}
+	return nil
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// validateRegistryHost checks that the registry is a host with an optional port, e.g. registry.example.com:5000
+func validateRegistryHost(registry string) error {
+	host := registry
+	if h, port, err := net.SplitHostPort(registry); err == nil {
+		if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+			return errors.Errorf("%q has an invalid port", registry)
+		}
+		host = h
+	}
+	if net.ParseIP(host) != nil || len(validation.IsDNS1123Subdomain(host)) == 0 {
+		return nil
+	}
+	return errors.Errorf("%q is not a registry host, e.g. registry.example.com:5000", registry)
+}
+
+// ValidateLocalRegistry validates that the local registry runs on a master with the offline package
+func ValidateLocalRegistry(k *Kubei) error {
+	l := &k.LocalRegistry
+	if !l.Enable {
+		return nil
+	}
+
+	if l.Port <= 0 || l.Port > 65535 {
+		return errors.Errorf("invalid local registry port %d", l.Port)
+	}
+	if len(k.ClusterNodes.Masters) == 0 || k.ClusterNodes.Masters[0].HostInfo.Host != l.Node {
+		return errors.Errorf("invalid local registry node %q: must be a master, the images are needed before the other nodes join", l.Node)
+	}
+	if k.ClusterNodes.Masters[0].InstallType != constants.InstallTypeOffline {
+		return errors.New("the local registry only works with the offline installation, the images are pushed from the offline package")
+	}
+	return nil
+}
+
+// ValidateIgnorePreflightErrors validates the names of the ignored preflight checks, "all" can't be used with other names
+func ValidateIgnorePreflightErrors(ignore []string) error {
+	for _, name := range ignore {
+		if strings.TrimSpace(name) == "" {
+			return errors.New("empty preflight check name in --ignore-preflight-errors")
+		}
+		if strings.ToLower(name) == "all" && len(ignore) > 1 {
+			return errors.New("don't specify individual checks if 'all' is used in --ignore-preflight-errors")
+		}
+	}
+	return nil
+}
+
+// ValidateDistribution validates the configuration of the offline package distribution
+func ValidateDistribution(d *Distribution) error {
+	if d.Mode != constants.DistributionModeDirect && d.Mode != constants.DistributionModeP2P {
+		return errors.Errorf("invalid distribution mode %q: must be %s or %s",
+			d.Mode, constants.DistributionModeDirect, constants.DistributionModeP2P)
+	}
+	if d.Port <= 0 || d.Port > 65535 {
+		return errors.Errorf("invalid distribution port %d", d.Port)
+	}
+	if d.Fanout < 1 {
+		return errors.Errorf("invalid distribution fanout %d: must be at least 1", d.Fanout)
+	}
+	return nil
+}
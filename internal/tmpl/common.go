@@ -2,7 +2,11 @@ package tmpl
 
 import (
 	"fmt"
+	"strings"
+
 	"github.com/lithammer/dedent"
+
+	"github.com/yuyicai/kubei/internal/constants"
 )
 
 func Restart(name string) string {
@@ -11,30 +15,38 @@ func Restart(name string) string {
 	return fmt.Sprintf(cmdTmpl, name, name)
 }
 
+// hostsPattern matches the lines of /etc/hosts with the domain name, but not the ones with a longer name
+// containing it, e.g. apiserver.k8s.local.example.com
+func hostsPattern(apiDomainName string) string {
+	return fmt.Sprintf(`[[:space:]]%s\([[:space:]]\|$\)`, strings.ReplaceAll(apiDomainName, ".", `\.`))
+}
+
 func SetHosts(ip, apiDomainName string) string {
 	cmdTmpl := dedent.Dedent(`
-        sed -i '/%s/d' /etc/hosts
-        cat <<EOF | tee -a /etc/hosts
+        sed -i '/%s/d' %s
+        cat <<EOF | tee -a %s
         %s %s
         EOF`)
-	return fmt.Sprintf(cmdTmpl, apiDomainName, ip, apiDomainName)
+	return fmt.Sprintf(cmdTmpl, hostsPattern(apiDomainName), constants.HostsFile, constants.HostsFile, ip, apiDomainName)
 }
 
 func ChangeHosts(ip, apiDomainName string) string {
-	cmdTmpl := "sed -i '/%s/c %s %s' /etc/hosts"
-	return fmt.Sprintf(cmdTmpl, apiDomainName, ip, apiDomainName)
+	cmdTmpl := "sed -i '/%s/c %s %s' %s"
+	return fmt.Sprintf(cmdTmpl, hostsPattern(apiDomainName), ip, apiDomainName, constants.HostsFile)
 }
 
+// SwapOff disables swap and comments out the swap entries of /etc/fstab, the commented ones are left as they are.
 func SwapOff() string {
-	return dedent.Dedent(`
+	cmdTmpl := dedent.Dedent(`
         swapoff -a && sysctl -w vm.swappiness=0
-        sed -i '/swap/ s/^#*/#/' /etc/fstab
+        sed -i '/^[[:space:]]*[^#[:space:]].*[[:space:]]swap[[:space:]]/ s/^/#/' %s
 	`)
+	return fmt.Sprintf(cmdTmpl, constants.FstabFile)
 }
 
 func Iptables() string {
 	cmd := dedent.Dedent(`
-        cat <<EOF | tee /etc/sysctl.d/99-k8s-sysctl.conf 
+        cat <<EOF | tee ` + constants.K8sSysctlConf + `
         net.ipv4.ip_forward=1
         net.bridge.bridge-nf-call-iptables=1
         net.bridge.bridge-nf-call-arptables=1
@@ -0,0 +1,72 @@
+package tmpl
+
+import (
+	"testing"
+
+	"github.com/lithammer/dedent"
+)
+
+func Test_hostsPattern(t *testing.T) {
+	tests := []struct {
+		name          string
+		apiDomainName string
+		want          string
+	}{
+		{
+			name:          "domain name",
+			apiDomainName: "apiserver.k8s.local",
+			want:          `[[:space:]]apiserver\.k8s\.local\([[:space:]]\|$\)`,
+		},
+		{
+			name:          "host name",
+			apiDomainName: "apiserver",
+			want:          `[[:space:]]apiserver\([[:space:]]\|$\)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hostsPattern(tt.apiDomainName); got != tt.want {
+				t.Errorf("hostsPattern() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetHosts(t *testing.T) {
+	want := dedent.Dedent(`
+		sed -i '/[[:space:]]apiserver\.k8s\.local\([[:space:]]\|$\)/d' /etc/hosts
+		cat <<EOF | tee -a /etc/hosts
+		10.0.0.1 apiserver.k8s.local
+		EOF`)
+
+	if got := SetHosts("10.0.0.1", "apiserver.k8s.local"); got != want {
+		t.Errorf("SetHosts() = %v, want %v", got, want)
+	}
+}
+
+func TestChangeHosts(t *testing.T) {
+	want := `sed -i '/[[:space:]]apiserver\.k8s\.local\([[:space:]]\|$\)/c 127.0.0.1 apiserver.k8s.local' /etc/hosts`
+
+	if got := ChangeHosts("127.0.0.1", "apiserver.k8s.local"); got != want {
+		t.Errorf("ChangeHosts() = %v, want %v", got, want)
+	}
+}
+
+func TestResetHosts(t *testing.T) {
+	want := `sed -i '/[[:space:]]apiserver\.k8s\.local\([[:space:]]\|$\)/d' /etc/hosts`
+
+	if got := ResetHosts("apiserver.k8s.local"); got != want {
+		t.Errorf("ResetHosts() = %v, want %v", got, want)
+	}
+}
+
+func TestSwapOff(t *testing.T) {
+	want := dedent.Dedent(`
+		swapoff -a && sysctl -w vm.swappiness=0
+		sed -i '/^[[:space:]]*[^#[:space:]].*[[:space:]]swap[[:space:]]/ s/^/#/' /etc/fstab
+	`)
+
+	if got := SwapOff(); got != want {
+		t.Errorf("SwapOff() = %v, want %v", got, want)
+	}
+}
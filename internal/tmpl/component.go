@@ -5,39 +5,127 @@ import (
 	"github.com/lithammer/dedent"
 	"github.com/yuyicai/kubei/internal/constants"
 	"github.com/yuyicai/kubei/internal/rundata"
+	"strings"
 	"text/template"
 )
 
 type DocekrText interface {
-	Docker(installTyped string, dockerData rundata.Docker) (string, error)
+	Docker(installTyped string, dockerData rundata.Docker, registries rundata.Registries, repo rundata.PackageRepo) (string, error)
 	RemoveDocker() string
 }
 
 type KubeText interface {
-	KubeComponent(version, installType string) (string, error)
+	KubeComponent(version, installType string, repo rundata.PackageRepo) (string, error)
 	RemoveKubeComponent() string
 }
 
+// gpgKey returns the GPG key of the repository, or the default one under the base URL of the repository
+func gpgKey(repo rundata.PackageRepo, defaultKeys ...string) string {
+	if repo.GPGKey != "" {
+		return repo.GPGKey
+	}
+	return strings.Join(defaultKeys, " ")
+}
+
+// aptRepoProxy returns the commands which let apt access the repository through its proxy,
+// the proxy only applies to the host of the repository, the file is removed if the repository has no proxy
+func aptRepoProxy(name string, repo rundata.PackageRepo) string {
+	file := aptRepoProxyFile(name)
+	if repo.Proxy == "" {
+		return "rm -f " + file
+	}
+	return strings.Join([]string{
+		"cat <<EOF | tee " + file,
+		"Acquire::http::Proxy::" + repo.Hostname() + ` "` + repo.Proxy + `";`,
+		"Acquire::https::Proxy::" + repo.Hostname() + ` "` + repo.Proxy + `";`,
+		"EOF",
+	}, "\n")
+}
+
+func aptRepoProxyFile(name string) string {
+	return "/etc/apt/apt.conf.d/90kubei-" + name + "-proxy"
+}
+
+// DockerRepoFiles returns the files of the docker-ce repository written by Docker of apt and yum.
+func DockerRepoFiles() []string {
+	return []string{"/etc/apt/sources.list.d/docker.list", aptRepoProxyFile("docker"), "/etc/yum.repos.d/docker-ce.repo"}
+}
+
+// KubeRepoFiles returns the files of the kubernetes repository written by KubeComponent of apt and yum.
+func KubeRepoFiles() []string {
+	return []string{"/etc/apt/sources.list.d/kubernetes.list", aptRepoProxyFile("kubernetes"), "/etc/yum.repos.d/kubernetes.repo"}
+}
+
+// curlProxy returns the option of curl to download the GPG key through the proxy of the repository
+func curlProxy(repo rundata.PackageRepo) string {
+	if repo.Proxy == "" {
+		return ""
+	}
+	return " -x " + repo.Proxy
+}
+
+// Apt installs the packages on the Debian family, Ubuntu is assumed if the OS is not set
 type Apt struct {
+	OS rundata.OS
+}
+
+// distro returns the name of the distro in the docker-ce repository
+func (a Apt) distro() string {
+	if a.OS.ID == constants.OSDebian {
+		return constants.OSDebian
+	}
+	return constants.OSUbuntu
+}
+
+// codename returns the code name of the distro, it is got by lsb_release on the node if it is not detected
+func (a Apt) codename() string {
+	if a.OS.Codename != "" {
+		return a.OS.Codename
+	}
+	return "$(lsb_release -cs)"
+}
+
+// arch returns the architecture of the apt repository, amd64 is assumed if it is not detected
+func (a Apt) arch() string {
+	if a.OS.Arch != "" {
+		return a.OS.Arch
+	}
+	return constants.ArchAMD64
 }
 
-func (Apt) Docker(installTyped string, d rundata.Docker) (string, error) {
+func (a Apt) Docker(installTyped string, d rundata.Docker, r rundata.Registries, repo rundata.PackageRepo) (string, error) {
+	registryMirrors, insecureRegistries := dockerRegistries(r)
 	m := map[string]interface{}{
-		"version":        d.Version,
-		"cgroupDriver":   d.CGroupDriver,
-		"logDriver":      d.LogDriver,
-		"logOptsMaxSize": d.LogOptsMaxSize,
-		"storageDriver":  d.StorageDriver,
+		"distro":             a.distro(),
+		"codename":           a.codename(),
+		"arch":               a.arch(),
+		"repo":               repo.BaseURL,
+		"gpgKey":             gpgKey(repo, repo.BaseURL+"/linux/"+a.distro()+"/gpg"),
+		"repoProxy":          aptRepoProxy("docker", repo),
+		"curlProxy":          curlProxy(repo),
+		"registryMirrors":    registryMirrors,
+		"insecureRegistries": insecureRegistries,
+		"version":            d.Version,
+		"cgroupDriver":       d.CGroupDriver,
+		"logDriver":          d.LogDriver,
+		"logOptsMaxSize":     d.LogOptsMaxSize,
+		"storageDriver":      d.StorageDriver,
 	}
 	t, err := template.New("text").Parse(dedent.Dedent(`
 		{{ define "config" }}
 		mkdir -p /etc/docker/ || true
 		cat <<EOF | tee /etc/docker/daemon.json
 		{
+		{{- if .registryMirrors }}
 		  "registry-mirrors": [
-		      "https://dockerhub.mirrors.nwafu.edu.cn/",
-		      "https://hub-mirror.c.163.com"
+		{{- range $i, $mirror := .registryMirrors }}{{ if $i }},{{ end }}
+		      "{{ $mirror }}"
+		{{- end }}
 		  ],
+		{{- end }}
+		{{- if .insecureRegistries }}
+		  "insecure-registries": [{{ range $i, $registry := .insecureRegistries }}{{ if $i }}, {{ end }}"{{ $registry }}"{{ end }}],
+		{{- end }}
 		{{- if eq .cgroupDriver "systemd" }}
 		  "exec-opts": ["native.cgroupdriver=systemd"],
 		{{- end }}
@@ -52,14 +140,15 @@ func (Apt) Docker(installTyped string, d rundata.Docker) (string, error) {
 		{{ end }}
 		{{ define "online" }}
 		apt-get update -qq >/dev/null && DEBIAN_FRONTEND=noninteractive apt-get -y install -qq apt-transport-https ca-certificates curl
-		curl -fsSL https://mirrors.aliyun.com/docker-ce/linux/ubuntu/gpg | apt-key add -qq - >/dev/null
+		{{ .repoProxy }}
+		curl -fsSL{{ .curlProxy }} {{ .gpgKey }} | apt-key add -qq - >/dev/null
 		cat <<EOF | tee /etc/apt/sources.list.d/docker.list
-		deb [arch=amd64] https://mirrors.aliyun.com/docker-ce/linux/ubuntu $(lsb_release -cs) stable
+		deb [arch={{ .arch }}] {{ .repo }}/linux/{{ .distro }} {{ .codename }} stable
 		EOF
 		apt-get update -qq >/dev/null
 		{{- if ne .version "" }}
 		DOCKER_VER=$(apt-cache madison docker-ce | awk '/{{ .version }}/ {print$3}' | head -1)
-		echo "kubernetes version: $DOCKER_VER"
+		echo "docker-ce version: $DOCKER_VER"
 		apt-get -y install -qq docker-ce=$DOCKER_VER docker-ce-cli=$DOCKER_VER containerd.io
 		{{- else }}
 		apt-get -y install -qq docker-ce docker-ce-cli containerd.io
@@ -83,15 +172,23 @@ func (Apt) Docker(installTyped string, d rundata.Docker) (string, error) {
 	return cmdBuff.String(), nil
 }
 
-func (Apt) Containerd(version string) (string, error) {
+func (a Apt) Containerd(version string, repo rundata.PackageRepo) (string, error) {
 	m := map[string]interface{}{
-		"version": version,
+		"distro":    a.distro(),
+		"codename":  a.codename(),
+		"arch":      a.arch(),
+		"repo":      repo.BaseURL,
+		"gpgKey":    gpgKey(repo, repo.BaseURL+"/linux/"+a.distro()+"/gpg"),
+		"repoProxy": aptRepoProxy("docker", repo),
+		"curlProxy": curlProxy(repo),
+		"version":   version,
 	}
 	t, err := template.New("ver").Parse(dedent.Dedent(`
         apt-get update -qq >/dev/null && DEBIAN_FRONTEND=noninteractive apt-get -y install apt-transport-https ca-certificates curl
-        curl -fsSL https://mirrors.aliyun.com/docker-ce/linux/ubuntu/gpg | apt-key add -qq - >/dev/null
+        {{ .repoProxy }}
+        curl -fsSL{{ .curlProxy }} {{ .gpgKey }} | apt-key add -qq - >/dev/null
         cat <<EOF | tee /etc/apt/sources.list.d/docker.list
-        deb [arch=amd64] https://mirrors.aliyun.com/docker-ce/linux/ubuntu $(lsb_release -cs) stable
+        deb [arch={{ .arch }}] {{ .repo }}/linux/{{ .distro }} {{ .codename }} stable
         EOF
         apt-get update -qq >/dev/null
         {{- if ne .version "" }}
@@ -114,16 +211,21 @@ func (Apt) Containerd(version string) (string, error) {
 	return cmd, nil
 }
 
-func (Apt) KubeComponent(version, installType string) (string, error) {
+func (Apt) KubeComponent(version, installType string, repo rundata.PackageRepo) (string, error) {
 	m := map[string]interface{}{
-		"version": version,
+		"version":   version,
+		"repo":      repo.BaseURL,
+		"gpgKey":    gpgKey(repo, repo.BaseURL+"/apt/doc/apt-key.gpg"),
+		"repoProxy": aptRepoProxy("kubernetes", repo),
+		"curlProxy": curlProxy(repo),
 	}
 	t, err := template.New("text").Parse(dedent.Dedent(`
 		{{ define "online" }}
 		apt-get update -qq && apt-get install -qq -y apt-transport-https curl
-		curl -s https://mirrors.aliyun.com/kubernetes/apt/doc/apt-key.gpg | apt-key add - >/dev/null
+		{{ .repoProxy }}
+		curl -s{{ .curlProxy }} {{ .gpgKey }} | apt-key add - >/dev/null
 		cat <<EOF | tee /etc/apt/sources.list.d/kubernetes.list
-		deb https://mirrors.aliyun.com/kubernetes/apt/ kubernetes-xenial main
+		deb {{ .repo }}/apt/ kubernetes-xenial main
 		EOF
 		apt-get update -qq
 		{{- if ne .version "" }}
@@ -160,26 +262,53 @@ func (Apt) RemoveKubeComponent() string {
 	return "apt-get remove -y --allow-change-held-packages kubelet kubeadm kubectl || true"
 }
 
+// Yum installs the packages on the Red Hat family, CentOS is assumed if the OS is not set
 type Yum struct {
+	OS rundata.OS
 }
 
-func (Yum) Docker(installType string, d rundata.Docker) (string, error) {
+// distroDocker is true if docker is installed from the repositories of the distro,
+// the docker-ce repository has no packages of openEuler and Kylin, they have their own docker-engine
+func (y Yum) distroDocker() bool {
+	return y.OS.ID == constants.OSOpenEuler || y.OS.ID == constants.OSKylin
+}
+
+// rpmArch returns the architecture in the rpm form, e.g. x86_64, aarch64, x86_64 is assumed if it is not detected
+func (y Yum) rpmArch() string {
+	if y.OS.Arch == constants.ArchARM64 {
+		return "aarch64"
+	}
+	return "x86_64"
+}
+
+func (y Yum) Docker(installType string, d rundata.Docker, r rundata.Registries, repo rundata.PackageRepo) (string, error) {
+	registryMirrors, insecureRegistries := dockerRegistries(r)
 	m := map[string]interface{}{
-		"version":        d.Version,
-		"cgroupDriver":   d.CGroupDriver,
-		"logDriver":      d.LogDriver,
-		"logOptsMaxSize": d.LogOptsMaxSize,
-		"storageDriver":  d.StorageDriver,
+		"distroDocker":       y.distroDocker(),
+		"repoFile":           yumDockerRepo(repo),
+		"registryMirrors":    registryMirrors,
+		"insecureRegistries": insecureRegistries,
+		"version":            d.Version,
+		"cgroupDriver":       d.CGroupDriver,
+		"logDriver":          d.LogDriver,
+		"logOptsMaxSize":     d.LogOptsMaxSize,
+		"storageDriver":      d.StorageDriver,
 	}
 	t, err := template.New("text").Parse(dedent.Dedent(`
 		{{ define "config" }}
 		mkdir -p /etc/docker/ || true
 		cat <<EOF | tee /etc/docker/daemon.json
 		{
+		{{- if .registryMirrors }}
 		  "registry-mirrors": [
-		      "https://dockerhub.mirrors.nwafu.edu.cn/",
-		      "https://hub-mirror.c.163.com"
+		{{- range $i, $mirror := .registryMirrors }}{{ if $i }},{{ end }}
+		      "{{ $mirror }}"
+		{{- end }}
 		  ],
+		{{- end }}
+		{{- if .insecureRegistries }}
+		  "insecure-registries": [{{ range $i, $registry := .insecureRegistries }}{{ if $i }}, {{ end }}"{{ $registry }}"{{ end }}],
+		{{- end }}
 		{{- if eq .cgroupDriver "systemd" }}
 		  "exec-opts": ["native.cgroupdriver=systemd"],
 		{{- end }}
@@ -198,9 +327,10 @@ func (Yum) Docker(installType string, d rundata.Docker) (string, error) {
 		mkdir -p /etc/systemd/system/docker.service.d
 		{{ end }}
 		{{ define "online" }}
-		yum install -y -q yum-utils
-		yum-config-manager --add-repo \
-		  https://mirrors.aliyun.com/docker-ce/linux/centos/docker-ce.repo
+		{{- if .distroDocker }}
+		yum install -y -q docker-engine
+		{{- else }}
+		{{ .repoFile }}
 		{{- if ne .version "" }}
 		DOCKER_VER=$(yum list -y docker-ce --showduplicates | awk '/{{ .version }}/ {print$2}' | tail -1 | sed 's/[[:digit:]]://')
 		echo "docker-ce version: $DOCKER_VER"
@@ -208,6 +338,7 @@ func (Yum) Docker(installType string, d rundata.Docker) (string, error) {
 		{{- else }}
 		yum install -y -q docker-ce docker-ce-cli containerd.io
 		{{- end }}
+		{{- end }}
 		{{- template "config" . -}}
 		{{ end }}
 		{{ define "offline" }}
@@ -227,14 +358,13 @@ func (Yum) Docker(installType string, d rundata.Docker) (string, error) {
 	return cmdBuff.String(), nil
 }
 
-func (Yum) Containerd(version string) (string, error) {
+func (Yum) Containerd(version string, repo rundata.PackageRepo) (string, error) {
 	m := map[string]interface{}{
-		"version": version,
+		"version":  version,
+		"repoFile": yumDockerRepo(repo),
 	}
 	t, err := template.New("ver").Parse(dedent.Dedent(`
-        yum install -y -q yum-utils
-        yum-config-manager --add-repo \
-          https://mirrors.aliyun.com/docker-ce/linux/centos/docker-ce.repo
+        {{ .repoFile }}
         {{- if ne .version "" }}
         CONTAINERD_VER=$(yum list -y docker-ce --showduplicates | awk '/{{ .version }}/ {print$2}' | tail -1 | sed 's/[[:digit:]]://')
         yum install -y -q containerd.io=CONTAINERD_VER
@@ -255,9 +385,13 @@ func (Yum) Containerd(version string) (string, error) {
 	return cmd, nil
 }
 
-func (Yum) KubeComponent(version, installType string) (string, error) {
+func (y Yum) KubeComponent(version, installType string, repo rundata.PackageRepo) (string, error) {
 	m := map[string]interface{}{
 		"version": version,
+		"rpmArch": y.rpmArch(),
+		"repo":    repo.BaseURL,
+		"gpgKey":  gpgKey(repo, repo.BaseURL+"/yum/doc/yum-key.gpg", repo.BaseURL+"/yum/doc/rpm-package-key.gpg"),
+		"proxy":   repo.Proxy,
 	}
 	t, err := template.New("ver").Parse(dedent.Dedent(`
 		{{ define "selinux" }}
@@ -268,11 +402,14 @@ func (Yum) KubeComponent(version, installType string) (string, error) {
 		cat <<EOF | tee /etc/yum.repos.d/kubernetes.repo
 		[kubernetes]
 		name=Kubernetes
-		baseurl=https://mirrors.aliyun.com/kubernetes/yum/repos/kubernetes-el7-x86_64
+		baseurl={{ .repo }}/yum/repos/kubernetes-el7-{{ .rpmArch }}
 		enabled=1
 		gpgcheck=1
 		repo_gpgcheck=1
-		gpgkey=https://mirrors.aliyun.com/kubernetes/yum/doc/yum-key.gpg https://mirrors.aliyun.com/kubernetes/yum/doc/rpm-package-key.gpg
+		gpgkey={{ .gpgKey }}
+		{{- if .proxy }}
+		proxy={{ .proxy }}
+		{{- end }}
 		EOF
 		{{- template "selinux" . -}}
 		{{- if ne .version "" }}
@@ -301,7 +438,27 @@ func (Yum) KubeComponent(version, installType string) (string, error) {
 	return cmd, nil
 }
 
-func (Yum) RemoveDocker() string {
+// yumDockerRepo returns the commands which write the docker-ce repository, the same as docker-ce.repo of the upstream
+func yumDockerRepo(repo rundata.PackageRepo) string {
+	lines := []string{
+		"cat <<'EOF' | tee /etc/yum.repos.d/docker-ce.repo",
+		"[docker-ce-stable]",
+		"name=Docker CE Stable - $basearch",
+		"baseurl=" + repo.BaseURL + "/linux/centos/$releasever/$basearch/stable",
+		"enabled=1",
+		"gpgcheck=1",
+		"gpgkey=" + gpgKey(repo, repo.BaseURL+"/linux/centos/gpg"),
+	}
+	if repo.Proxy != "" {
+		lines = append(lines, "proxy="+repo.Proxy)
+	}
+	return strings.Join(append(lines, "EOF"), "\n")
+}
+
+func (y Yum) RemoveDocker() string {
+	if y.distroDocker() {
+		return "yum remove -y docker-engine || true"
+	}
 	return "yum remove -y docker-ce docker-ce-cli containerd.io || true"
 }
 
@@ -309,22 +466,24 @@ func (Yum) RemoveKubeComponent() string {
 	return "yum remove -y kubelet kubeadm kubectl  || true"
 }
 
-func NewContainerEngineText(installationType string) DocekrText {
-	switch installationType {
+// NewContainerEngineText returns the install strategy of the container engine on the OS
+func NewContainerEngineText(os rundata.OS) DocekrText {
+	switch os.PackageManagementType() {
 	case constants.PackageManagementTypeApt:
-		return &Apt{}
+		return &Apt{OS: os}
 	case constants.PackageManagementTypeYum:
-		return &Yum{}
+		return &Yum{OS: os}
 	}
 	return nil
 }
 
-func NewKubeText(installationType string) KubeText {
-	switch installationType {
+// NewKubeText returns the install strategy of the Kubernetes components on the OS
+func NewKubeText(os rundata.OS) KubeText {
+	switch os.PackageManagementType() {
 	case constants.PackageManagementTypeApt:
-		return &Apt{}
+		return &Apt{OS: os}
 	case constants.PackageManagementTypeYum:
-		return &Yum{}
+		return &Yum{OS: os}
 	}
 	return nil
 }
@@ -4,13 +4,35 @@ import (
 	"github.com/lithammer/dedent"
 	"github.com/yuyicai/kubei/internal/constants"
 	"github.com/yuyicai/kubei/internal/rundata"
+	"strings"
 	"testing"
 )
 
+var defaultRegistries = rundata.Registries{
+	Mirrors: map[string][]string{"docker.io": strings.Split(constants.DefaultDockerHubMirrors, ",")},
+}
+
+var (
+	dockerRepo     = rundata.PackageRepo{BaseURL: constants.DefaultDockerRepo}
+	kubernetesRepo = rundata.PackageRepo{BaseURL: constants.DefaultKubernetesRepo}
+	// a proxy repository of Nexus accessed through a proxy
+	customDockerRepo = rundata.PackageRepo{
+		BaseURL: "https://nexus.example.com/repository/docker-ce",
+		GPGKey:  "https://nexus.example.com/repository/docker-ce/linux/gpg",
+		Proxy:   "http://proxy.example.com:3128",
+	}
+	customKubernetesRepo = rundata.PackageRepo{
+		BaseURL: "https://nexus.example.com/repository/kubernetes",
+		GPGKey:  "https://nexus.example.com/repository/kubernetes/key.gpg",
+		Proxy:   "http://proxy.example.com:3128",
+	}
+)
+
 func TestApt_Docker(t *testing.T) {
 	type args struct {
-		i string
-		d rundata.Docker
+		i    string
+		d    rundata.Docker
+		repo rundata.PackageRepo
 	}
 	tests := []struct {
 		name    string
@@ -29,23 +51,26 @@ func TestApt_Docker(t *testing.T) {
 					LogOptsMaxSize: constants.DefaultLogOptsMaxSize,
 					StorageDriver:  constants.DockerDefaultStorageDriver,
 				},
+				repo: dockerRepo,
 			},
 			want: dedent.Dedent(`
 				apt-get update -qq >/dev/null && DEBIAN_FRONTEND=noninteractive apt-get -y install -qq apt-transport-https ca-certificates curl
+				rm -f /etc/apt/apt.conf.d/90kubei-docker-proxy
 				curl -fsSL https://mirrors.aliyun.com/docker-ce/linux/ubuntu/gpg | apt-key add -qq - >/dev/null
 				cat <<EOF | tee /etc/apt/sources.list.d/docker.list
 				deb [arch=amd64] https://mirrors.aliyun.com/docker-ce/linux/ubuntu $(lsb_release -cs) stable
 				EOF
 				apt-get update -qq >/dev/null
 				DOCKER_VER=$(apt-cache madison docker-ce | awk '/18.09.9/ {print$3}' | head -1)
+				echo "docker-ce version: $DOCKER_VER"
 				apt-get -y install -qq docker-ce=$DOCKER_VER docker-ce-cli=$DOCKER_VER containerd.io
+				mkdir -p /etc/docker/ || true
 				cat <<EOF | tee /etc/docker/daemon.json
 				{
 				  "registry-mirrors": [
 				      "https://dockerhub.mirrors.nwafu.edu.cn/",
 				      "https://hub-mirror.c.163.com"
 				  ],
-				  "exec-opts": ["native.cgroupdriver=systemd"],
 				  "log-driver": "json-file",
 				  "log-opts": {
 				    "max-size": "500m"
@@ -53,8 +78,8 @@ func TestApt_Docker(t *testing.T) {
 				  "storage-driver": "overlay2"
 				}
 				EOF
-				mkdir -p /etc/systemd/system/docker.service.d
-				`),
+				mkdir -p /etc/systemd/system/docker.service.d || true
+			`),
 		},
 		{
 			name: "(apt_docker) online, not set version install cmd",
@@ -67,22 +92,24 @@ func TestApt_Docker(t *testing.T) {
 					LogOptsMaxSize: constants.DefaultLogOptsMaxSize,
 					StorageDriver:  constants.DockerDefaultStorageDriver,
 				},
+				repo: dockerRepo,
 			},
 			want: dedent.Dedent(`
 				apt-get update -qq >/dev/null && DEBIAN_FRONTEND=noninteractive apt-get -y install -qq apt-transport-https ca-certificates curl
+				rm -f /etc/apt/apt.conf.d/90kubei-docker-proxy
 				curl -fsSL https://mirrors.aliyun.com/docker-ce/linux/ubuntu/gpg | apt-key add -qq - >/dev/null
 				cat <<EOF | tee /etc/apt/sources.list.d/docker.list
 				deb [arch=amd64] https://mirrors.aliyun.com/docker-ce/linux/ubuntu $(lsb_release -cs) stable
 				EOF
 				apt-get update -qq >/dev/null
 				apt-get -y install -qq docker-ce docker-ce-cli containerd.io
+				mkdir -p /etc/docker/ || true
 				cat <<EOF | tee /etc/docker/daemon.json
 				{
 				  "registry-mirrors": [
 				      "https://dockerhub.mirrors.nwafu.edu.cn/",
 				      "https://hub-mirror.c.163.com"
 				  ],
-				  "exec-opts": ["native.cgroupdriver=systemd"],
 				  "log-driver": "json-file",
 				  "log-opts": {
 				    "max-size": "500m"
@@ -90,8 +117,52 @@ func TestApt_Docker(t *testing.T) {
 				  "storage-driver": "overlay2"
 				}
 				EOF
-				mkdir -p /etc/systemd/system/docker.service.d
-				`),
+				mkdir -p /etc/systemd/system/docker.service.d || true
+			`),
+		},
+		{
+			name: "(apt_docker) online, custom repo install cmd",
+			args: args{
+				i: constants.InstallTypeOnline,
+				d: rundata.Docker{
+					Version:        "18.09.9",
+					CGroupDriver:   constants.DefaultCGroupDriver,
+					LogDriver:      constants.DefaultLogDriver,
+					LogOptsMaxSize: constants.DefaultLogOptsMaxSize,
+					StorageDriver:  constants.DockerDefaultStorageDriver,
+				},
+				repo: customDockerRepo,
+			},
+			want: dedent.Dedent(`
+				apt-get update -qq >/dev/null && DEBIAN_FRONTEND=noninteractive apt-get -y install -qq apt-transport-https ca-certificates curl
+				cat <<EOF | tee /etc/apt/apt.conf.d/90kubei-docker-proxy
+				Acquire::http::Proxy::nexus.example.com "http://proxy.example.com:3128";
+				Acquire::https::Proxy::nexus.example.com "http://proxy.example.com:3128";
+				EOF
+				curl -fsSL -x http://proxy.example.com:3128 https://nexus.example.com/repository/docker-ce/linux/gpg | apt-key add -qq - >/dev/null
+				cat <<EOF | tee /etc/apt/sources.list.d/docker.list
+				deb [arch=amd64] https://nexus.example.com/repository/docker-ce/linux/ubuntu $(lsb_release -cs) stable
+				EOF
+				apt-get update -qq >/dev/null
+				DOCKER_VER=$(apt-cache madison docker-ce | awk '/18.09.9/ {print$3}' | head -1)
+				echo "docker-ce version: $DOCKER_VER"
+				apt-get -y install -qq docker-ce=$DOCKER_VER docker-ce-cli=$DOCKER_VER containerd.io
+				mkdir -p /etc/docker/ || true
+				cat <<EOF | tee /etc/docker/daemon.json
+				{
+				  "registry-mirrors": [
+				      "https://dockerhub.mirrors.nwafu.edu.cn/",
+				      "https://hub-mirror.c.163.com"
+				  ],
+				  "log-driver": "json-file",
+				  "log-opts": {
+				    "max-size": "500m"
+				  },
+				  "storage-driver": "overlay2"
+				}
+				EOF
+				mkdir -p /etc/systemd/system/docker.service.d || true
+			`),
 		},
 		{
 			name: "(apt_docker) offline install cmd",
@@ -104,15 +175,16 @@ func TestApt_Docker(t *testing.T) {
 					LogOptsMaxSize: constants.DefaultLogOptsMaxSize,
 					StorageDriver:  constants.DockerDefaultStorageDriver,
 				},
+				repo: dockerRepo,
 			},
 			want: dedent.Dedent(`
+				mkdir -p /etc/docker/ || true
 				cat <<EOF | tee /etc/docker/daemon.json
 				{
 				  "registry-mirrors": [
 				      "https://dockerhub.mirrors.nwafu.edu.cn/",
 				      "https://hub-mirror.c.163.com"
 				  ],
-				  "exec-opts": ["native.cgroupdriver=systemd"],
 				  "log-driver": "json-file",
 				  "log-opts": {
 				    "max-size": "500m"
@@ -120,15 +192,15 @@ func TestApt_Docker(t *testing.T) {
 				  "storage-driver": "overlay2"
 				}
 				EOF
-				mkdir -p /etc/systemd/system/docker.service.d
+				mkdir -p /etc/systemd/system/docker.service.d || true
 				sh /tmp/.kubei/container_engine/default.sh
-				`),
+			`),
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ap := Apt{}
-			got, err := ap.Docker(tt.args.i, tt.args.d)
+			got, err := ap.Docker(tt.args.i, tt.args.d, defaultRegistries, tt.args.repo)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Docker() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -142,8 +214,9 @@ func TestApt_Docker(t *testing.T) {
 
 func TestYum_Docker(t *testing.T) {
 	type args struct {
-		i string
-		d rundata.Docker
+		i    string
+		d    rundata.Docker
+		repo rundata.PackageRepo
 	}
 	tests := []struct {
 		name    string
@@ -154,7 +227,7 @@ func TestYum_Docker(t *testing.T) {
 		{
 			name: "(yum_docker) online install cmd",
 			args: args{
-				i: "online",
+				i: constants.InstallTypeOnline,
 				d: rundata.Docker{
 					Version:        "18.09.9",
 					CGroupDriver:   constants.DefaultCGroupDriver,
@@ -162,21 +235,27 @@ func TestYum_Docker(t *testing.T) {
 					LogOptsMaxSize: constants.DefaultLogOptsMaxSize,
 					StorageDriver:  constants.DockerDefaultStorageDriver,
 				},
+				repo: dockerRepo,
 			},
 			want: dedent.Dedent(`
-				yum install -y -q yum-utils
-				yum-config-manager --add-repo \
-				  https://mirrors.aliyun.com/docker-ce/linux/centos/docker-ce.repo
-				DOCKER_VER=$(yum list docker-ce --showduplicates | awk '/18.09.9/ {print$2}' | tail -1 | sed 's/[[:digit:]]://')
+				cat <<'EOF' | tee /etc/yum.repos.d/docker-ce.repo
+				[docker-ce-stable]
+				name=Docker CE Stable - $basearch
+				baseurl=https://mirrors.aliyun.com/docker-ce/linux/centos/$releasever/$basearch/stable
+				enabled=1
+				gpgcheck=1
+				gpgkey=https://mirrors.aliyun.com/docker-ce/linux/centos/gpg
+				EOF
+				DOCKER_VER=$(yum list -y docker-ce --showduplicates | awk '/18.09.9/ {print$2}' | tail -1 | sed 's/[[:digit:]]://')
+				echo "docker-ce version: $DOCKER_VER"
 				yum install -y -q docker-ce-$DOCKER_VER docker-ce-cli-$DOCKER_VER containerd.io
-				mkdir -p /etc/docker
+				mkdir -p /etc/docker/ || true
 				cat <<EOF | tee /etc/docker/daemon.json
 				{
 				  "registry-mirrors": [
 				      "https://dockerhub.mirrors.nwafu.edu.cn/",
 				      "https://hub-mirror.c.163.com"
 				  ],
-				  "exec-opts": ["native.cgroupdriver=systemd"],
 				  "log-driver": "json-file",
 				  "log-opts": {
 				    "max-size": "500m"
@@ -188,12 +267,12 @@ func TestYum_Docker(t *testing.T) {
 				}
 				EOF
 				mkdir -p /etc/systemd/system/docker.service.d
-				`),
+			`),
 		},
 		{
 			name: "(yum_docker) online, not set version install cmd",
 			args: args{
-				i: "online",
+				i: constants.InstallTypeOnline,
 				d: rundata.Docker{
 					Version:        "",
 					CGroupDriver:   constants.DefaultCGroupDriver,
@@ -201,20 +280,25 @@ func TestYum_Docker(t *testing.T) {
 					LogOptsMaxSize: constants.DefaultLogOptsMaxSize,
 					StorageDriver:  constants.DockerDefaultStorageDriver,
 				},
+				repo: dockerRepo,
 			},
 			want: dedent.Dedent(`
-				yum install -y -q yum-utils
-				yum-config-manager --add-repo \
-				  https://mirrors.aliyun.com/docker-ce/linux/centos/docker-ce.repo
+				cat <<'EOF' | tee /etc/yum.repos.d/docker-ce.repo
+				[docker-ce-stable]
+				name=Docker CE Stable - $basearch
+				baseurl=https://mirrors.aliyun.com/docker-ce/linux/centos/$releasever/$basearch/stable
+				enabled=1
+				gpgcheck=1
+				gpgkey=https://mirrors.aliyun.com/docker-ce/linux/centos/gpg
+				EOF
 				yum install -y -q docker-ce docker-ce-cli containerd.io
-				mkdir -p /etc/docker
+				mkdir -p /etc/docker/ || true
 				cat <<EOF | tee /etc/docker/daemon.json
 				{
 				  "registry-mirrors": [
 				      "https://dockerhub.mirrors.nwafu.edu.cn/",
 				      "https://hub-mirror.c.163.com"
 				  ],
-				  "exec-opts": ["native.cgroupdriver=systemd"],
 				  "log-driver": "json-file",
 				  "log-opts": {
 				    "max-size": "500m"
@@ -226,12 +310,58 @@ func TestYum_Docker(t *testing.T) {
 				}
 				EOF
 				mkdir -p /etc/systemd/system/docker.service.d
-				`),
+			`),
+		},
+		{
+			name: "(yum_docker) online, custom repo install cmd",
+			args: args{
+				i: constants.InstallTypeOnline,
+				d: rundata.Docker{
+					Version:        "18.09.9",
+					CGroupDriver:   constants.DefaultCGroupDriver,
+					LogDriver:      constants.DefaultLogDriver,
+					LogOptsMaxSize: constants.DefaultLogOptsMaxSize,
+					StorageDriver:  constants.DockerDefaultStorageDriver,
+				},
+				repo: customDockerRepo,
+			},
+			want: dedent.Dedent(`
+				cat <<'EOF' | tee /etc/yum.repos.d/docker-ce.repo
+				[docker-ce-stable]
+				name=Docker CE Stable - $basearch
+				baseurl=https://nexus.example.com/repository/docker-ce/linux/centos/$releasever/$basearch/stable
+				enabled=1
+				gpgcheck=1
+				gpgkey=https://nexus.example.com/repository/docker-ce/linux/gpg
+				proxy=http://proxy.example.com:3128
+				EOF
+				DOCKER_VER=$(yum list -y docker-ce --showduplicates | awk '/18.09.9/ {print$2}' | tail -1 | sed 's/[[:digit:]]://')
+				echo "docker-ce version: $DOCKER_VER"
+				yum install -y -q docker-ce-$DOCKER_VER docker-ce-cli-$DOCKER_VER containerd.io
+				mkdir -p /etc/docker/ || true
+				cat <<EOF | tee /etc/docker/daemon.json
+				{
+				  "registry-mirrors": [
+				      "https://dockerhub.mirrors.nwafu.edu.cn/",
+				      "https://hub-mirror.c.163.com"
+				  ],
+				  "log-driver": "json-file",
+				  "log-opts": {
+				    "max-size": "500m"
+				  },
+				  "storage-driver": "overlay2",
+				  "storage-opts": [
+				    "overlay2.override_kernel_check=true"
+				  ]
+				}
+				EOF
+				mkdir -p /etc/systemd/system/docker.service.d
+			`),
 		},
 		{
 			name: "(yum_docker) offline install cmd",
 			args: args{
-				i: "offline",
+				i: constants.InstallTypeOffline,
 				d: rundata.Docker{
 					Version:        "18.09.9",
 					CGroupDriver:   constants.DefaultCGroupDriver,
@@ -239,16 +369,16 @@ func TestYum_Docker(t *testing.T) {
 					LogOptsMaxSize: constants.DefaultLogOptsMaxSize,
 					StorageDriver:  constants.DockerDefaultStorageDriver,
 				},
+				repo: dockerRepo,
 			},
 			want: dedent.Dedent(`
-				mkdir -p /etc/docker
+				mkdir -p /etc/docker/ || true
 				cat <<EOF | tee /etc/docker/daemon.json
 				{
 				  "registry-mirrors": [
 				      "https://dockerhub.mirrors.nwafu.edu.cn/",
 				      "https://hub-mirror.c.163.com"
 				  ],
-				  "exec-opts": ["native.cgroupdriver=systemd"],
 				  "log-driver": "json-file",
 				  "log-opts": {
 				    "max-size": "500m"
@@ -261,13 +391,13 @@ func TestYum_Docker(t *testing.T) {
 				EOF
 				mkdir -p /etc/systemd/system/docker.service.d
 				sh /tmp/.kubei/container_engine/default.sh
-				`),
+			`),
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			yu := Yum{}
-			got, err := yu.Docker(tt.args.i, tt.args.d)
+			got, err := yu.Docker(tt.args.i, tt.args.d, defaultRegistries, tt.args.repo)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Docker() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -283,6 +413,7 @@ func TestApt_KubeComponent(t *testing.T) {
 	type args struct {
 		version     string
 		installType string
+		repo        rundata.PackageRepo
 	}
 	tests := []struct {
 		name    string
@@ -295,15 +426,18 @@ func TestApt_KubeComponent(t *testing.T) {
 			args: args{
 				version:     "1.17.4",
 				installType: constants.InstallTypeOnline,
+				repo:        kubernetesRepo,
 			},
 			want: dedent.Dedent(`
 				apt-get update -qq && apt-get install -qq -y apt-transport-https curl
+				rm -f /etc/apt/apt.conf.d/90kubei-kubernetes-proxy
 				curl -s https://mirrors.aliyun.com/kubernetes/apt/doc/apt-key.gpg | apt-key add - >/dev/null
 				cat <<EOF | tee /etc/apt/sources.list.d/kubernetes.list
 				deb https://mirrors.aliyun.com/kubernetes/apt/ kubernetes-xenial main
 				EOF
 				apt-get update -qq
 				KUBE_VER=$(apt-cache madison kubelet | awk '/1.17.4/ {print$3}' | head -1)
+				echo "kubernetes version: $KUBE_VER"
 				apt-get install -qq -y --allow-change-held-packages kubelet=$KUBE_VER kubeadm=$KUBE_VER kubectl=$KUBE_VER
 				apt-mark hold kubelet kubeadm kubectl
 			`),
@@ -313,9 +447,11 @@ func TestApt_KubeComponent(t *testing.T) {
 			args: args{
 				version:     "",
 				installType: constants.InstallTypeOnline,
+				repo:        kubernetesRepo,
 			},
 			want: dedent.Dedent(`
 				apt-get update -qq && apt-get install -qq -y apt-transport-https curl
+				rm -f /etc/apt/apt.conf.d/90kubei-kubernetes-proxy
 				curl -s https://mirrors.aliyun.com/kubernetes/apt/doc/apt-key.gpg | apt-key add - >/dev/null
 				cat <<EOF | tee /etc/apt/sources.list.d/kubernetes.list
 				deb https://mirrors.aliyun.com/kubernetes/apt/ kubernetes-xenial main
@@ -325,21 +461,71 @@ func TestApt_KubeComponent(t *testing.T) {
 				apt-mark hold kubelet kubeadm kubectl
 			`),
 		},
+		{
+			name: "(apt_kubernetes) online, custom repo install cmd",
+			args: args{
+				version:     "1.17.4",
+				installType: constants.InstallTypeOnline,
+				repo:        customKubernetesRepo,
+			},
+			want: dedent.Dedent(`
+				apt-get update -qq && apt-get install -qq -y apt-transport-https curl
+				cat <<EOF | tee /etc/apt/apt.conf.d/90kubei-kubernetes-proxy
+				Acquire::http::Proxy::nexus.example.com "http://proxy.example.com:3128";
+				Acquire::https::Proxy::nexus.example.com "http://proxy.example.com:3128";
+				EOF
+				curl -s -x http://proxy.example.com:3128 https://nexus.example.com/repository/kubernetes/key.gpg | apt-key add - >/dev/null
+				cat <<EOF | tee /etc/apt/sources.list.d/kubernetes.list
+				deb https://nexus.example.com/repository/kubernetes/apt/ kubernetes-xenial main
+				EOF
+				apt-get update -qq
+				KUBE_VER=$(apt-cache madison kubelet | awk '/1.17.4/ {print$3}' | head -1)
+				echo "kubernetes version: $KUBE_VER"
+				apt-get install -qq -y --allow-change-held-packages kubelet=$KUBE_VER kubeadm=$KUBE_VER kubectl=$KUBE_VER
+				apt-mark hold kubelet kubeadm kubectl
+			`),
+		},
+		{
+			name: "(apt_kubernetes) online, custom repo with a port install cmd",
+			args: args{
+				version:     "",
+				installType: constants.InstallTypeOnline,
+				repo: rundata.PackageRepo{
+					BaseURL: "http://nexus:8081/repository/kubernetes",
+					Proxy:   "http://proxy.example.com:3128",
+				},
+			},
+			want: dedent.Dedent(`
+				apt-get update -qq && apt-get install -qq -y apt-transport-https curl
+				cat <<EOF | tee /etc/apt/apt.conf.d/90kubei-kubernetes-proxy
+				Acquire::http::Proxy::nexus "http://proxy.example.com:3128";
+				Acquire::https::Proxy::nexus "http://proxy.example.com:3128";
+				EOF
+				curl -s -x http://proxy.example.com:3128 http://nexus:8081/repository/kubernetes/apt/doc/apt-key.gpg | apt-key add - >/dev/null
+				cat <<EOF | tee /etc/apt/sources.list.d/kubernetes.list
+				deb http://nexus:8081/repository/kubernetes/apt/ kubernetes-xenial main
+				EOF
+				apt-get update -qq
+				apt-get install -qq -y --allow-change-held-packages kubelet kubeadm kubectl
+				apt-mark hold kubelet kubeadm kubectl
+			`),
+		},
 		{
 			name: "(apt_kubernetes) offline install cmd",
 			args: args{
 				version:     "",
 				installType: constants.InstallTypeOffline,
+				repo:        kubernetesRepo,
 			},
 			want: dedent.Dedent(`
-				sh /tmp/.kubei/kubernetes/default.sh
+				sh /tmp/.kubei/kube/default.sh
 			`),
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ap := Apt{}
-			got, err := ap.KubeComponent(tt.args.version, tt.args.installType)
+			got, err := ap.KubeComponent(tt.args.version, tt.args.installType, tt.args.repo)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("KubeComponent() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -355,6 +541,7 @@ func TestYum_KubeComponent(t *testing.T) {
 	type args struct {
 		version     string
 		installType string
+		repo        rundata.PackageRepo
 	}
 	tests := []struct {
 		name    string
@@ -367,6 +554,7 @@ func TestYum_KubeComponent(t *testing.T) {
 			args: args{
 				version:     "1.17.4",
 				installType: constants.InstallTypeOnline,
+				repo:        kubernetesRepo,
 			},
 			want: dedent.Dedent(`
 				cat <<EOF | tee /etc/yum.repos.d/kubernetes.repo
@@ -380,7 +568,8 @@ func TestYum_KubeComponent(t *testing.T) {
 				EOF
 				setenforce 0 || true
 				sed -i 's/^SELINUX=enforcing$/SELINUX=permissive/' /etc/selinux/config
-				KUBE_VER=$(yum list kubelet --showduplicates | awk '/1.17.4/ {print$2}' | tail -1 | sed 's/[[:digit:]]://')
+				KUBE_VER=$(yum list -y kubelet --showduplicates | awk '/1.17.4/ {print$2}' | tail -1 | sed 's/[[:digit:]]://')
+				echo "kubernetes version: $KUBE_VER"
 				yum install -y kubelet-$KUBE_VER kubeadm-$KUBE_VER kubectl-$KUBE_VER --disableexcludes=kubernetes
 			`),
 		},
@@ -389,6 +578,7 @@ func TestYum_KubeComponent(t *testing.T) {
 			args: args{
 				version:     "",
 				installType: constants.InstallTypeOnline,
+				repo:        kubernetesRepo,
 			},
 			want: dedent.Dedent(`
 				cat <<EOF | tee /etc/yum.repos.d/kubernetes.repo
@@ -405,23 +595,49 @@ func TestYum_KubeComponent(t *testing.T) {
 				yum install -y kubelet kubeadm kubectl --disableexcludes=kubernetes
 			`),
 		},
+		{
+			name: "(yum_kubernetes) online, custom repo install cmd",
+			args: args{
+				version:     "1.17.4",
+				installType: constants.InstallTypeOnline,
+				repo:        customKubernetesRepo,
+			},
+			want: dedent.Dedent(`
+				cat <<EOF | tee /etc/yum.repos.d/kubernetes.repo
+				[kubernetes]
+				name=Kubernetes
+				baseurl=https://nexus.example.com/repository/kubernetes/yum/repos/kubernetes-el7-x86_64
+				enabled=1
+				gpgcheck=1
+				repo_gpgcheck=1
+				gpgkey=https://nexus.example.com/repository/kubernetes/key.gpg
+				proxy=http://proxy.example.com:3128
+				EOF
+				setenforce 0 || true
+				sed -i 's/^SELINUX=enforcing$/SELINUX=permissive/' /etc/selinux/config
+				KUBE_VER=$(yum list -y kubelet --showduplicates | awk '/1.17.4/ {print$2}' | tail -1 | sed 's/[[:digit:]]://')
+				echo "kubernetes version: $KUBE_VER"
+				yum install -y kubelet-$KUBE_VER kubeadm-$KUBE_VER kubectl-$KUBE_VER --disableexcludes=kubernetes
+			`),
+		},
 		{
 			name: "(yum_kubernetes) offline install cmd",
 			args: args{
 				version:     "",
 				installType: constants.InstallTypeOffline,
+				repo:        kubernetesRepo,
 			},
 			want: dedent.Dedent(`
 				setenforce 0 || true
 				sed -i 's/^SELINUX=enforcing$/SELINUX=permissive/' /etc/selinux/config
-				sh /tmp/.kubei/kubernetes/default.sh
+				sh /tmp/.kubei/kube/default.sh
 			`),
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			yu := Yum{}
-			got, err := yu.KubeComponent(tt.args.version, tt.args.installType)
+			got, err := yu.KubeComponent(tt.args.version, tt.args.installType, tt.args.repo)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("KubeComponent() error = %v, wantErr %v", err, tt.wantErr)
 				return
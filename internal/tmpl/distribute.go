@@ -0,0 +1,111 @@
+package tmpl
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/lithammer/dedent"
+)
+
+const (
+	// distDir only holds a hard link of the offline package, so that nothing else is served
+	distDir     = "/tmp/.kubei/.dist"
+	distPidFile = "/tmp/.kubei/.dist.pid"
+)
+
+// ServeFile returns the commands which serve the file by http in the background on the seed node,
+// with python3 or busybox, whichever is found first. The server only listens on the node IP host,
+// the receivers verify the checksum of the file, see FetchFile.
+func ServeFile(file, name, host string, port int) (string, error) {
+	m := map[string]interface{}{
+		"distDir": distDir,
+		"pidFile": distPidFile,
+		"file":    file,
+		"name":    name,
+		"host":    host,
+		"port":    port,
+	}
+	t, err := template.New("text").Parse(dedent.Dedent(`
+		if [ -f {{ .pidFile }} ]; then
+		  kill $(cat {{ .pidFile }}) >/dev/null 2>&1 || true
+		fi
+		mkdir -p {{ .distDir }}
+		ln -f {{ .file }} {{ .distDir }}/{{ .name }}
+		cd {{ .distDir }}
+		if command -v python3 >/dev/null 2>&1; then
+		  nohup python3 -m http.server --bind {{ .host }} {{ .port }} >/dev/null 2>&1 </dev/null &
+		elif command -v busybox >/dev/null 2>&1; then
+		  nohup busybox httpd -f -p {{ .host }}:{{ .port }} -h {{ .distDir }} >/dev/null 2>&1 </dev/null &
+		else
+		  echo "python3 or busybox is required to serve the offline package" >&2
+		  exit 1
+		fi
+		echo $! > {{ .pidFile }}
+	`))
+	if err != nil {
+		return "", err
+	}
+
+	var cmdBuff bytes.Buffer
+	if err := t.Execute(&cmdBuff, m); err != nil {
+		return "", err
+	}
+
+	return cmdBuff.String(), nil
+}
+
+// StopServeFile returns the commands which stop serving the file started by ServeFile.
+func StopServeFile() string {
+	return dedent.Dedent(`
+		if [ -f ` + distPidFile + ` ]; then
+		  kill $(cat ` + distPidFile + `) >/dev/null 2>&1 || true
+		  rm -f ` + distPidFile + `
+		fi
+		rm -rf ` + distDir + `
+	`)
+}
+
+// FetchFile returns the commands which download the file from the seed node with curl or wget,
+// the download is retried as the http server of the seed node may not be listening yet.
+// The file is only moved into place if the download succeeds and its sha256 checksum is sum.
+func FetchFile(url, file, sum string) (string, error) {
+	m := map[string]interface{}{
+		"url":  url,
+		"file": file,
+		"sum":  sum,
+	}
+	t, err := template.New("text").Parse(dedent.Dedent(`
+		mkdir -p $(dirname {{ .file }})
+		rm -f {{ .file }}.part
+		fetched=false
+		for i in 1 2 3 4 5 6 7 8 9 10; do
+		  if command -v curl >/dev/null 2>&1; then
+		    curl -fsS -o {{ .file }}.part {{ .url }} && fetched=true && break
+		  else
+		    wget -q -O {{ .file }}.part {{ .url }} && fetched=true && break
+		  fi
+		  sleep 1
+		done
+		if [ "$fetched" != true ]; then
+		  rm -f {{ .file }}.part
+		  echo "failed to download {{ .url }}" >&2
+		  exit 1
+		fi
+		if [ "$(sha256sum {{ .file }}.part | awk '{print $1}')" != "{{ .sum }}" ]; then
+		  rm -f {{ .file }}.part
+		  echo "sha256 mismatch for {{ .url }}, expected {{ .sum }}" >&2
+		  exit 1
+		fi
+		mv {{ .file }}.part {{ .file }}
+	`))
+	if err != nil {
+		return "", err
+	}
+
+	var cmdBuff bytes.Buffer
+	if err := t.Execute(&cmdBuff, m); err != nil {
+		return "", err
+	}
+
+	return cmdBuff.String(), nil
+}
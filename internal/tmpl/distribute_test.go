@@ -0,0 +1,71 @@
+package tmpl
+
+import (
+	"testing"
+
+	"github.com/lithammer/dedent"
+)
+
+func TestServeFile(t *testing.T) {
+	want := dedent.Dedent(`
+		if [ -f /tmp/.kubei/.dist.pid ]; then
+		  kill $(cat /tmp/.kubei/.dist.pid) >/dev/null 2>&1 || true
+		fi
+		mkdir -p /tmp/.kubei/.dist
+		ln -f /tmp/.kubei/kube.tar.gz /tmp/.kubei/.dist/kube.tar.gz
+		cd /tmp/.kubei/.dist
+		if command -v python3 >/dev/null 2>&1; then
+		  nohup python3 -m http.server --bind 10.0.0.1 18088 >/dev/null 2>&1 </dev/null &
+		elif command -v busybox >/dev/null 2>&1; then
+		  nohup busybox httpd -f -p 10.0.0.1:18088 -h /tmp/.kubei/.dist >/dev/null 2>&1 </dev/null &
+		else
+		  echo "python3 or busybox is required to serve the offline package" >&2
+		  exit 1
+		fi
+		echo $! > /tmp/.kubei/.dist.pid
+	`)
+
+	got, err := ServeFile("/tmp/.kubei/kube.tar.gz", "kube.tar.gz", "10.0.0.1", 18088)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("ServeFile() got = %v, want %v", got, want)
+	}
+}
+
+func TestFetchFile(t *testing.T) {
+	want := dedent.Dedent(`
+		mkdir -p $(dirname /tmp/.kubei/kube.tar.gz)
+		rm -f /tmp/.kubei/kube.tar.gz.part
+		fetched=false
+		for i in 1 2 3 4 5 6 7 8 9 10; do
+		  if command -v curl >/dev/null 2>&1; then
+		    curl -fsS -o /tmp/.kubei/kube.tar.gz.part http://10.0.0.1:18088/kube.tar.gz && fetched=true && break
+		  else
+		    wget -q -O /tmp/.kubei/kube.tar.gz.part http://10.0.0.1:18088/kube.tar.gz && fetched=true && break
+		  fi
+		  sleep 1
+		done
+		if [ "$fetched" != true ]; then
+		  rm -f /tmp/.kubei/kube.tar.gz.part
+		  echo "failed to download http://10.0.0.1:18088/kube.tar.gz" >&2
+		  exit 1
+		fi
+		if [ "$(sha256sum /tmp/.kubei/kube.tar.gz.part | awk '{print $1}')" != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" ]; then
+		  rm -f /tmp/.kubei/kube.tar.gz.part
+		  echo "sha256 mismatch for http://10.0.0.1:18088/kube.tar.gz, expected e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" >&2
+		  exit 1
+		fi
+		mv /tmp/.kubei/kube.tar.gz.part /tmp/.kubei/kube.tar.gz
+	`)
+
+	got, err := FetchFile("http://10.0.0.1:18088/kube.tar.gz", "/tmp/.kubei/kube.tar.gz",
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("FetchFile() got = %v, want %v", got, want)
+	}
+}
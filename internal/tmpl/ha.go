@@ -3,15 +3,18 @@ package tmpl
 import (
 	"bytes"
 	"fmt"
-	"github.com/lithammer/dedent"
 	"text/template"
+
+	"github.com/lithammer/dedent"
+
+	"github.com/yuyicai/kubei/internal/constants"
 )
 
 func KubeletUnitFile(image string) string {
 	cmdTmpl := dedent.Dedent(`
         cgroupDriver=$(docker info --format '{{json .CgroupDriver}}' | sed 's/"//g')
         mkdir -p /etc/systemd/system/kubelet.service.d
-        cat << EOF | tee /etc/systemd/system/kubelet.service.d/20-ha-service-manager.conf
+        cat << EOF | tee %s
         [Service]
         ExecStart=
         ExecStart=/usr/bin/kubelet --address=127.0.0.1 --pod-manifest-path=/etc/kubernetes/manifests --pod-infra-container-image=%s --cgroup-driver=${cgroupDriver}
@@ -19,12 +22,11 @@ func KubeletUnitFile(image string) string {
         EOF
 	`)
 
-	return fmt.Sprintf(cmdTmpl, image)
+	return fmt.Sprintf(cmdTmpl, constants.KubeletHADropIn, image)
 }
 
 func RemoveKubeletUnitFile() string {
-	cmdTmpl := "rm -f /etc/systemd/system/kubelet.service.d/20-ha-service-manager.conf"
-	return cmdTmpl
+	return "rm -f " + constants.KubeletHADropIn
 }
 
 func NginxConf(masters []string, nginxPort, masterPort string) (string, error) {
@@ -0,0 +1,94 @@
+package tmpl
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/lithammer/dedent"
+
+	"github.com/yuyicai/kubei/internal/constants"
+)
+
+// Journal returns the commands which record the files of a node before kubei changes them.
+// Each file is recorded once, the first time it is changed: "backup <file>" with a copy of it in the backup dir,
+// or "absent <file>" if it doesn't exist yet, so RestoreHost rolls the node back to the state before kubei,
+// however many times kubei runs. The symlinks are resolved, e.g. /etc/yum.conf -> /etc/dnf/dnf.conf.
+func Journal(files ...string) (string, error) {
+	m := map[string]interface{}{
+		"files":     files,
+		"journal":   constants.JournalFile,
+		"backupDir": constants.JournalBackupDir,
+	}
+	t, err := template.New("text").Parse(dedent.Dedent(`
+		set -e
+		mkdir -p {{ .backupDir }}
+		touch {{ .journal }}
+		for f in{{ range .files }} {{ . }}{{ end }}; do
+		  f=$(readlink -m "$f")
+		  if grep -qxF "backup $f" {{ .journal }} || grep -qxF "absent $f" {{ .journal }}; then
+		    continue
+		  fi
+		  if [ -e "$f" ]; then
+		    mkdir -p "{{ .backupDir }}$(dirname "$f")"
+		    cp -a "$f" "{{ .backupDir }}$f"
+		    echo "backup $f" >> {{ .journal }}
+		  else
+		    echo "absent $f" >> {{ .journal }}
+		  fi
+		done
+	`))
+	if err != nil {
+		return "", err
+	}
+
+	var cmdBuff bytes.Buffer
+	if err := t.Execute(&cmdBuff, m); err != nil {
+		return "", err
+	}
+
+	return cmdBuff.String(), nil
+}
+
+// RestoreHost returns the commands which roll back the files recorded by Journal in the reverse order,
+// and reload the sysctl, systemd, docker and swap settings from the restored files.
+func RestoreHost() (string, error) {
+	m := map[string]interface{}{
+		"journal":   constants.JournalFile,
+		"backupDir": constants.JournalBackupDir,
+	}
+	t, err := template.New("text").Parse(dedent.Dedent(`
+		set -e
+		if [ ! -f {{ .journal }} ]; then
+		  echo "no changes recorded in {{ .journal }}"
+		  exit 0
+		fi
+		tac {{ .journal }} | while read -r state f; do
+		  case "$state" in
+		  backup)
+		    mkdir -p "$(dirname "$f")"
+		    cp -a --remove-destination "{{ .backupDir }}$f" "$f"
+		    echo "restored $f"
+		    ;;
+		  absent)
+		    rm -f "$f"
+		    echo "removed $f"
+		    ;;
+		  esac
+		done
+		rm -rf {{ .journal }} {{ .backupDir }}
+		sysctl --system >/dev/null 2>&1 || true
+		systemctl daemon-reload
+		systemctl try-restart docker containerd || true
+		swapon -a || true
+	`))
+	if err != nil {
+		return "", err
+	}
+
+	var cmdBuff bytes.Buffer
+	if err := t.Execute(&cmdBuff, m); err != nil {
+		return "", err
+	}
+
+	return cmdBuff.String(), nil
+}
@@ -0,0 +1,72 @@
+package tmpl
+
+import (
+	"testing"
+
+	"github.com/lithammer/dedent"
+)
+
+func TestJournal(t *testing.T) {
+	want := dedent.Dedent(`
+		set -e
+		mkdir -p /var/lib/kubei/backup
+		touch /var/lib/kubei/journal
+		for f in /etc/hosts /etc/fstab; do
+		  f=$(readlink -m "$f")
+		  if grep -qxF "backup $f" /var/lib/kubei/journal || grep -qxF "absent $f" /var/lib/kubei/journal; then
+		    continue
+		  fi
+		  if [ -e "$f" ]; then
+		    mkdir -p "/var/lib/kubei/backup$(dirname "$f")"
+		    cp -a "$f" "/var/lib/kubei/backup$f"
+		    echo "backup $f" >> /var/lib/kubei/journal
+		  else
+		    echo "absent $f" >> /var/lib/kubei/journal
+		  fi
+		done
+	`)
+
+	got, err := Journal("/etc/hosts", "/etc/fstab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("Journal() got = %v, want %v", got, want)
+	}
+}
+
+func TestRestoreHost(t *testing.T) {
+	want := dedent.Dedent(`
+		set -e
+		if [ ! -f /var/lib/kubei/journal ]; then
+		  echo "no changes recorded in /var/lib/kubei/journal"
+		  exit 0
+		fi
+		tac /var/lib/kubei/journal | while read -r state f; do
+		  case "$state" in
+		  backup)
+		    mkdir -p "$(dirname "$f")"
+		    cp -a --remove-destination "/var/lib/kubei/backup$f" "$f"
+		    echo "restored $f"
+		    ;;
+		  absent)
+		    rm -f "$f"
+		    echo "removed $f"
+		    ;;
+		  esac
+		done
+		rm -rf /var/lib/kubei/journal /var/lib/kubei/backup
+		sysctl --system >/dev/null 2>&1 || true
+		systemctl daemon-reload
+		systemctl try-restart docker containerd || true
+		swapon -a || true
+	`)
+
+	got, err := RestoreHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("RestoreHost() got = %v, want %v", got, want)
+	}
+}
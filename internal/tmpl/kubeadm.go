@@ -84,3 +84,22 @@ func CopyAdminConfig() string {
 func ChownKubectlConfig() string {
 	return "chown $SUDO_USER:$SUDO_UID $HOME/.kube/config"
 }
+
+// RestartControlPlane restarts the containers of the control plane static pods, by docker if kubelet runs them
+// by docker, or else by stopping them with crictl, and kubelet starts them again.
+func RestartControlPlane() string {
+	return dedent.Dedent(`
+        set -e
+        for name in kube-apiserver kube-controller-manager kube-scheduler etcd; do
+          ids=$(docker ps -q --filter "name=k8s_${name}_" 2>/dev/null || true)
+          if [ -n "$ids" ]; then
+            docker restart $ids >/dev/null
+          elif command -v crictl >/dev/null 2>&1; then
+            crictl ps -q --name "^${name}$" | xargs -r crictl stop >/dev/null
+          else
+            echo "neither docker nor crictl can restart ${name}" >&2
+            exit 1
+          fi
+        done
+	`)
+}
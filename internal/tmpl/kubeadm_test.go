@@ -0,0 +1,28 @@
+package tmpl
+
+import (
+	"testing"
+
+	"github.com/lithammer/dedent"
+)
+
+func TestRestartControlPlane(t *testing.T) {
+	want := dedent.Dedent(`
+		set -e
+		for name in kube-apiserver kube-controller-manager kube-scheduler etcd; do
+		  ids=$(docker ps -q --filter "name=k8s_${name}_" 2>/dev/null || true)
+		  if [ -n "$ids" ]; then
+		    docker restart $ids >/dev/null
+		  elif command -v crictl >/dev/null 2>&1; then
+		    crictl ps -q --name "^${name}$" | xargs -r crictl stop >/dev/null
+		  else
+		    echo "neither docker nor crictl can restart ${name}" >&2
+		    exit 1
+		  fi
+		done
+	`)
+
+	if got := RestartControlPlane(); got != want {
+		t.Errorf("RestartControlPlane() = %v, want %v", got, want)
+	}
+}
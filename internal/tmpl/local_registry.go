@@ -0,0 +1,153 @@
+package tmpl
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/lithammer/dedent"
+
+	"github.com/yuyicai/kubei/internal/constants"
+)
+
+// localRegistryImages records the images tagged by LoadLocalRegistryImages, they are pushed by PushLocalRegistryImages
+const localRegistryImages = "/tmp/.kubei/images/.local-registry-images"
+
+// LoadLocalRegistryImages returns the commands which load all the images of the offline package on master0,
+// and tag them with the local registry, e.g. k8s.gcr.io/coredns/coredns:v1.8.4 is tagged as <address>/coredns:v1.8.4,
+// the same as the images used by kubeadm with the local registry as the image repository.
+// Only the images of the architecture of master0 are loaded, the local registry can't serve a mixed-architecture cluster.
+func LoadLocalRegistryImages(address string) (string, error) {
+	m := map[string]interface{}{
+		"address": address,
+		"list":    localRegistryImages,
+		"arch":    offlineArch,
+	}
+	t, err := template.New("text").Parse(dedent.Dedent(`
+		set -e
+		cd /tmp/.kubei/images
+		{{ .arch }}
+		: > {{ .list }}
+		for image in master/*.tar node/*.tar registry/*.tar master/$ARCH/*.tar node/$ARCH/*.tar registry/$ARCH/*.tar; do
+		  [ -f "$image" ] || continue
+		  loaded=$(docker load -i "$image")
+		  for name in $(echo "$loaded" | sed -n 's/^Loaded image: //p'); do
+		    docker tag "$name" "{{ .address }}/${name##*/}"
+		    echo "{{ .address }}/${name##*/}" >> {{ .list }}
+		  done
+		done
+	`))
+	if err != nil {
+		return "", err
+	}
+
+	var cmdBuff bytes.Buffer
+	if err := t.Execute(&cmdBuff, m); err != nil {
+		return "", err
+	}
+
+	return cmdBuff.String(), nil
+}
+
+// LocalRegistry returns the commands which write the static pod of the local registry listening on address,
+// the node IP of master0, the images are stored in the host path so that they survive the restarts of the pod.
+// The registry is read-only after it is seeded, so that the images pulled by the other nodes can't be overwritten,
+// and the images can't be deleted at all.
+func LocalRegistry(image, address string, readOnly bool) (string, error) {
+	m := map[string]interface{}{
+		"image":    image,
+		"address":  address,
+		"readOnly": readOnly,
+		"dataDir":  constants.LocalRegistryDataDir,
+		"manifest": constants.LocalRegistryManifest,
+	}
+	t, err := template.New("text").Parse(dedent.Dedent(`
+		mkdir -p {{ .dataDir }}
+		cat <<EOF | tee {{ .manifest }}
+		apiVersion: v1
+		kind: Pod
+		metadata:
+		  name: kubei-registry
+		  namespace: kube-system
+		  labels:
+		    component: kubei-registry
+		    tier: node
+		spec:
+		  hostNetwork: true
+		  priorityClassName: system-node-critical
+		  containers:
+		  - name: registry
+		    image: {{ .image }}
+		    imagePullPolicy: IfNotPresent
+		    env:
+		    - name: REGISTRY_HTTP_ADDR
+		      value: {{ .address }}
+		    {{- if .readOnly }}
+		    - name: REGISTRY_STORAGE_MAINTENANCE_READONLY
+		      value: '{"enabled": true}'
+		    {{- end }}
+		    volumeMounts:
+		    - name: data
+		      mountPath: /var/lib/registry
+		  volumes:
+		  - name: data
+		    hostPath:
+		      path: {{ .dataDir }}
+		      type: DirectoryOrCreate
+		EOF
+	`))
+	if err != nil {
+		return "", err
+	}
+
+	var cmdBuff bytes.Buffer
+	if err := t.Execute(&cmdBuff, m); err != nil {
+		return "", err
+	}
+
+	return cmdBuff.String(), nil
+}
+
+// PushLocalRegistryImages returns the commands which wait for the local registry, and push the images tagged by
+// LoadLocalRegistryImages to it. The pushes are retried until the wait timeout, the read-only registry of
+// a previous run may still be running until the kubelet restarts it with the writable manifest.
+func PushLocalRegistryImages(address string, waitSeconds int) (string, error) {
+	m := map[string]interface{}{
+		"address": address,
+		"wait":    waitSeconds,
+		"list":    localRegistryImages,
+		"sleep":   2,
+	}
+	t, err := template.New("text").Parse(dedent.Dedent(`
+		set -e
+		i=0
+		until curl -sf http://{{ .address }}/v2/ >/dev/null; do
+		  i=$((i + {{ .sleep }}))
+		  if [ $i -ge {{ .wait }} ]; then
+		    echo "the local registry is not ready in {{ .wait }}s" >&2
+		    exit 1
+		  fi
+		  sleep {{ .sleep }}
+		done
+		for image in $(sort -u {{ .list }}); do
+		  until docker push "$image" >/dev/null; do
+		    i=$((i + {{ .sleep }}))
+		    if [ $i -ge {{ .wait }} ]; then
+		      echo "failed to push $image to the local registry in {{ .wait }}s" >&2
+		      exit 1
+		    fi
+		    sleep {{ .sleep }}
+		  done
+		  echo "pushed $image"
+		done
+	`))
+	if err != nil {
+		return "", err
+	}
+
+	var cmdBuff bytes.Buffer
+	if err := t.Execute(&cmdBuff, m); err != nil {
+		return "", err
+	}
+
+	return cmdBuff.String(), nil
+}
@@ -0,0 +1,158 @@
+package tmpl
+
+import (
+	"testing"
+
+	"github.com/lithammer/dedent"
+)
+
+func TestLocalRegistry(t *testing.T) {
+	tests := []struct {
+		name     string
+		readOnly bool
+		want     string
+	}{
+		{
+			name:     "writable for the seeding",
+			readOnly: false,
+			want: dedent.Dedent(`
+				mkdir -p /var/lib/kubei/registry
+				cat <<EOF | tee /etc/kubernetes/manifests/kubei-registry.yaml
+				apiVersion: v1
+				kind: Pod
+				metadata:
+				  name: kubei-registry
+				  namespace: kube-system
+				  labels:
+				    component: kubei-registry
+				    tier: node
+				spec:
+				  hostNetwork: true
+				  priorityClassName: system-node-critical
+				  containers:
+				  - name: registry
+				    image: registry:2.7.1
+				    imagePullPolicy: IfNotPresent
+				    env:
+				    - name: REGISTRY_HTTP_ADDR
+				      value: 10.0.0.1:5000
+				    volumeMounts:
+				    - name: data
+				      mountPath: /var/lib/registry
+				  volumes:
+				  - name: data
+				    hostPath:
+				      path: /var/lib/kubei/registry
+				      type: DirectoryOrCreate
+				EOF
+			`),
+		},
+		{
+			name:     "read-only after the seeding",
+			readOnly: true,
+			want: dedent.Dedent(`
+				mkdir -p /var/lib/kubei/registry
+				cat <<EOF | tee /etc/kubernetes/manifests/kubei-registry.yaml
+				apiVersion: v1
+				kind: Pod
+				metadata:
+				  name: kubei-registry
+				  namespace: kube-system
+				  labels:
+				    component: kubei-registry
+				    tier: node
+				spec:
+				  hostNetwork: true
+				  priorityClassName: system-node-critical
+				  containers:
+				  - name: registry
+				    image: registry:2.7.1
+				    imagePullPolicy: IfNotPresent
+				    env:
+				    - name: REGISTRY_HTTP_ADDR
+				      value: 10.0.0.1:5000
+				    - name: REGISTRY_STORAGE_MAINTENANCE_READONLY
+				      value: '{"enabled": true}'
+				    volumeMounts:
+				    - name: data
+				      mountPath: /var/lib/registry
+				  volumes:
+				  - name: data
+				    hostPath:
+				      path: /var/lib/kubei/registry
+				      type: DirectoryOrCreate
+				EOF
+			`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LocalRegistry("registry:2.7.1", "10.0.0.1:5000", tt.readOnly)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("LocalRegistry() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadLocalRegistryImages(t *testing.T) {
+	want := dedent.Dedent(`
+		set -e
+		cd /tmp/.kubei/images
+		ARCH=$(uname -m | sed -e 's/^x86_64$/amd64/' -e 's/^aarch64$/arm64/')
+		: > /tmp/.kubei/images/.local-registry-images
+		for image in master/*.tar node/*.tar registry/*.tar master/$ARCH/*.tar node/$ARCH/*.tar registry/$ARCH/*.tar; do
+		  [ -f "$image" ] || continue
+		  loaded=$(docker load -i "$image")
+		  for name in $(echo "$loaded" | sed -n 's/^Loaded image: //p'); do
+		    docker tag "$name" "10.0.0.1:5000/${name##*/}"
+		    echo "10.0.0.1:5000/${name##*/}" >> /tmp/.kubei/images/.local-registry-images
+		  done
+		done
+	`)
+
+	got, err := LoadLocalRegistryImages("10.0.0.1:5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("LoadLocalRegistryImages() got = %v, want %v", got, want)
+	}
+}
+
+func TestPushLocalRegistryImages(t *testing.T) {
+	want := dedent.Dedent(`
+		set -e
+		i=0
+		until curl -sf http://10.0.0.1:5000/v2/ >/dev/null; do
+		  i=$((i + 2))
+		  if [ $i -ge 120 ]; then
+		    echo "the local registry is not ready in 120s" >&2
+		    exit 1
+		  fi
+		  sleep 2
+		done
+		for image in $(sort -u /tmp/.kubei/images/.local-registry-images); do
+		  until docker push "$image" >/dev/null; do
+		    i=$((i + 2))
+		    if [ $i -ge 120 ]; then
+		      echo "failed to push $image to the local registry in 120s" >&2
+		      exit 1
+		    fi
+		    sleep 2
+		  done
+		  echo "pushed $image"
+		done
+	`)
+
+	got, err := PushLocalRegistryImages("10.0.0.1:5000", 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("PushLocalRegistryImages() got = %v, want %v", got, want)
+	}
+}
@@ -13,7 +13,10 @@ func Flannel(network, image, backendType string) (string, error) {
 		"backendType": backendType,
 	}
 
+	// the daemonset of flannel was kube-flannel-ds-amd64 before the other architectures were supported,
+	// it is replaced by kube-flannel-ds which runs on all of them
 	cmdTmpl := dedent.Dedent(`
+        kubectl -n kube-system delete daemonset kube-flannel-ds-amd64 --ignore-not-found
         cat <<EOF | kubectl apply -f -
         ---
         apiVersion: policy/v1beta1
@@ -151,7 +154,7 @@ func Flannel(network, image, backendType string) (string, error) {
         apiVersion: apps/v1
         kind: DaemonSet
         metadata:
-          name: kube-flannel-ds-amd64
+          name: kube-flannel-ds
           namespace: kube-system
           labels:
             tier: node
@@ -171,14 +174,10 @@ func Flannel(network, image, backendType string) (string, error) {
                   requiredDuringSchedulingIgnoredDuringExecution:
                     nodeSelectorTerms:
                       - matchExpressions:
-                          - key: beta.kubernetes.io/os
+                          - key: kubernetes.io/os
                             operator: In
                             values:
                               - linux
-                          - key: beta.kubernetes.io/arch
-                            operator: In
-                            values:
-                              - amd64
               hostNetwork: true
               tolerations:
               - operator: Exists
@@ -0,0 +1,208 @@
+package tmpl
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/lithammer/dedent"
+
+	"github.com/yuyicai/kubei/internal/rundata"
+)
+
+// offlineArch sets ARCH to the architecture of the node in the Go and docker form
+const offlineArch = `ARCH=$(uname -m | sed -e 's/^x86_64$/amd64/' -e 's/^aarch64$/arm64/')`
+
+// offlineInstallTmpl installs the files for all architectures, and then the files for the architecture of the node,
+// it fails if the package has architecture specific files but none of them is for the node.
+const offlineInstallTmpl = `
+	{{- define "install" -}}
+	#!/bin/sh
+	set -e
+	cd "$(dirname "$0")"
+	{{- template "files" .common }}
+	{{- if .archs }}
+	` + offlineArch + `
+	case "$ARCH" in
+	{{- range $arch, $files := .archs }}
+	  {{ $arch }})
+	{{- template "files" $files }}
+	    ;;
+	{{- end }}
+	  *)
+	    echo "the offline package has no files for the architecture $ARCH" >&2
+	    exit 1
+	    ;;
+	esac
+	{{- end }}
+	{{- end }}
+	{{- define "files" }}
+	{{- if .debs }}
+	if command -v apt-get >/dev/null 2>&1; then
+	  dpkg -i --force-confold{{ range .debs }} {{ .Path }}{{ end }}
+	fi
+	{{- end }}
+	{{- if .rpms }}
+	if command -v yum >/dev/null 2>&1; then
+	  rpm -Uvh --replacepkgs{{ range .rpms }} {{ .Path }}{{ end }}
+	fi
+	{{- end }}
+	{{- range .archives }}
+	mkdir -p {{ .InstallTo }}
+	tar xzf {{ .Path }} -C {{ .InstallTo }} --strip-components={{ .StripComponents }}
+	{{- end }}
+	{{- range .binaries }}
+	mkdir -p {{ .InstallTo }}
+	install -m 0755 {{ .Path }} {{ .InstallTo }}/{{ .GetName }}
+	{{- end }}
+	{{- end }}
+`
+
+// OfflineContainerEngine returns container_engine/default.sh of the offline package,
+// the systemd units of docker are written if docker is not installed by the package manager.
+func OfflineContainerEngine(files []rundata.BOMFile) (string, error) {
+	t, err := template.New("text").Parse(dedent.Dedent(offlineInstallTmpl) + dedent.Dedent(`
+		{{- define "container_engine" -}}
+		{{- template "install" . }}
+		if [ ! -f /lib/systemd/system/docker.service ] && [ ! -f /usr/lib/systemd/system/docker.service ]; then
+		cat <<'EOF' > /etc/systemd/system/docker.service
+		[Unit]
+		Description=Docker Application Container Engine
+		Documentation=https://docs.docker.com
+		After=network-online.target firewalld.service
+		Wants=network-online.target
+
+		[Service]
+		Type=notify
+		ExecStart=/usr/bin/dockerd
+		ExecReload=/bin/kill -s HUP $MAINPID
+		LimitNOFILE=infinity
+		LimitNPROC=infinity
+		LimitCORE=infinity
+		TasksMax=infinity
+		TimeoutStartSec=0
+		Delegate=yes
+		KillMode=process
+		Restart=on-failure
+		StartLimitBurst=3
+		StartLimitInterval=60s
+
+		[Install]
+		WantedBy=multi-user.target
+		EOF
+		fi
+		{{ end }}
+	`))
+	if err != nil {
+		return "", err
+	}
+
+	var cmdBuff bytes.Buffer
+	if err := t.ExecuteTemplate(&cmdBuff, "container_engine", offlineFiles(files)); err != nil {
+		return "", err
+	}
+
+	return cmdBuff.String(), nil
+}
+
+// OfflineKubeComponent returns kube/default.sh of the offline package,
+// the systemd units of kubelet are written if kubelet is not installed by the package manager.
+func OfflineKubeComponent(files []rundata.BOMFile) (string, error) {
+	t, err := template.New("text").Parse(dedent.Dedent(offlineInstallTmpl) + dedent.Dedent(`
+		{{- define "kube" -}}
+		{{- template "install" . }}
+		if [ ! -f /lib/systemd/system/kubelet.service ] && [ ! -f /usr/lib/systemd/system/kubelet.service ]; then
+		cat <<'EOF' > /etc/systemd/system/kubelet.service
+		[Unit]
+		Description=kubelet: The Kubernetes Node Agent
+		Documentation=https://kubernetes.io/docs/home/
+		Wants=network-online.target
+		After=network-online.target
+
+		[Service]
+		ExecStart=/usr/bin/kubelet
+		Restart=always
+		StartLimitInterval=0
+		RestartSec=10
+
+		[Install]
+		WantedBy=multi-user.target
+		EOF
+		mkdir -p /etc/systemd/system/kubelet.service.d
+		cat <<'EOF' > /etc/systemd/system/kubelet.service.d/10-kubeadm.conf
+		[Service]
+		Environment="KUBELET_KUBECONFIG_ARGS=--bootstrap-kubeconfig=/etc/kubernetes/bootstrap-kubelet.conf --kubeconfig=/etc/kubernetes/kubelet.conf"
+		Environment="KUBELET_CONFIG_ARGS=--config=/var/lib/kubelet/config.yaml"
+		EnvironmentFile=-/var/lib/kubelet/kubeadm-flags.env
+		EnvironmentFile=-/etc/default/kubelet
+		ExecStart=
+		ExecStart=/usr/bin/kubelet $KUBELET_KUBECONFIG_ARGS $KUBELET_CONFIG_ARGS $KUBELET_KUBEADM_ARGS $KUBELET_EXTRA_ARGS
+		EOF
+		fi
+		{{ end }}
+	`))
+	if err != nil {
+		return "", err
+	}
+
+	var cmdBuff bytes.Buffer
+	if err := t.ExecuteTemplate(&cmdBuff, "kube", offlineFiles(files)); err != nil {
+		return "", err
+	}
+
+	return cmdBuff.String(), nil
+}
+
+// OfflineImages returns images/master.sh or images/node.sh of the offline package,
+// which loads the image tarballs in images/master or images/node, and the ones in the sub dir of
+// the architecture of the node, e.g. images/node/arm64.
+func OfflineImages(nodeType string) string {
+	return dedent.Dedent(`
+		#!/bin/sh
+		set -e
+		cd "$(dirname "$0")"
+		` + offlineArch + `
+		for image in ` + nodeType + `/*.tar ` + nodeType + `/$ARCH/*.tar; do
+		  [ -f "$image" ] || continue
+		  docker load -i "$image"
+		done
+	`)
+}
+
+// offlineFiles groups the files by the architecture, and then by the kind.
+func offlineFiles(files []rundata.BOMFile) map[string]interface{} {
+	var common []rundata.BOMFile
+	archs := map[string][]rundata.BOMFile{}
+	for _, f := range files {
+		if f.Arch == "" {
+			common = append(common, f)
+		} else {
+			archs[f.Arch] = append(archs[f.Arch], f)
+		}
+	}
+
+	grouped := map[string]map[string][]rundata.BOMFile{}
+	for arch, files := range archs {
+		grouped[arch] = groupBOMFiles(files)
+	}
+	return map[string]interface{}{
+		"common": groupBOMFiles(common),
+		"archs":  grouped,
+	}
+}
+
+func groupBOMFiles(files []rundata.BOMFile) map[string][]rundata.BOMFile {
+	m := map[string][]rundata.BOMFile{}
+	for _, f := range files {
+		switch f.Kind() {
+		case rundata.BOMFileKindDeb:
+			m["debs"] = append(m["debs"], f)
+		case rundata.BOMFileKindRpm:
+			m["rpms"] = append(m["rpms"], f)
+		case rundata.BOMFileKindArchive:
+			m["archives"] = append(m["archives"], f)
+		default:
+			m["binaries"] = append(m["binaries"], f)
+		}
+	}
+	return m
+}
@@ -0,0 +1,129 @@
+package tmpl
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/lithammer/dedent"
+
+	"github.com/yuyicai/kubei/internal/constants"
+)
+
+// yumProxyMarker is the comment line before the proxy added to yum.conf, the proxy is only added if there is no one,
+// and the marker lets RemoveProxy remove the proxy added by kubei only
+const yumProxyMarker = "# added by kubei"
+
+// Proxy returns the commands which let apt, yum, docker, containerd and kubelet use the http proxy,
+// the services pick up the systemd drop-ins when they are restarted.
+func Proxy(httpProxy, httpsProxy, noProxy string) (string, error) {
+	// yum has only one proxy for both http and https
+	yumProxy := httpsProxy
+	if yumProxy == "" {
+		yumProxy = httpProxy
+	}
+	// % is the specifier of systemd, e.g. in the percent-encoded password of the proxy
+	systemd := strings.NewReplacer("%", "%%")
+	m := map[string]interface{}{
+		"httpProxy":         httpProxy,
+		"httpsProxy":        httpsProxy,
+		"systemdHTTPProxy":  systemd.Replace(httpProxy),
+		"systemdHTTPSProxy": systemd.Replace(httpsProxy),
+		"systemdNoProxy":    systemd.Replace(noProxy),
+		"yumProxy":          yumProxy,
+		"marker":            yumProxyMarker,
+		"aptConf":           constants.ProxyAptConf,
+		"dropIn":            constants.ProxyDropIn,
+		"services":          proxyServices,
+	}
+	t, err := template.New("text").Parse(dedent.Dedent(`
+		if [ -d /etc/apt/apt.conf.d ]; then
+		cat <<'EOF' | tee {{ .aptConf }}
+		{{- if .httpProxy }}
+		Acquire::http::Proxy "{{ .httpProxy }}";
+		{{- end }}
+		{{- if .httpsProxy }}
+		Acquire::https::Proxy "{{ .httpsProxy }}";
+		{{- end }}
+		EOF
+		fi
+		if [ -f /etc/yum.conf ]; then
+		sed -i --follow-symlinks '/^{{ .marker }}$/{N;d}' /etc/yum.conf
+		grep -q '^proxy=' /etc/yum.conf || sed -i --follow-symlinks '/^\[main\]/a {{ .marker }}\nproxy={{ .yumProxy }}' /etc/yum.conf
+		fi
+		{{- range .services }}
+		mkdir -p /etc/systemd/system/{{ . }}.service.d
+		cat <<'EOF' | tee /etc/systemd/system/{{ . }}.service.d/{{ $.dropIn }}
+		[Service]
+		{{- if $.httpProxy }}
+		Environment="HTTP_PROXY={{ $.systemdHTTPProxy }}"
+		{{- end }}
+		{{- if $.httpsProxy }}
+		Environment="HTTPS_PROXY={{ $.systemdHTTPSProxy }}"
+		{{- end }}
+		Environment="NO_PROXY={{ $.systemdNoProxy }}"
+		EOF
+		{{- end }}
+		systemctl daemon-reload
+	`))
+	if err != nil {
+		return "", err
+	}
+
+	var cmdBuff bytes.Buffer
+	if err := t.Execute(&cmdBuff, m); err != nil {
+		return "", err
+	}
+
+	return cmdBuff.String(), nil
+}
+
+// RemoveProxy returns the commands which remove the proxy written by Proxy, docker and containerd are restarted
+// without the proxy if they are running.
+func RemoveProxy() (string, error) {
+	m := map[string]interface{}{
+		"marker":   yumProxyMarker,
+		"aptConf":  constants.ProxyAptConf,
+		"dropIn":   constants.ProxyDropIn,
+		"services": proxyServices,
+	}
+	t, err := template.New("text").Parse(dedent.Dedent(`
+		rm -f {{ .aptConf }}
+		if [ -f /etc/yum.conf ]; then
+		sed -i --follow-symlinks '/^{{ .marker }}$/{N;d}' /etc/yum.conf
+		fi
+		restart=""
+		{{- range .services }}
+		if [ -f /etc/systemd/system/{{ . }}.service.d/{{ $.dropIn }} ]; then
+		  rm -f /etc/systemd/system/{{ . }}.service.d/{{ $.dropIn }}
+		  restart="$restart {{ . }}"
+		fi
+		{{- end }}
+		systemctl daemon-reload
+		for s in $restart; do
+		  [ "$s" = kubelet ] || systemctl try-restart $s
+		done
+	`))
+	if err != nil {
+		return "", err
+	}
+
+	var cmdBuff bytes.Buffer
+	if err := t.Execute(&cmdBuff, m); err != nil {
+		return "", err
+	}
+
+	return cmdBuff.String(), nil
+}
+
+// proxyServices are the services which get the proxy by systemd drop-ins
+var proxyServices = []string{"docker", "containerd", "kubelet"}
+
+// ProxyFiles returns the files changed by Proxy.
+func ProxyFiles() []string {
+	files := []string{constants.ProxyAptConf, "/etc/yum.conf"}
+	for _, s := range proxyServices {
+		files = append(files, "/etc/systemd/system/"+s+".service.d/"+constants.ProxyDropIn)
+	}
+	return files
+}
@@ -0,0 +1,255 @@
+package tmpl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/lithammer/dedent"
+
+	"github.com/yuyicai/kubei/internal/constants"
+	"github.com/yuyicai/kubei/internal/rundata"
+)
+
+// dockerHubAuthKey is the key of Docker Hub in the docker config.json
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
+// registryHosts is the hosts.toml of a registry for containerd,
+// see https://github.com/containerd/containerd/blob/main/docs/hosts.md
+type registryHosts struct {
+	Registry string
+	Server   string
+	Hosts    []registryHost
+}
+
+type registryHost struct {
+	URL          string
+	Capabilities string
+	CA           string
+	SkipVerify   bool
+}
+
+type registryCA struct {
+	Registry string
+	Data     string
+}
+
+// dockerRegistries returns the registry-mirrors and the insecure-registries of the docker daemon.json,
+// docker only uses the mirrors of Docker Hub, and the http mirrors must be insecure registries.
+func dockerRegistries(r rundata.Registries) ([]string, []string) {
+	mirrors := r.Mirrors["docker.io"]
+	insecure := append([]string{}, r.Insecure...)
+	for _, mirror := range mirrors {
+		if u, err := url.Parse(mirror); err == nil && u.Scheme == "http" && !r.IsInsecure(u.Host) {
+			insecure = append(insecure, u.Host)
+		}
+	}
+	return mirrors, insecure
+}
+
+// Registries returns the commands which write the CAs of the registries for docker and containerd,
+// the hosts.toml of the registries for containerd, and the credentials of the registries for kubelet.
+// The config_path of the cri plugin in the containerd config.toml is set to the dir of the hosts.toml,
+// and the credentials are copied to the docker config.json of root for kubeadm if it doesn't exist.
+// The cas are the contents of the CAs of the registries.
+func Registries(r rundata.Registries, cas map[string]string) (string, error) {
+	var caList []registryCA
+	for registry, data := range cas {
+		caList = append(caList, registryCA{Registry: registry, Data: strings.TrimSpace(data)})
+	}
+	sort.Slice(caList, func(i, j int) bool { return caList[i].Registry < caList[j].Registry })
+
+	auths, err := dockerConfigAuths(r.Auths)
+	if err != nil {
+		return "", err
+	}
+
+	m := map[string]interface{}{
+		"dockerCertsDir":     constants.DockerCertsDir,
+		"containerdCertsDir": constants.ContainerdCertsDir,
+		"containerdConfig":   constants.ContainerdConfigFile,
+		"kubeletConfig":      constants.KubeletDockerConfigFile,
+		"kubeletConfigDir":   path.Dir(constants.KubeletDockerConfigFile),
+		"rootConfig":         constants.RootDockerConfigFile,
+		"rootConfigDir":      path.Dir(constants.RootDockerConfigFile),
+		"cas":                caList,
+		"hosts":              containerdHosts(r, cas),
+		"auths":              auths,
+	}
+	t, err := template.New("text").Parse(dedent.Dedent(`
+		{{- range .cas }}
+		mkdir -p {{ $.dockerCertsDir }}/{{ .Registry }} {{ $.containerdCertsDir }}/{{ .Registry }}
+		cat <<EOF | tee {{ $.dockerCertsDir }}/{{ .Registry }}/ca.crt {{ $.containerdCertsDir }}/{{ .Registry }}/ca.crt >/dev/null
+		{{ .Data }}
+		EOF
+		{{- end }}
+		{{- range .hosts }}
+		mkdir -p {{ $.containerdCertsDir }}/{{ .Registry }}
+		cat <<EOF | tee {{ $.containerdCertsDir }}/{{ .Registry }}/hosts.toml >/dev/null
+		server = "{{ .Server }}"
+		{{- range .Hosts }}
+
+		[host."{{ .URL }}"]
+		  capabilities = [{{ .Capabilities }}]
+		{{- if .CA }}
+		  ca = "{{ .CA }}"
+		{{- end }}
+		{{- if .SkipVerify }}
+		  skip_verify = true
+		{{- end }}
+		{{- end }}
+		EOF
+		{{- end }}
+		{{- if .hosts }}
+		mkdir -p $(dirname {{ .containerdConfig }})
+		touch {{ .containerdConfig }}
+		if grep -q '^\s*config_path\s*=' {{ .containerdConfig }}; then
+		  sed -i 's#^\(\s*\)config_path\s*=.*#\1config_path = "{{ .containerdCertsDir }}"#' {{ .containerdConfig }}
+		elif grep -qF '[plugins."io.containerd.grpc.v1.cri".registry]' {{ .containerdConfig }}; then
+		  sed -i '/^\s*\[plugins\."io\.containerd\.grpc\.v1\.cri"\.registry\]/a\  config_path = "{{ .containerdCertsDir }}"' {{ .containerdConfig }}
+		else
+		  cat <<EOF | tee -a {{ .containerdConfig }} >/dev/null
+
+		[plugins."io.containerd.grpc.v1.cri".registry]
+		  config_path = "{{ .containerdCertsDir }}"
+		EOF
+		fi
+		systemctl try-restart containerd || true
+		{{- end }}
+		{{- if .auths }}
+		mkdir -p {{ .kubeletConfigDir }}
+		cat <<EOF | tee {{ .kubeletConfig }} >/dev/null
+		{{ .auths }}
+		EOF
+		chmod 600 {{ .kubeletConfig }}
+		if [ ! -f {{ .rootConfig }} ]; then
+		  mkdir -p {{ .rootConfigDir }}
+		  cp {{ .kubeletConfig }} {{ .rootConfig }}
+		fi
+		{{- end }}
+	`))
+	if err != nil {
+		return "", err
+	}
+
+	var cmdBuff bytes.Buffer
+	if err := t.Execute(&cmdBuff, m); err != nil {
+		return "", err
+	}
+
+	return cmdBuff.String(), nil
+}
+
+// RegistryFiles returns the CAs and the hosts.toml written by Registries.
+func RegistryFiles(r rundata.Registries, cas map[string]string) []string {
+	var files []string
+	for registry := range cas {
+		files = append(files, path.Join(constants.DockerCertsDir, registry, "ca.crt"), path.Join(constants.ContainerdCertsDir, registry, "ca.crt"))
+	}
+	for _, h := range containerdHosts(r, cas) {
+		files = append(files, path.Join(constants.ContainerdCertsDir, h.Registry, "hosts.toml"))
+	}
+	sort.Strings(files)
+	return files
+}
+
+// containerdHosts returns the hosts.toml of the registries which have mirrors, are insecure or have CAs.
+func containerdHosts(r rundata.Registries, cas map[string]string) []registryHosts {
+	registries := map[string]bool{}
+	for registry, mirrors := range r.Mirrors {
+		if len(mirrors) > 0 {
+			registries[registry] = true
+		}
+	}
+	for _, registry := range r.Insecure {
+		registries[registry] = true
+	}
+	for registry := range cas {
+		registries[registry] = true
+	}
+
+	ca := func(registry string) string {
+		if _, ok := cas[registry]; ok {
+			return path.Join(constants.ContainerdCertsDir, registry, "ca.crt")
+		}
+		return ""
+	}
+
+	var hosts []registryHosts
+	for registry := range registries {
+		h := registryHosts{
+			Registry: registry,
+			Server:   "https://" + registry,
+		}
+		if registry == "docker.io" {
+			h.Server = "https://registry-1.docker.io"
+		}
+
+		for _, mirror := range r.Mirrors[registry] {
+			u, err := url.Parse(mirror)
+			if err != nil {
+				continue
+			}
+			h.Hosts = append(h.Hosts, registryHost{
+				URL:          strings.TrimSuffix(mirror, "/"),
+				Capabilities: `"pull", "resolve"`,
+				CA:           ca(u.Host),
+				SkipVerify:   u.Scheme == "https" && r.IsInsecure(u.Host),
+			})
+		}
+
+		// the upstream registry itself is configured only if it is insecure or has a CA,
+		// an insecure registry is tried by https without verifying the certificate first, and then by http
+		if r.IsInsecure(registry) || ca(registry) != "" {
+			h.Hosts = append(h.Hosts, registryHost{
+				URL:          h.Server,
+				Capabilities: `"pull", "resolve", "push"`,
+				CA:           ca(registry),
+				SkipVerify:   r.IsInsecure(registry),
+			})
+		}
+		if r.IsInsecure(registry) {
+			h.Hosts = append(h.Hosts, registryHost{
+				URL:          "http://" + registry,
+				Capabilities: `"pull", "resolve", "push"`,
+			})
+		}
+		hosts = append(hosts, h)
+	}
+
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Registry < hosts[j].Registry })
+	return hosts
+}
+
+// dockerConfigAuths returns the docker config.json with the credentials of the registries,
+// it is the same as the one written by docker login, and is used by kubelet to pull the images.
+func dockerConfigAuths(auths map[string]rundata.RegistryAuth) (string, error) {
+	if len(auths) == 0 {
+		return "", nil
+	}
+
+	type auth struct {
+		Auth string `json:"auth"`
+	}
+	config := struct {
+		Auths map[string]auth `json:"auths"`
+	}{Auths: map[string]auth{}}
+
+	for registry, a := range auths {
+		if registry == "docker.io" {
+			registry = dockerHubAuthKey
+		}
+		config.Auths[registry] = auth{Auth: base64.StdEncoding.EncodeToString([]byte(a.Username + ":" + a.Password))}
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
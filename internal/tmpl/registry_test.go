@@ -0,0 +1,115 @@
+package tmpl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lithammer/dedent"
+
+	"github.com/yuyicai/kubei/internal/rundata"
+)
+
+func TestRegistries(t *testing.T) {
+	r := rundata.Registries{
+		Mirrors: map[string][]string{
+			"docker.io": {"https://mirror.example.com/", "http://10.0.0.1:5000"},
+			"quay.io":   {},
+		},
+		Insecure: []string{"registry.local:5000"},
+		Auths: map[string]rundata.RegistryAuth{
+			"registry.local:5000": {Username: "user", Password: "pass"},
+		},
+	}
+	cas := map[string]string{"mirror.example.com": "-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n"}
+
+	want := dedent.Dedent(`
+		mkdir -p /etc/docker/certs.d/mirror.example.com /etc/containerd/certs.d/mirror.example.com
+		cat <<EOF | tee /etc/docker/certs.d/mirror.example.com/ca.crt /etc/containerd/certs.d/mirror.example.com/ca.crt >/dev/null
+		-----BEGIN CERTIFICATE-----
+		-----END CERTIFICATE-----
+		EOF
+		mkdir -p /etc/containerd/certs.d/docker.io
+		cat <<EOF | tee /etc/containerd/certs.d/docker.io/hosts.toml >/dev/null
+		server = "https://registry-1.docker.io"
+
+		[host."https://mirror.example.com"]
+		  capabilities = ["pull", "resolve"]
+		  ca = "/etc/containerd/certs.d/mirror.example.com/ca.crt"
+
+		[host."http://10.0.0.1:5000"]
+		  capabilities = ["pull", "resolve"]
+		EOF
+		mkdir -p /etc/containerd/certs.d/mirror.example.com
+		cat <<EOF | tee /etc/containerd/certs.d/mirror.example.com/hosts.toml >/dev/null
+		server = "https://mirror.example.com"
+
+		[host."https://mirror.example.com"]
+		  capabilities = ["pull", "resolve", "push"]
+		  ca = "/etc/containerd/certs.d/mirror.example.com/ca.crt"
+		EOF
+		mkdir -p /etc/containerd/certs.d/registry.local:5000
+		cat <<EOF | tee /etc/containerd/certs.d/registry.local:5000/hosts.toml >/dev/null
+		server = "https://registry.local:5000"
+
+		[host."https://registry.local:5000"]
+		  capabilities = ["pull", "resolve", "push"]
+		  skip_verify = true
+
+		[host."http://registry.local:5000"]
+		  capabilities = ["pull", "resolve", "push"]
+		EOF
+		mkdir -p $(dirname /etc/containerd/config.toml)
+		touch /etc/containerd/config.toml
+		if grep -q '^\s*config_path\s*=' /etc/containerd/config.toml; then
+		  sed -i 's#^\(\s*\)config_path\s*=.*#\1config_path = "/etc/containerd/certs.d"#' /etc/containerd/config.toml
+		elif grep -qF '[plugins."io.containerd.grpc.v1.cri".registry]' /etc/containerd/config.toml; then
+		  sed -i '/^\s*\[plugins\."io\.containerd\.grpc\.v1\.cri"\.registry\]/a\  config_path = "/etc/containerd/certs.d"' /etc/containerd/config.toml
+		else
+		  cat <<EOF | tee -a /etc/containerd/config.toml >/dev/null
+
+		[plugins."io.containerd.grpc.v1.cri".registry]
+		  config_path = "/etc/containerd/certs.d"
+		EOF
+		fi
+		systemctl try-restart containerd || true
+		mkdir -p /var/lib/kubelet
+		cat <<EOF | tee /var/lib/kubelet/config.json >/dev/null
+		{
+		  "auths": {
+		    "registry.local:5000": {
+		      "auth": "dXNlcjpwYXNz"
+		    }
+		  }
+		}
+		EOF
+		chmod 600 /var/lib/kubelet/config.json
+		if [ ! -f /root/.docker/config.json ]; then
+		  mkdir -p /root/.docker
+		  cp /var/lib/kubelet/config.json /root/.docker/config.json
+		fi
+	`)
+
+	got, err := Registries(r, cas)
+	if err != nil {
+		t.Fatalf("Registries() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("Registries() got = %v, want %v", got, want)
+	}
+
+	files := []string{
+		"/etc/containerd/certs.d/docker.io/hosts.toml",
+		"/etc/containerd/certs.d/mirror.example.com/ca.crt",
+		"/etc/containerd/certs.d/mirror.example.com/hosts.toml",
+		"/etc/containerd/certs.d/registry.local:5000/hosts.toml",
+		"/etc/docker/certs.d/mirror.example.com/ca.crt",
+	}
+	if got := RegistryFiles(r, cas); !reflect.DeepEqual(got, files) {
+		t.Errorf("RegistryFiles() = %v, want %v", got, files)
+	}
+
+	mirrors, insecure := dockerRegistries(r)
+	if len(mirrors) != 2 || len(insecure) != 2 || insecure[1] != "10.0.0.1:5000" {
+		t.Errorf("dockerRegistries() = %v, %v", mirrors, insecure)
+	}
+}
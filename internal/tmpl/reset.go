@@ -1,8 +1,25 @@
 package tmpl
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/yuyicai/kubei/internal/constants"
+)
 
 func ResetHosts(apiDomainName string) string {
-	cmdTmpl := "sed -i '/%s/d' /etc/hosts"
-	return fmt.Sprintf(cmdTmpl, apiDomainName)
+	cmdTmpl := "sed -i '/%s/d' %s"
+	return fmt.Sprintf(cmdTmpl, hostsPattern(apiDomainName), constants.HostsFile)
+}
+
+// ResetRegistryAuth removes the docker config.json of root copied from the credentials of kubelet by Registries,
+// it is only removed if it didn't exist before kubei, see Journal.
+func ResetRegistryAuth() string {
+	return fmt.Sprintf("if grep -qxF 'absent %s' %s 2>/dev/null; then rm -f %s; fi",
+		constants.RootDockerConfigFile, constants.JournalFile, constants.RootDockerConfigFile)
+}
+
+// ResetLocalRegistry removes the static pod and the images of the local registry, nothing is removed on the nodes
+// which don't run it.
+func ResetLocalRegistry() string {
+	return fmt.Sprintf("rm -rf %s %s", constants.LocalRegistryManifest, constants.LocalRegistryDataDir)
 }
@@ -0,0 +1,59 @@
+package archive
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptedTarGz(t *testing.T) {
+	files := map[string][]byte{
+		"ca.crt":      []byte("ca cert"),
+		"ca.key":      []byte("ca key"),
+		"etcd/ca.crt": []byte("etcd ca cert"),
+		"admin.conf":  []byte("admin kubeconfig"),
+	}
+
+	data, err := TarGz(files)
+	if err != nil {
+		t.Fatalf("TarGz() error = %v", err)
+	}
+
+	encrypted, err := Encrypt(data, "passphrase")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if !IsEncrypted(encrypted) {
+		t.Fatal("IsEncrypted() = false, want true")
+	}
+	if bytes.Contains(encrypted, []byte("ca key")) {
+		t.Fatal("encrypted archive contains the plaintext")
+	}
+
+	if _, err := Decrypt(encrypted, "wrong passphrase"); err != ErrDecrypt {
+		t.Errorf("Decrypt() with a wrong passphrase error = %v, want %v", err, ErrDecrypt)
+	}
+
+	tampered := append([]byte{}, encrypted...)
+	tampered[len(tampered)-1] ^= 0xff
+	if _, err := Decrypt(tampered, "passphrase"); err != ErrDecrypt {
+		t.Errorf("Decrypt() of a tampered archive error = %v, want %v", err, ErrDecrypt)
+	}
+
+	decrypted, err := Decrypt(encrypted, "passphrase")
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+
+	got, err := UnTarGz(decrypted)
+	if err != nil {
+		t.Fatalf("UnTarGz() error = %v", err)
+	}
+	if len(got) != len(files) {
+		t.Fatalf("UnTarGz() got %d files, want %d", len(got), len(files))
+	}
+	for name, content := range files {
+		if !bytes.Equal(got[name], content) {
+			t.Errorf("UnTarGz() %s = %q, want %q", name, got[name], content)
+		}
+	}
+}
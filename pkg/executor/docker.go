@@ -0,0 +1,42 @@
+package executor
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+
+	"github.com/vbauerster/mpb/v6"
+)
+
+// Docker runs the commands in a container by "docker exec", the container is a node of the cluster,
+// e.g. a systemd container used by the integration tests instead of a VM.
+type Docker struct {
+	host      string
+	container string
+}
+
+func NewDocker(host, container string) *Docker {
+	return &Docker{host: host, container: container}
+}
+
+func (d *Docker) Run(cmd string) error {
+	_, err := d.RunOut(cmd)
+	return err
+}
+
+func (d *Docker) RunOut(cmd string) ([]byte, error) {
+	return run(d.host, exec.Command("docker", "exec", d.container, "bash", "-c", script(cmd)))
+}
+
+// SendFile copies the file to the container by "docker cp".
+func (d *Docker) SendFile(dstFile, srcFile string, p *mpb.Progress) error {
+	if err := d.Run(fmt.Sprintf("mkdir -p %s", path.Dir(dstFile))); err != nil {
+		return err
+	}
+	_, err := run(d.host, exec.Command("docker", "cp", srcFile, d.container+":"+dstFile))
+	return err
+}
+
+func (d *Docker) Close() error {
+	return nil
+}
@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testImage is the image of the container, it has bash
+const testImage = "debian:bullseye-slim"
+
+func TestDocker(t *testing.T) {
+	if exec.Command("docker", "info").Run() != nil {
+		t.Skip("the docker executor needs docker")
+	}
+	id, err := exec.Command("docker", "run", "-d", "--rm", testImage, "sleep", "300").Output()
+	if err != nil {
+		t.Skipf("failed to run a container of %s: %v", testImage, err)
+	}
+	container := strings.TrimSpace(string(id))
+	t.Cleanup(func() { exec.Command("docker", "rm", "-f", container).Run() })
+
+	d := NewDocker("10.0.0.1", container)
+
+	out, err := d.RunOut("a=kubei\necho \"$a\"")
+	if err != nil || string(out) != "kubei\n" {
+		t.Errorf("RunOut() = %q, %v, want %q", out, err, "kubei\n")
+	}
+	if err := d.Run("echo kubei\nfalse"); err == nil {
+		t.Error("Run() with a failed command, want error")
+	}
+
+	src := filepath.Join(t.TempDir(), "src.sh")
+	if err := ioutil.WriteFile(src, []byte("echo kubei"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SendFile("/tmp/.kubei/sub/dst.sh", src, nil); err != nil {
+		t.Fatal(err)
+	}
+	out, err = d.RunOut("bash /tmp/.kubei/sub/dst.sh")
+	if err != nil || string(out) != "kubei\n" {
+		t.Errorf("the sent script = %q, %v, want %q", out, err, "kubei\n")
+	}
+}
@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vbauerster/mpb/v6"
+	"k8s.io/klog"
+
+	"github.com/yuyicai/kubei/pkg/ssh"
+)
+
+var _ Executor = (*ssh.Client)(nil)
+
+// Executor runs the commands and sends the files to a node, e.g. by ssh, on the local host or by docker exec.
+// The commands are run by bash as root, by sudo, su or doas if the user isn't root.
+type Executor interface {
+	Run(cmd string) error
+	// RunOut runs the commands and returns the stdout
+	RunOut(cmd string) ([]byte, error)
+	// SendFile sends the local file to the node, the progress is shown as a bar of p if p is not nil
+	SendFile(dstFile, srcFile string, p *mpb.Progress) error
+	Close() error
+}
+
+// sudoPrompt is the password prompt of "sudo -p" of Local, it is removed from the stderr
+const sudoPrompt = "[kubei] password for sudo: "
+
+// script is the script run by "bash -c" of Local and Docker, the stdin is /dev/null so that the commands
+// reading it don't hang or read the sudo password, as the script file run by ssh.
+func script(cmd string) string {
+	return "exec </dev/null\n" + cmd
+}
+
+// run runs the command on the local host, the stdout and the stderr are logged the same as the ones of ssh,
+// and the stderr is in the error.
+func run(host string, c *exec.Cmd) ([]byte, error) {
+	klog.V(6).Infof("[%s] [commands] Execute commands: \n%s", host, c.String())
+
+	var stdout, stderr bytes.Buffer
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	err := c.Run()
+
+	errOut := strings.Replace(stderr.String(), sudoPrompt, "", -1)
+	logLines(host, "remote-stdout", 8, stdout.Bytes())
+	logLines(host, "remote-stderr", 7, []byte(errOut))
+	if err != nil {
+		return nil, errors.Wrap(err, errOut)
+	}
+	return stdout.Bytes(), nil
+}
+
+func logLines(host, name string, level klog.Level, output []byte) {
+	if !klog.V(level) {
+		return
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if str := scanner.Text(); str != "" {
+			klog.Infof("[%s] [%s] %s", host, name, str)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/vbauerster/mpb/v6"
+)
+
+// partSuffix is the suffix of the file being copied, it is renamed to the dest file after the copy is finished.
+const partSuffix = ".part"
+
+// Local runs the commands on the host kubei runs on, so that kubei can bootstrap its own host.
+type Local struct {
+	host string
+	// password is the sudo password if kubei isn't run by root, sudo must be passwordless if it is empty
+	password string
+}
+
+func NewLocal(host, password string) *Local {
+	return &Local{host: host, password: password}
+}
+
+func (l *Local) Run(cmd string) error {
+	_, err := l.RunOut(cmd)
+	return err
+}
+
+func (l *Local) RunOut(cmd string) ([]byte, error) {
+	return run(l.host, l.command(cmd))
+}
+
+func (l *Local) command(cmd string) *exec.Cmd {
+	if os.Geteuid() == 0 {
+		return exec.Command("bash", "-c", script(cmd))
+	}
+	if l.password == "" {
+		return exec.Command("sudo", "-n", "bash", "-c", script(cmd))
+	}
+	c := exec.Command("sudo", "-S", "-p", sudoPrompt, "bash", "-c", script(cmd))
+	c.Stdin = strings.NewReader(l.password + "\n")
+	return c
+}
+
+// SendFile copies the file to dstFile and preserves the file mode, nothing is copied if they are the same file.
+func (l *Local) SendFile(dstFile, srcFile string, p *mpb.Progress) error {
+	src, err := filepath.Abs(srcFile)
+	if err != nil {
+		return err
+	}
+	dst, err := filepath.Abs(dstFile)
+	if err != nil {
+		return err
+	}
+	if src == dst {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst+partSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(dst+partSuffix, dst)
+}
+
+func (l *Local) Close() error {
+	return nil
+}
@@ -0,0 +1,45 @@
+package executor
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	if os.Geteuid() != 0 && exec.Command("sudo", "-n", "true").Run() != nil {
+		t.Skip("the local executor needs root or passwordless sudo")
+	}
+	l := NewLocal("127.0.0.1", "")
+
+	out, err := l.RunOut("a=kubei\necho \"$a\"")
+	if err != nil || string(out) != "kubei\n" {
+		t.Errorf("RunOut() = %q, %v, want %q", out, err, "kubei\n")
+	}
+	if err := l.Run("echo kubei\nfalse"); err == nil {
+		t.Error("Run() with a failed command, want error")
+	}
+	// the commands don't read the stdin, e.g. the sudo password
+	if out, err := l.RunOut("cat"); err != nil || len(out) != 0 {
+		t.Errorf("RunOut() of cat = %q, %v, want no output", out, err)
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.sh")
+	if err := ioutil.WriteFile(src, []byte("echo kubei"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "sub", "dst.sh")
+	if err := l.SendFile(dst, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(dst)
+	if err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("SendFile() mode = %v, %v, want 0755", info, err)
+	}
+	if err := l.SendFile(src, src, nil); err != nil {
+		t.Errorf("SendFile() to the same file = %v", err)
+	}
+}
@@ -2,9 +2,13 @@ package pki
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	cryptorand "crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/pem"
 	"math"
 	"math/big"
 	"net"
@@ -17,11 +21,42 @@ import (
 	"k8s.io/kubernetes/cmd/kubeadm/app/util/pkiutil"
 )
 
-// CertConfig is a wrapper around certutil.Config extending it with PublicKeyAlgorithm.
+// KeyAlgorithm is the algorithm and size of the private keys.
+type KeyAlgorithm string
+
+const (
+	RSA2048   KeyAlgorithm = "RSA-2048"
+	RSA4096   KeyAlgorithm = "RSA-4096"
+	ECDSAP256 KeyAlgorithm = "ECDSA-P256"
+)
+
+// CertConfig is a wrapper around certutil.Config extending it with KeyAlgorithm.
 type CertConfig struct {
 	certutil.Config
-	NotAfterTime       time.Duration
-	PublicKeyAlgorithm x509.PublicKeyAlgorithm
+	NotAfterTime time.Duration
+	KeyAlgorithm KeyAlgorithm
+}
+
+// ValidateKeyAlgorithm checks that the key algorithm is supported
+func ValidateKeyAlgorithm(keyAlgorithm KeyAlgorithm) error {
+	switch keyAlgorithm {
+	case RSA2048, RSA4096, ECDSAP256:
+		return nil
+	}
+	return errors.Errorf("unsupported key algorithm %q, supported: %s, %s, %s", keyAlgorithm, RSA2048, RSA4096, ECDSAP256)
+}
+
+// NewPrivateKey creates a private key with the key algorithm, RSA-2048 is used if it is empty
+func NewPrivateKey(keyAlgorithm KeyAlgorithm) (crypto.Signer, error) {
+	switch keyAlgorithm {
+	case ECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	case RSA4096:
+		return rsa.GenerateKey(cryptorand.Reader, 4096)
+	case RSA2048, "":
+		return rsa.GenerateKey(cryptorand.Reader, 2048)
+	}
+	return nil, ValidateKeyAlgorithm(keyAlgorithm)
 }
 
 // NewSelfSignedCACert creates a CA certificate
@@ -49,7 +84,7 @@ func NewSelfSignedCACert(cfg CertConfig, key crypto.Signer) (*x509.Certificate,
 
 // NewCertificateAuthority creates new certificate and private key for the certificate authority
 func NewCertificateAuthority(config *CertConfig) (*x509.Certificate, crypto.Signer, error) {
-	key, err := pkiutil.NewPrivateKey(config.PublicKeyAlgorithm)
+	key, err := NewPrivateKey(config.KeyAlgorithm)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "unable to create private key while generating CA certificate")
 	}
@@ -64,7 +99,7 @@ func NewCertificateAuthority(config *CertConfig) (*x509.Certificate, crypto.Sign
 
 // NewCertAndKey creates new certificate and key by passing the certificate authority certificate and key
 func NewCertAndKey(caCert *x509.Certificate, caKey crypto.Signer, config *CertConfig) (*x509.Certificate, crypto.Signer, error) {
-	key, err := pkiutil.NewPrivateKey(config.PublicKeyAlgorithm)
+	key, err := NewPrivateKey(config.KeyAlgorithm)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "unable to create private key")
 	}
@@ -101,7 +136,7 @@ func NewSignedCert(cfg *CertConfig, key crypto.Signer, caCert *x509.Certificate,
 		IPAddresses:  cfg.AltNames.IPs,
 		SerialNumber: serial,
 		NotBefore:    caCert.NotBefore,
-		NotAfter:     time.Now().Add(cfg.NotAfterTime).UTC(),
+		NotAfter:     notAfter(caCert, cfg.NotAfterTime),
 		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:  cfg.Usages,
 	}
@@ -112,6 +147,78 @@ func NewSignedCert(cfg *CertConfig, key crypto.Signer, caCert *x509.Certificate,
 	return x509.ParseCertificate(certDERBytes)
 }
 
+// notAfter returns the time notAfterTime from now, capped at the expiry of the CA,
+// the certificate is not valid longer than the CA signing it
+func notAfter(caCert *x509.Certificate, notAfterTime time.Duration) time.Time {
+	t := time.Now().Add(notAfterTime)
+	if t.After(caCert.NotAfter) {
+		return caCert.NotAfter.UTC()
+	}
+	return t.UTC()
+}
+
+// NewCSRAndKey creates new key and certificate signing request, so that the certificate can be signed by an external CA
+func NewCSRAndKey(config *CertConfig) (*x509.CertificateRequest, crypto.Signer, error) {
+	key, err := NewPrivateKey(config.KeyAlgorithm)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to create private key")
+	}
+
+	RemoveDuplicateAltNames(&config.AltNames)
+
+	tmpl := x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:   config.CommonName,
+			Organization: config.Organization,
+		},
+		DNSNames:    config.AltNames.DNSNames,
+		IPAddresses: config.AltNames.IPs,
+	}
+	csrDERBytes, err := x509.CreateCertificateRequest(cryptorand.Reader, &tmpl, key)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to create certificate signing request")
+	}
+
+	csr, err := x509.ParseCertificateRequest(csrDERBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return csr, key, nil
+}
+
+// ValidateCA checks that the certificate is a CA which is valid now and able to sign certificates,
+// and that the key, if given, belongs to the certificate
+func ValidateCA(cert *x509.Certificate, key crypto.Signer) error {
+	if !cert.IsCA {
+		return errors.New("the certificate is not a CA")
+	}
+	if cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return errors.New("the certificate does not have the cert sign key usage")
+	}
+
+	now := time.Now()
+	if now.Before(cert.NotBefore) {
+		return errors.Errorf("the certificate is not valid before %v", cert.NotBefore)
+	}
+	if now.After(cert.NotAfter) {
+		return errors.Errorf("the certificate expired at %v", cert.NotAfter)
+	}
+
+	if key != nil {
+		return ValidateKey(cert, key)
+	}
+	return nil
+}
+
+// ValidateKey checks that the key belongs to the certificate
+func ValidateKey(cert *x509.Certificate, key crypto.Signer) error {
+	pub, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(key.Public()) {
+		return errors.New("the private key does not match the certificate")
+	}
+	return nil
+}
+
 // RemoveDuplicateAltNames removes duplicate items in altNames.
 func RemoveDuplicateAltNames(altNames *certutil.AltNames) {
 	if altNames == nil {
@@ -138,6 +245,15 @@ func EncodeCertPEM(cert *x509.Certificate) []byte {
 	return pkiutil.EncodeCertPEM(cert)
 }
 
+// EncodeCSRPEM returns PEM-endcoded certificate signing request data
+func EncodeCSRPEM(csr *x509.CertificateRequest) []byte {
+	block := pem.Block{
+		Type:  certutil.CertificateRequestBlockType,
+		Bytes: csr.Raw,
+	}
+	return pem.EncodeToMemory(&block)
+}
+
 // EncodePrivateKeyPEM returns PEM-encoded private data
 func EncodePrivateKeyPEM(key crypto.PrivateKey) ([]byte, error) {
 	return keyutil.MarshalPrivateKeyToPEM(key)
@@ -147,3 +263,26 @@ func EncodePrivateKeyPEM(key crypto.PrivateKey) ([]byte, error) {
 func EncodePublicKeyPEM(key crypto.PublicKey) ([]byte, error) {
 	return pkiutil.EncodePublicKeyPEM(key)
 }
+
+// ParseCertPEM returns the first certificate in the PEM-encoded data
+func ParseCertPEM(data []byte) (*x509.Certificate, error) {
+	certs, err := certutil.ParseCertsPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	return certs[0], nil
+}
+
+// ParsePrivateKeyPEM returns the private key in the PEM-encoded data
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	key, err := keyutil.ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("the private key is not a crypto.Signer")
+	}
+	return signer, nil
+}
@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+// dockerHubConfigKey is the key of docker hub in the auths of docker config.json.
+const dockerHubConfigKey = "https://index.docker.io/v1/"
+
+// dockerConfig is the part of docker config.json used by kubei.
+type dockerConfig struct {
+	Auths      map[string]dockerAuth `json:"auths"`
+	CredsStore string                `json:"credsStore,omitempty"`
+}
+
+type dockerAuth struct {
+	Auth     string `json:"auth,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// getCredentials returns the username and password of the registry,
+// they are loaded from the docker config.json if they are not set in the options.
+func getCredentials(registryHost string, opts Options) (string, string, error) {
+	if opts.Username != "" {
+		return opts.Username, opts.Password, nil
+	}
+
+	file := opts.DockerConfig
+	if file == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", "", nil
+		}
+		file = filepath.Join(home, ".docker", "config.json")
+		if _, err := os.Stat(file); err != nil {
+			return "", "", nil
+		}
+	}
+
+	return dockerConfigAuth(file, registryHost)
+}
+
+// dockerConfigAuth returns the credentials of the registry in the docker config.json,
+// both the base64 encoded auth and the plain username and password are supported.
+func dockerConfigAuth(file, registryHost string) (string, string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to read docker config %s", file)
+	}
+
+	cfg := &dockerConfig{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return "", "", errors.Wrapf(err, "failed to parse docker config %s", file)
+	}
+
+	want := configKey(registryHost)
+	for key, auth := range cfg.Auths {
+		if configKey(key) != want {
+			continue
+		}
+
+		if auth.Auth == "" {
+			return auth.Username, auth.Password, nil
+		}
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "invalid auth of %s in docker config %s", key, file)
+		}
+		s := strings.SplitN(string(decoded), ":", 2)
+		if len(s) != 2 {
+			return "", "", errors.Errorf("invalid auth of %s in docker config %s", key, file)
+		}
+		return s[0], s[1], nil
+	}
+
+	if cfg.CredsStore != "" {
+		klog.Warningf("the credentials store %q in docker config %s is not supported, pull %s anonymously",
+			cfg.CredsStore, file, registryHost)
+	}
+	return "", "", nil
+}
+
+// configKey normalizes the registry in the auths of docker config.json,
+// e.g. https://registry.example.com/v2/ is registry.example.com.
+func configKey(registry string) string {
+	if registry == dockerHubConfigKey {
+		return "docker.io"
+	}
+
+	registry = strings.TrimPrefix(registry, "https://")
+	registry = strings.TrimPrefix(registry, "http://")
+	registry = strings.SplitN(registry, "/", 2)[0]
+	switch registry {
+	case "registry-1.docker.io", "index.docker.io":
+		return "docker.io"
+	}
+	return registry
+}
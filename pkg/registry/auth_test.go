@@ -0,0 +1,50 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDockerConfigAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubei-docker-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.json")
+	// dXNlcjpwYXNz is base64 of user:pass
+	if err := ioutil.WriteFile(file, []byte(`{
+  "auths": {
+    "https://index.docker.io/v1/": {"auth": "dXNlcjpwYXNz"},
+    "registry.example.com": {"username": "admin", "password": "secret"},
+    "https://mirror.example.com:5000/v2/": {"auth": "bWlycm9yOnA6YXNz"}
+  }
+}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		registry     string
+		wantUser     string
+		wantPassword string
+	}{
+		{registry: "registry-1.docker.io", wantUser: "user", wantPassword: "pass"},
+		{registry: "registry.example.com", wantUser: "admin", wantPassword: "secret"},
+		{registry: "mirror.example.com:5000", wantUser: "mirror", wantPassword: "p:ass"},
+		{registry: "k8s.gcr.io"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.registry, func(t *testing.T) {
+			user, password, err := dockerConfigAuth(file, tt.registry)
+			if err != nil {
+				t.Fatalf("dockerConfigAuth() error = %v", err)
+			}
+			if user != tt.wantUser || password != tt.wantPassword {
+				t.Errorf("dockerConfigAuth() = %q, %q, want %q, %q", user, password, tt.wantUser, tt.wantPassword)
+			}
+		})
+	}
+}
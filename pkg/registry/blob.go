@@ -0,0 +1,255 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/go-kratos/kratos/pkg/sync/errgroup"
+	"github.com/heroku/docker-registry-client/registry"
+	"github.com/mitchellh/go-homedir"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/vbauerster/mpb/v6"
+	"github.com/vbauerster/mpb/v6/decor"
+	"k8s.io/klog"
+)
+
+const (
+	// blobConcurrency is the number of the blobs downloaded at the same time
+	blobConcurrency = 4
+	// blobRetries is the number of the attempts to download a blob
+	blobRetries = 3
+	// partialSuffix is the suffix of the blob being downloaded, it is resumed by the next attempt or run
+	partialSuffix = ".partial"
+)
+
+// blobRetryInterval is the backoff between the attempts, it grows with the attempts
+var blobRetryInterval = time.Second
+
+// blobCache stores the verified blobs in <dir>/blobs/<algorithm>/<encoded>,
+// so that the blobs are not downloaded again by the next run, e.g. the layers shared by the images.
+type blobCache struct {
+	dir string
+}
+
+func newBlobCache(dir string) (*blobCache, error) {
+	if dir == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(home, ".kubei", "cache")
+	}
+	return &blobCache{dir: dir}, nil
+}
+
+func (c *blobCache) path(d digest.Digest) string {
+	return filepath.Join(c.dir, "blobs", d.Algorithm().String(), d.Encoded())
+}
+
+// has returns true if the blob is in the cache, the blobs are only moved into the cache after they are verified.
+func (c *blobCache) has(desc distribution.Descriptor) bool {
+	fi, err := os.Stat(c.path(desc.Digest))
+	return err == nil && fi.Size() == desc.Size
+}
+
+// statusError is returned when the registry responds with an unexpected status.
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %s", e.status)
+}
+
+// isRetryable returns false for the errors which will not be fixed by retrying, e.g. 404 or 401.
+func isRetryable(err error) bool {
+	var se *statusError
+	var he *registry.HttpStatusError
+	code := 0
+	switch {
+	case errors.As(err, &se):
+		code = se.code
+	case errors.As(err, &he):
+		code = he.Response.StatusCode
+	default:
+		return !errors.Is(err, context.Canceled)
+	}
+	return code >= http.StatusInternalServerError || code == http.StatusTooManyRequests
+}
+
+// fetchBlobs downloads the blobs into the cache concurrently with a shared progress container,
+// the blobs which are already in the cache are skipped.
+func fetchBlobs(hub *registry.Registry, repository string, descs []distribution.Descriptor, cache *blobCache) error {
+	// the digests are validated before any download starts, so that no download is left running on the error
+	for _, desc := range descs {
+		if err := desc.Digest.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid blob digest %q", desc.Digest)
+		}
+	}
+
+	p := mpb.New(
+		mpb.WithWidth(60),
+		mpb.WithRefreshRate(180*time.Millisecond),
+	)
+
+	g := errgroup.WithCancel(context.Background())
+	g.GOMAXPROCS(blobConcurrency)
+	seen := map[digest.Digest]bool{}
+	for _, desc := range descs {
+		desc := desc
+		if seen[desc.Digest] || cache.has(desc) {
+			klog.V(7).Infof("blob %s is in the cache", desc.Digest)
+			continue
+		}
+		seen[desc.Digest] = true
+
+		g.Go(func(ctx context.Context) error {
+			return fetchBlob(ctx, hub, p, repository, desc, cache)
+		})
+	}
+
+	err := g.Wait()
+	p.Wait()
+	return err
+}
+
+// fetchBlob downloads the blob into the cache, the transient failures are retried,
+// and each attempt resumes from the partial blob left by the previous one.
+func fetchBlob(ctx context.Context, hub *registry.Registry, p *mpb.Progress, repository string, desc distribution.Descriptor, cache *blobCache) error {
+	klog.V(7).Infof("downloading blob: %v", desc)
+	bar := p.Add(
+		desc.Size,
+		mpb.NewBarFiller("[=>-|"),
+		mpb.PrependDecorators(
+			decor.Name(desc.Digest.Encoded()[:12]),
+		),
+		mpb.AppendDecorators(
+			decor.Percentage(decor.WC{}),
+			decor.Name(" ] "),
+			decor.EwmaSpeed(decor.UnitKiB, "% .2f", 60),
+		),
+	)
+
+	var err error
+	for attempt := 1; attempt <= blobRetries; attempt++ {
+		if err = downloadBlobToCache(ctx, hub, repository, desc, cache, bar); err == nil {
+			bar.SetTotal(desc.Size, true)
+			return nil
+		}
+		if !isRetryable(err) || ctx.Err() != nil || attempt == blobRetries {
+			break
+		}
+
+		klog.V(2).Infof("failed to download blob %s/%s, retry (%d/%d): %v", repository, desc.Digest, attempt, blobRetries-1, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Duration(attempt) * blobRetryInterval):
+		}
+	}
+
+	bar.Abort(false)
+	return errors.Wrapf(err, "failed to download blob: %s/%s", repository, desc.Digest)
+}
+
+func downloadBlobToCache(ctx context.Context, hub *registry.Registry, repository string, desc distribution.Descriptor, cache *blobCache, bar *mpb.Bar) error {
+	file := cache.path(desc.Digest)
+	partial := file + partialSuffix
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	var offset int64
+	if fi, err := os.Stat(partial); err == nil && fi.Size() <= desc.Size {
+		offset = fi.Size()
+	}
+
+	if offset < desc.Size {
+		var err error
+		if offset, err = downloadBlobRange(ctx, hub, repository, desc, partial, offset, bar); err != nil {
+			return err
+		}
+	}
+
+	// the partial blob is removed if it is broken, so that the next attempt downloads it from the beginning
+	if err := verifyBlob(partial, desc); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return os.Rename(partial, file)
+}
+
+// downloadBlobRange appends the blob from the offset to the partial blob by a HTTP range request,
+// the blob is downloaded from the beginning if the registry does not support range requests.
+func downloadBlobRange(ctx context.Context, hub *registry.Registry, repository string, desc distribution.Descriptor, partial string, offset int64, bar *mpb.Bar) (int64, error) {
+	url := fmt.Sprintf("%s/v2/%s/blobs/%s", hub.URL, repository, desc.Digest)
+	hub.Logf("registry.blob.download url=%s repository=%s digest=%s offset=%d", url, repository, desc.Digest, offset)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := hub.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusOK:
+		offset = 0
+		flags |= os.O_TRUNC
+	default:
+		return 0, &statusError{code: resp.StatusCode, status: resp.Status}
+	}
+
+	f, err := os.OpenFile(partial, flags, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	bar.SetCurrent(offset)
+	// never read more than the size of the blob, a longer blob is a broken one
+	n, err := io.Copy(f, bar.ProxyReader(io.LimitReader(resp.Body, desc.Size-offset+1)))
+	if err != nil {
+		return 0, err
+	}
+	return offset + n, f.Close()
+}
+
+func verifyBlob(file string, desc distribution.Descriptor) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	verifier := desc.Digest.Verifier()
+	n, err := io.Copy(verifier, f)
+	if err != nil {
+		return err
+	}
+	if n != desc.Size {
+		return errors.Errorf("size mismatch for blob %s: expected %d, got %d", desc.Digest, desc.Size, n)
+	}
+	if !verifier.Verified() {
+		return errors.Errorf("digest mismatch for blob %s", desc.Digest)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package registry
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/heroku/docker-registry-client/registry"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestFetchBlobsResume(t *testing.T) {
+	blob := make([]byte, 64<<10)
+	rand.Read(blob)
+	desc := distribution.Descriptor{Digest: digest.FromBytes(blob), Size: int64(len(blob))}
+
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(blob))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "kubei-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cache, err := newBlobCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first half of the blob is left by an interrupted download
+	file := cache.path(desc.Digest)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file+partialSuffix, blob[:len(blob)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hub := &registry.Registry{URL: server.URL, Client: server.Client(), Logf: registryLog}
+	if err := fetchBlobs(hub, "library/test", []distribution.Descriptor{desc, desc}, cache); err != nil {
+		t.Fatalf("fetchBlobs() error = %v", err)
+	}
+
+	if len(ranges) != 1 || ranges[0] != "bytes=32768-" {
+		t.Errorf("requests with ranges %q, want one request with bytes=32768-", ranges)
+	}
+	got, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, blob) {
+		t.Error("cached blob differs")
+	}
+	if _, err := os.Stat(file + partialSuffix); !os.IsNotExist(err) {
+		t.Errorf("partial blob is left: %v", err)
+	}
+
+	// the cached blob is not downloaded again
+	if err := fetchBlobs(hub, "library/test", []distribution.Descriptor{desc}, cache); err != nil {
+		t.Fatalf("fetchBlobs() error = %v", err)
+	}
+	if len(ranges) != 1 {
+		t.Errorf("cached blob is downloaded again")
+	}
+}
+
+func TestFetchBlobsInvalidDigest(t *testing.T) {
+	blob := []byte("blob")
+	descs := []distribution.Descriptor{
+		{Digest: digest.FromBytes(blob), Size: int64(len(blob))},
+		{Digest: "sha256:invalid", Size: 1},
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(blob)
+	}))
+	defer server.Close()
+
+	cache, err := newBlobCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hub := &registry.Registry{URL: server.URL, Client: server.Client(), Logf: registryLog}
+	if err := fetchBlobs(hub, "library/test", descs, cache); err == nil {
+		t.Fatal("fetchBlobs() with an invalid digest, want error")
+	}
+	// nothing is downloaded, even the blob before the invalid one
+	if requests != 0 {
+		t.Errorf("%d requests, want none", requests)
+	}
+}
@@ -2,104 +2,162 @@ package registry
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
+	"github.com/docker/distribution"
 	"github.com/heroku/docker-registry-client/registry"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
-	"github.com/vbauerster/mpb/v6"
-	"github.com/vbauerster/mpb/v6/decor"
 	"k8s.io/klog"
 )
 
-func DownloadImage(imageUrl, user, password, destPath string) error {
+// DownloadImage downloads the image into a tarball in the dest path,
+// which can be loaded by `docker load` or imported as an OCI image layout.
+func DownloadImage(imageUrl string, opts Options, destPath string) error {
 	img, err := checkImageUrl(imageUrl)
 	if err != nil {
 		return errors.Wrapf(err, "failed to check image url: %s", imageUrl)
 	}
 
-	hub, err := New(fmt.Sprintf("%s://%s", img.Scheme, img.Registry), user, password)
+	platform, err := opts.platform()
 	if err != nil {
-		return errors.Wrapf(err, "failed to create registry client whit registry url: %s", img.Registry)
+		return err
+	}
+
+	hub, err := newHub(img, opts)
+	if err != nil {
+		return err
 	}
-	return downloadImageFromRepository(hub, img.Repository, img.Tag, destPath)
+	return downloadImageFromRepository(hub, img, platform, opts.CacheDir, destPath)
 }
 
-func downloadImageFromRepository(hub *registry.Registry, repository, tag, destPath string) error {
-	file := fmt.Sprintf("%s_%s", strings.ReplaceAll(repository, "/", "-"), tag)
-	fw, err := os.Create(filepath.Join(destPath, file))
+func downloadImageFromRepository(hub *registry.Registry, img image, platform ocispec.Platform, cacheDir, destPath string) (err error) {
+	manifest, err := getManifest(hub, img.Repository, img.reference(), platform)
 	if err != nil {
+		return errors.Wrapf(err, "failed to get manifest of repository %s", img.Repository)
+	}
+	manifestDesc := manifest.descriptor()
+
+	cache, err := newBlobCache(cacheDir)
+	if err != nil {
+		return err
+	}
+	descs := append([]distribution.Descriptor{manifest.Config}, manifest.Layers...)
+	if err := fetchBlobs(hub, img.Repository, descs, cache); err != nil {
 		return err
 	}
 
-	tw := tar.NewWriter(fw)
-	defer tw.Close()
+	if err := os.MkdirAll(destPath, 0755); err != nil {
+		return err
+	}
 
-	_, err = hub.ManifestV2(repository, tag)
+	// write to a temporary file first, so that a broken tarball is never left in the dest path
+	file := filepath.Join(destPath, fmt.Sprintf("%s_%s.tar", strings.ReplaceAll(img.Repository, "/", "-"), img.fileTag()))
+	tmpFile := file + ".tmp"
+	fw, err := os.Create(tmpFile)
 	if err != nil {
-		return errors.Wrapf(err, "failed to get repository %s manifestV2", repository)
+		return err
 	}
+	defer func() {
+		fw.Close()
+		if err != nil {
+			os.Remove(tmpFile)
+		}
+	}()
 
-	// todo download image layer
-	return nil
+	iw := newImageWriter(fw)
+	for _, desc := range descs {
+		if err := writeCachedBlob(cache, desc, iw); err != nil {
+			return err
+		}
+	}
+
+	if err := iw.writeBlob(bytes.NewReader(manifest.payload), manifestDesc); err != nil {
+		return err
+	}
+
+	if err := iw.addImage(img.Name(), img.Tag, manifestDesc, manifest.Config, manifest.Layers); err != nil {
+		return err
+	}
+
+	if err := iw.Close(); err != nil {
+		return err
+	}
+
+	if err := fw.Close(); err != nil {
+		return err
+	}
+
+	klog.V(2).Infof("downloaded image %s of %s to %s", img, platformString(platform), file)
+	return os.Rename(tmpFile, file)
 }
 
-func DownloadFile(imageUrl, user, password, destPath string) error {
+func writeCachedBlob(cache *blobCache, desc distribution.Descriptor, iw *imageWriter) error {
+	f, err := os.Open(cache.path(desc.Digest))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return iw.writeBlob(f, desc)
+}
+
+// DownloadFile downloads the files packed in the layers of the image into the dest path.
+func DownloadFile(imageUrl string, opts Options, destPath string) error {
 	img, err := checkImageUrl(imageUrl)
 	if err != nil {
 		return errors.Wrapf(err, "failed to check image url: %s", imageUrl)
 	}
 
-	hub, err := New(fmt.Sprintf("%s://%s", img.Scheme, img.Registry), user, password)
+	platform, err := opts.platform()
 	if err != nil {
-		return errors.Wrapf(err, "failed to create registry client whit registry url: %s", img.Registry)
+		return err
 	}
-	return downloadFileFromRepository(hub, img.Repository, img.Tag, destPath)
+
+	hub, err := newHub(img, opts)
+	if err != nil {
+		return err
+	}
+	return downloadFileFromRepository(hub, img.Repository, img.reference(), platform, opts.CacheDir, destPath)
 }
 
-func downloadFileFromRepository(hub *registry.Registry, repository, tag, destPath string) error {
-
-	manifestV2, err := hub.ManifestV2(repository, tag)
-	if err != nil {
-		return errors.Wrapf(err, "failed to get repository %s manifestV2", repository)
-	}
-
-	for _, layer := range manifestV2.Layers {
-		klog.V(7).Infof("downloading layer: %v", layer)
-		p := mpb.New(
-			mpb.WithWidth(60),
-			mpb.WithRefreshRate(180*time.Millisecond),
-		)
-		bar := p.Add(
-			layer.Size,
-			mpb.NewBarFiller("[=>-|"),
-			mpb.AppendDecorators(
-				decor.Percentage(decor.WC{}),
-				decor.Name(" ] "),
-				decor.EwmaSpeed(decor.UnitKiB, "% .2f", 60),
-			),
-		)
-		blob, err := hub.DownloadBlob(repository, layer.Digest)
-		if err != nil {
-			errors.Wrapf(err, "failed to download blob: %s/%s", repository, layer.Digest)
-		}
-		proxyReader := bar.ProxyReader(blob)
+func downloadFileFromRepository(hub *registry.Registry, repository, reference string, platform ocispec.Platform, cacheDir, destPath string) error {
+	manifest, err := getManifest(hub, repository, reference, platform)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get manifest of repository %s", repository)
+	}
 
-		if err := writToFile(proxyReader, destPath); err != nil {
-			return errors.Wrapf(err, "failed to download blob: %s/%s", repository, layer.Digest)
-		}
+	cache, err := newBlobCache(cacheDir)
+	if err != nil {
+		return err
+	}
+	if err := fetchBlobs(hub, repository, manifest.Layers, cache); err != nil {
+		return err
+	}
 
-		blob.Close()
-		proxyReader.Close()
+	// the layers are extracted in order, so that the files in the upper layers win
+	for _, layer := range manifest.Layers {
+		if err := extractCachedLayer(cache, layer, destPath); err != nil {
+			return errors.Wrapf(err, "failed to extract layer: %s/%s", repository, layer.Digest)
+		}
 	}
 	return nil
 }
 
+func extractCachedLayer(cache *blobCache, layer distribution.Descriptor, destPath string) error {
+	f, err := os.Open(cache.path(layer.Digest))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return writToFile(f, destPath)
+}
+
 func writToFile(r io.Reader, destPath string) error {
 	gr, err := gzip.NewReader(r)
 	if err != nil {
@@ -116,22 +174,51 @@ func writToFile(r io.Reader, destPath string) error {
 				return err
 			}
 		}
+
+		// never write outside of the dest path
 		filename := filepath.Join(destPath, hdr.Name)
-		file, err := createFile(filename)
-		if err != nil {
-			return err
+		if rel, err := filepath.Rel(destPath, filename); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			return errors.Errorf("invalid file name %q in layer", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(filename, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			mode := os.FileMode(hdr.Mode).Perm()
+			if mode == 0 {
+				mode = 0644
+			}
+			if err := writeFile(filename, tr, mode); err != nil {
+				return err
+			}
+		default:
+			klog.V(2).Infof("skip %s with unsupported type %c in layer", hdr.Name, hdr.Typeflag)
 		}
-		io.Copy(file, tr)
 	}
 	return nil
 }
 
-func createFile(name string) (*os.File, error) {
+func writeFile(name string, r io.Reader, mode os.FileMode) error {
+	file, err := createFile(name, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func createFile(name string, mode os.FileMode) (*os.File, error) {
 	err := os.MkdirAll(filepath.Dir(name), 0755)
 	if err != nil {
 		return nil, err
 	}
-	return os.Create(name)
+	return os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
 }
 
 func tarFromReader(r io.Reader, name string, size int64, tw *tar.Writer) error {
@@ -1,7 +1,26 @@
 package registry
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 func TestDownloadFile(t *testing.T) {
@@ -26,9 +45,197 @@ func TestDownloadFile(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := DownloadFile(tt.args.imageUrl, tt.args.user, tt.args.password, tt.args.destPath); (err != nil) != tt.wantErr {
+			if err := DownloadFile(tt.args.imageUrl, Options{Username: tt.args.user, Password: tt.args.password}, tt.args.destPath); (err != nil) != tt.wantErr {
 				t.Errorf("DownloadFile() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+// newTestRegistry serves the blobs and the manifest of repository:tag like a docker registry.
+func newTestRegistry(t *testing.T, repository, tag string, manifest []byte, blobs map[digest.Digest][]byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == fmt.Sprintf("/v2/%s/manifests/%s", repository, tag):
+			w.Header().Set("Content-Type", schema2.MediaTypeManifest)
+			w.Header().Set("Docker-Content-Digest", digest.FromBytes(manifest).String())
+			w.Write(manifest)
+		case strings.HasPrefix(r.URL.Path, fmt.Sprintf("/v2/%s/blobs/", repository)):
+			blob, ok := blobs[digest.Digest(path.Base(r.URL.Path))]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			// ServeContent supports the range requests used to resume the downloads
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(blob))
+		default:
+			t.Logf("unexpected request: %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func newTestLayer(t *testing.T, name, content string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	gw.Close()
+	return buf.Bytes()
+}
+
+func TestDownloadImage(t *testing.T) {
+	config := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`)
+	layers := [][]byte{newTestLayer(t, "a.txt", "a"), newTestLayer(t, "b.txt", "b")}
+
+	blobs := map[digest.Digest][]byte{digest.FromBytes(config): config}
+	m := schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config: distribution.Descriptor{
+			MediaType: schema2.MediaTypeImageConfig,
+			Digest:    digest.FromBytes(config),
+			Size:      int64(len(config)),
+		},
+	}
+	for _, layer := range layers {
+		blobs[digest.FromBytes(layer)] = layer
+		m.Layers = append(m.Layers, distribution.Descriptor{
+			MediaType: schema2.MediaTypeLayer,
+			Digest:    digest.FromBytes(layer),
+			Size:      int64(len(layer)),
+		})
+	}
+	manifest, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("download", func(t *testing.T) {
+		server := newTestRegistry(t, "library/test", "v1", manifest, blobs)
+		defer server.Close()
+
+		dir, err := ioutil.TempDir("", "kubei-image")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		if err := DownloadImage(server.URL+"/library/test:v1", Options{CacheDir: filepath.Join(dir, "cache")}, filepath.Join(dir, "images")); err != nil {
+			t.Fatalf("DownloadImage() error = %v", err)
+		}
+
+		files := readTestTar(t, filepath.Join(dir, "images", "library-test_v1.tar"))
+
+		var dockerManifests []dockerManifest
+		if err := json.Unmarshal(files["manifest.json"], &dockerManifests); err != nil {
+			t.Fatalf("failed to parse manifest.json: %v", err)
+		}
+		if len(dockerManifests) != 1 {
+			t.Fatalf("manifest.json has %d images, want 1", len(dockerManifests))
+		}
+		host := strings.TrimPrefix(server.URL, "http://")
+		if got := dockerManifests[0].RepoTags; len(got) != 1 || got[0] != host+"/library/test:v1" {
+			t.Errorf("RepoTags = %v", got)
+		}
+		if !bytes.Equal(files[dockerManifests[0].Config], config) {
+			t.Errorf("config %s differs", dockerManifests[0].Config)
+		}
+		for i, layer := range dockerManifests[0].Layers {
+			if !bytes.Equal(files[layer], layers[i]) {
+				t.Errorf("layer %s differs", layer)
+			}
+		}
+
+		var index ocispec.Index
+		if err := json.Unmarshal(files["index.json"], &index); err != nil {
+			t.Fatalf("failed to parse index.json: %v", err)
+		}
+		if len(index.Manifests) != 1 || index.Manifests[0].Digest != digest.FromBytes(manifest) {
+			t.Fatalf("index.json = %s", files["index.json"])
+		}
+		if !bytes.Equal(files[blobPath(index.Manifests[0].Digest)], manifest) {
+			t.Error("manifest blob differs")
+		}
+		if _, ok := files[ocispec.ImageLayoutFile]; !ok {
+			t.Error("oci-layout is missing")
+		}
+
+		var repositories map[string]map[string]string
+		if err := json.Unmarshal(files["repositories"], &repositories); err != nil {
+			t.Fatalf("failed to parse repositories: %v", err)
+		}
+		if repositories[host+"/library/test"]["v1"] != digest.FromBytes(layers[1]).Encoded() {
+			t.Errorf("repositories = %s", files["repositories"])
+		}
+	})
+
+	t.Run("digest mismatch", func(t *testing.T) {
+		corrupted := map[digest.Digest][]byte{}
+		for d, blob := range blobs {
+			corrupted[d] = blob
+		}
+		broken := append([]byte{}, layers[1]...)
+		broken[len(broken)-1] ^= 0xff
+		corrupted[digest.FromBytes(layers[1])] = broken
+
+		server := newTestRegistry(t, "library/test", "v1", manifest, corrupted)
+		defer server.Close()
+
+		dir, err := ioutil.TempDir("", "kubei-image")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		defer func(interval time.Duration) { blobRetryInterval = interval }(blobRetryInterval)
+		blobRetryInterval = time.Millisecond
+
+		if err := DownloadImage(server.URL+"/library/test:v1", Options{CacheDir: filepath.Join(dir, "cache")}, filepath.Join(dir, "images")); err == nil {
+			t.Fatal("DownloadImage() error = nil, want digest mismatch")
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "cache", "blobs", "sha256", digest.FromBytes(layers[1]).Encoded())); !os.IsNotExist(err) {
+			t.Errorf("broken blob is left in the cache: %v", err)
+		}
+
+		for _, file := range []string{"library-test_v1.tar", "library-test_v1.tar.tmp"} {
+			if _, err := os.Stat(filepath.Join(dir, "images", file)); !os.IsNotExist(err) {
+				t.Errorf("broken tarball %s is left: %v", file, err)
+			}
+		}
+	})
+}
+
+func readTestTar(t *testing.T, file string) map[string][]byte {
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = content
+	}
+	return files
+}
@@ -0,0 +1,155 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"path"
+
+	"github.com/docker/distribution"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// dockerManifest is an item of the manifest.json of a `docker save` tarball.
+type dockerManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// imageWriter writes images into a tarball, which can be loaded by `docker load`,
+// and is an OCI image layout at the same time, e.g. for `ctr images import`.
+// All blobs are stored in blobs/<algorithm>/<encoded>, manifest.json and repositories refer to them for docker.
+type imageWriter struct {
+	tw              *tar.Writer
+	blobs           map[digest.Digest]bool
+	index           ocispec.Index
+	dockerManifests []dockerManifest
+	repositories    map[string]map[string]string
+}
+
+func newImageWriter(w io.Writer) *imageWriter {
+	return &imageWriter{
+		tw:    tar.NewWriter(w),
+		blobs: map[digest.Digest]bool{},
+		index: ocispec.Index{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+		},
+		repositories: map[string]map[string]string{},
+	}
+}
+
+func blobPath(d digest.Digest) string {
+	return path.Join("blobs", d.Algorithm().String(), d.Encoded())
+}
+
+// writeBlob writes the blob into the tarball and verifies its size and digest.
+// A blob which is already written is skipped.
+func (w *imageWriter) writeBlob(r io.Reader, desc distribution.Descriptor) error {
+	if err := desc.Digest.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid digest %q", desc.Digest)
+	}
+	if w.blobs[desc.Digest] {
+		return nil
+	}
+
+	if err := w.tw.WriteHeader(&tar.Header{
+		Mode: 0644,
+		Size: desc.Size,
+		Name: blobPath(desc.Digest),
+	}); err != nil {
+		return err
+	}
+
+	verifier := desc.Digest.Verifier()
+	n, err := io.Copy(w.tw, io.TeeReader(r, verifier))
+	if err != nil {
+		return errors.Wrapf(err, "failed to write blob %s", desc.Digest)
+	}
+	if n != desc.Size {
+		return errors.Errorf("size mismatch for blob %s: expected %d, got %d", desc.Digest, desc.Size, n)
+	}
+	if !verifier.Verified() {
+		return errors.Errorf("digest mismatch for blob %s", desc.Digest)
+	}
+
+	w.blobs[desc.Digest] = true
+	return nil
+}
+
+// addImage adds the image to index.json, manifest.json and repositories.
+// The manifest, the config and the layers must have been written by writeBlob.
+func (w *imageWriter) addImage(name, tag string, manifestDesc, config distribution.Descriptor, layers []distribution.Descriptor) error {
+	for _, desc := range append([]distribution.Descriptor{manifestDesc, config}, layers...) {
+		if !w.blobs[desc.Digest] {
+			return errors.Errorf("blob %s of image %s:%s is not written", desc.Digest, name, tag)
+		}
+	}
+
+	// an image referenced only by digest is loaded by docker as an untagged image
+	ref := name + ":" + tag
+	annotations := map[string]string{
+		ocispec.AnnotationRefName:  tag,
+		"io.containerd.image.name": ref,
+	}
+	var repoTags []string
+	if tag == "" {
+		ref = name + "@" + manifestDesc.Digest.String()
+		annotations = map[string]string{"io.containerd.image.name": ref}
+	} else {
+		repoTags = []string{ref}
+	}
+
+	w.index.Manifests = append(w.index.Manifests, ocispec.Descriptor{
+		MediaType:   manifestDesc.MediaType,
+		Digest:      manifestDesc.Digest,
+		Size:        manifestDesc.Size,
+		Annotations: annotations,
+	})
+
+	m := dockerManifest{
+		Config:   blobPath(config.Digest),
+		RepoTags: repoTags,
+	}
+	for _, layer := range layers {
+		m.Layers = append(m.Layers, blobPath(layer.Digest))
+	}
+	w.dockerManifests = append(w.dockerManifests, m)
+
+	if len(layers) > 0 && tag != "" {
+		if w.repositories[name] == nil {
+			w.repositories[name] = map[string]string{}
+		}
+		w.repositories[name][tag] = layers[len(layers)-1].Digest.Encoded()
+	}
+	return nil
+}
+
+// Close writes oci-layout, index.json, manifest.json and repositories, and closes the tarball.
+func (w *imageWriter) Close() error {
+	files := []struct {
+		name string
+		v    interface{}
+	}{
+		{name: ocispec.ImageLayoutFile, v: ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion}},
+		{name: "index.json", v: w.index},
+		{name: "manifest.json", v: w.dockerManifests},
+		{name: "repositories", v: w.repositories},
+	}
+
+	for _, f := range files {
+		data, err := json.Marshal(f.v)
+		if err != nil {
+			return err
+		}
+		if err := tarFromReader(bytes.NewReader(data), f.name, int64(len(data)), w.tw); err != nil {
+			return err
+		}
+	}
+
+	return w.tw.Close()
+}
@@ -0,0 +1,153 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/heroku/docker-registry-client/registry"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// maxManifestSize limits the size of the manifests read into memory.
+const maxManifestSize = 4 << 20
+
+// imageManifest is the manifest of a single platform image,
+// either a docker schema2 manifest or an OCI image manifest, which have the same layout.
+type imageManifest struct {
+	MediaType string                    `json:"mediaType,omitempty"`
+	Config    distribution.Descriptor   `json:"config"`
+	Layers    []distribution.Descriptor `json:"layers"`
+
+	// payload is the manifest as it is in the registry, so that its digest is kept
+	payload []byte
+}
+
+// descriptor returns the descriptor of the manifest itself.
+func (m *imageManifest) descriptor() distribution.Descriptor {
+	return distribution.Descriptor{
+		MediaType: m.MediaType,
+		Digest:    digest.FromBytes(m.payload),
+		Size:      int64(len(m.payload)),
+	}
+}
+
+// imageIndex is a docker manifest list or an OCI image index.
+type imageIndex struct {
+	MediaType string               `json:"mediaType,omitempty"`
+	Manifests []ocispec.Descriptor `json:"manifests"`
+}
+
+// getManifest gets the manifest of the image, if the reference is a manifest list or an OCI image index,
+// the manifest of the platform in it is used. The reference is a tag or a digest.
+func getManifest(hub *registry.Registry, repository, reference string, platform ocispec.Platform) (*imageManifest, error) {
+	mediaType, payload, err := fetchManifest(hub, repository, reference,
+		schema2.MediaTypeManifest, manifestlist.MediaTypeManifestList,
+		ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mediaType {
+	case manifestlist.MediaTypeManifestList, ocispec.MediaTypeImageIndex:
+		index := &imageIndex{}
+		if err := json.Unmarshal(payload, index); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse manifest list of %s:%s", repository, reference)
+		}
+		desc, err := selectPlatform(index.Manifests, platform)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to select manifest from manifest list of %s:%s", repository, reference)
+		}
+		if desc.MediaType == manifestlist.MediaTypeManifestList || desc.MediaType == ocispec.MediaTypeImageIndex {
+			return nil, errors.Errorf("nested manifest list %s in %s:%s is not supported", desc.Digest, repository, reference)
+		}
+		return getManifest(hub, repository, desc.Digest.String(), platform)
+	case schema2.MediaTypeManifest, ocispec.MediaTypeImageManifest:
+		m := &imageManifest{}
+		if err := json.Unmarshal(payload, m); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse manifest of %s:%s", repository, reference)
+		}
+		m.MediaType = mediaType
+		m.payload = payload
+		return m, nil
+	default:
+		return nil, errors.Errorf("unsupported manifest media type %q of %s:%s", mediaType, repository, reference)
+	}
+}
+
+// fetchManifest gets the manifest with one of the media types,
+// the manifest is verified if the reference is a digest.
+func fetchManifest(hub *registry.Registry, repository, reference string, mediaTypes ...string) (string, []byte, error) {
+	url := fmt.Sprintf("%s/v2/%s/manifests/%s", hub.URL, repository, reference)
+	hub.Logf("registry.manifest.get url=%s repository=%s reference=%s", url, repository, reference)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("Accept", strings.Join(mediaTypes, ", "))
+
+	resp, err := hub.Client.Do(req)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "failed to get manifest of %s:%s", repository, reference)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, errors.Errorf("failed to get manifest of %s:%s: unexpected status %s", repository, reference, resp.Status)
+	}
+
+	payload, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "failed to read manifest of %s:%s", repository, reference)
+	}
+
+	if d, err := digest.Parse(reference); err == nil && d.Algorithm().FromBytes(payload) != d {
+		return "", nil, errors.Errorf("digest mismatch for manifest of %s@%s", repository, reference)
+	}
+
+	mediaType := strings.TrimSpace(strings.SplitN(resp.Header.Get("Content-Type"), ";", 2)[0])
+	if !contains(mediaTypes, mediaType) {
+		mediaType = detectMediaType(payload)
+	}
+	return mediaType, payload, nil
+}
+
+// detectMediaType gets the media type from the manifest itself, for the registries responding
+// with a generic Content-Type, e.g. application/json. The mediaType field is optional in OCI manifests.
+func detectMediaType(payload []byte) string {
+	var m struct {
+		MediaType string            `json:"mediaType"`
+		Manifests []json.RawMessage `json:"manifests"`
+		Config    json.RawMessage   `json:"config"`
+	}
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return ""
+	}
+
+	switch {
+	case m.MediaType != "":
+		return m.MediaType
+	case m.Manifests != nil:
+		return ocispec.MediaTypeImageIndex
+	case m.Config != nil:
+		return ocispec.MediaTypeImageManifest
+	}
+	return ""
+}
+
+func contains(s []string, v string) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// parsePlatform parses the platform in the form of os/arch[/variant], e.g. linux/arm64 or linux/arm/v7.
+func parsePlatform(s string) (ocispec.Platform, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return ocispec.Platform{}, errors.Errorf("invalid platform %q, it should be os/arch[/variant], e.g. linux/arm64", s)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return ocispec.Platform{}, errors.Errorf("invalid platform %q, it should be os/arch[/variant], e.g. linux/arm64", s)
+		}
+	}
+
+	p := ocispec.Platform{
+		OS:           strings.ToLower(parts[0]),
+		Architecture: strings.ToLower(parts[1]),
+	}
+	if len(parts) == 3 {
+		p.Variant = strings.ToLower(parts[2])
+	}
+	return normalizePlatform(p), nil
+}
+
+// normalizePlatform converts the aliases of the architectures to the names used by the images,
+// e.g. x86_64 is amd64 and aarch64 is arm64.
+func normalizePlatform(p ocispec.Platform) ocispec.Platform {
+	switch p.Architecture {
+	case "x86_64", "x86-64":
+		p.Architecture = "amd64"
+	case "aarch64":
+		p.Architecture = "arm64"
+	case "armhf":
+		p.Architecture, p.Variant = "arm", "v7"
+	case "armel":
+		p.Architecture, p.Variant = "arm", "v6"
+	}
+	// arm64 has only one variant, v8 is the same as no variant
+	if p.Architecture == "arm64" && p.Variant == "v8" {
+		p.Variant = ""
+	}
+	return p
+}
+
+func platformString(p ocispec.Platform) string {
+	if p.Variant == "" {
+		return fmt.Sprintf("%s/%s", p.OS, p.Architecture)
+	}
+	return fmt.Sprintf("%s/%s/%s", p.OS, p.Architecture, p.Variant)
+}
+
+// selectPlatform selects the manifest of the platform from a manifest list or an OCI image index.
+// The variant is only compared if it is set in the wanted platform, the first match wins.
+func selectPlatform(manifests []ocispec.Descriptor, want ocispec.Platform) (ocispec.Descriptor, error) {
+	want = normalizePlatform(want)
+
+	var available []string
+	for _, m := range manifests {
+		if m.Platform == nil {
+			continue
+		}
+		p := normalizePlatform(*m.Platform)
+		available = append(available, platformString(p))
+		if p.OS != want.OS || p.Architecture != want.Architecture {
+			continue
+		}
+		if want.Variant != "" && p.Variant != want.Variant {
+			continue
+		}
+		return m, nil
+	}
+	return ocispec.Descriptor{}, errors.Errorf("no manifest of %s, the platforms are [%s]", platformString(want), strings.Join(available, ", "))
+}
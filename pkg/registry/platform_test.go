@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestSelectPlatform(t *testing.T) {
+	manifests := []ocispec.Descriptor{
+		{Digest: digest.FromString("amd64"), Platform: &ocispec.Platform{OS: "linux", Architecture: "amd64"}},
+		{Digest: digest.FromString("arm-v6"), Platform: &ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}},
+		{Digest: digest.FromString("arm-v7"), Platform: &ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{Digest: digest.FromString("arm64"), Platform: &ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+		{Digest: digest.FromString("attestation")},
+	}
+
+	tests := []struct {
+		platform string
+		want     digest.Digest
+		wantErr  bool
+	}{
+		{platform: "linux/amd64", want: digest.FromString("amd64")},
+		{platform: "linux/x86_64", want: digest.FromString("amd64")},
+		{platform: "linux/arm64", want: digest.FromString("arm64")},
+		{platform: "linux/aarch64", want: digest.FromString("arm64")},
+		{platform: "linux/arm64/v8", want: digest.FromString("arm64")},
+		{platform: "linux/arm", want: digest.FromString("arm-v6")},
+		{platform: "linux/arm/v7", want: digest.FromString("arm-v7")},
+		{platform: "linux/s390x", wantErr: true},
+		{platform: "windows/amd64", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platform, func(t *testing.T) {
+			p, err := parsePlatform(tt.platform)
+			if err != nil {
+				t.Fatalf("parsePlatform() error = %v", err)
+			}
+			got, err := selectPlatform(manifests, p)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectPlatform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Digest != tt.want {
+				t.Errorf("selectPlatform() = %s, want %s", got.Digest, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePlatformInvalid(t *testing.T) {
+	for _, s := range []string{"", "linux", "linux/", "/amd64", "linux/arm/v7/extra"} {
+		if _, err := parsePlatform(s); err == nil {
+			t.Errorf("parsePlatform(%q) error = nil, want error", s)
+		}
+	}
+}
+
+func TestCheckImageUrl(t *testing.T) {
+	d := digest.FromString("manifest")
+	tests := []struct {
+		imageUrl string
+		want     image
+		wantErr  bool
+	}{
+		{
+			imageUrl: "nginx:1.17",
+			want:     image{Registry: "registry-1.docker.io", Repository: "library/nginx", Tag: "1.17", Scheme: "https"},
+		},
+		{
+			imageUrl: "k8s.gcr.io/pause@" + d.String(),
+			want:     image{Registry: "k8s.gcr.io", Repository: "pause", Digest: d, Scheme: "https"},
+		},
+		{
+			imageUrl: "http://127.0.0.1:5000/kubebin/kube-files:v1.22.4@" + d.String(),
+			want:     image{Registry: "127.0.0.1:5000", Repository: "kubebin/kube-files", Tag: "v1.22.4", Digest: d, Scheme: "http"},
+		},
+		{imageUrl: "k8s.gcr.io/pause", wantErr: true},
+		{imageUrl: "k8s.gcr.io/pause@sha256:invalid", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.imageUrl, func(t *testing.T) {
+			got, err := checkImageUrl(tt.imageUrl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkImageUrl() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("checkImageUrl() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
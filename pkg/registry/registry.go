@@ -8,6 +8,8 @@ import (
 	"strings"
 
 	"github.com/heroku/docker-registry-client/registry"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"k8s.io/klog"
 )
@@ -16,7 +18,93 @@ type image struct {
 	Registry   string
 	Repository string
 	Tag        string
-	Scheme     string
+	// Digest is set if the image is referenced by digest, e.g. nginx@sha256:...
+	Digest digest.Digest
+	Scheme string
+}
+
+// reference returns the digest of the image if it is set, otherwise the tag,
+// it is the reference of the manifest in the registry.
+func (i image) reference() string {
+	if i.Digest != "" {
+		return i.Digest.String()
+	}
+	return i.Tag
+}
+
+// String returns the image url without the scheme, e.g. registry-1.docker.io/library/nginx:1.17.
+func (i image) String() string {
+	s := i.Registry + "/" + i.Repository
+	if i.Tag != "" {
+		s += ":" + i.Tag
+	}
+	if i.Digest != "" {
+		s += "@" + i.Digest.String()
+	}
+	return s
+}
+
+// fileTag returns the tag of the image used in the file name, it is the digest if the image has no tag.
+func (i image) fileTag() string {
+	if i.Tag != "" {
+		return i.Tag
+	}
+	return strings.ReplaceAll(i.Digest.String(), ":", "-")
+}
+
+// Name returns the name of the image used by docker, e.g. docker.io/library/nginx.
+func (i image) Name() string {
+	registry := i.Registry
+	if registry == "registry-1.docker.io" || registry == "index.docker.io" {
+		registry = "docker.io"
+	}
+	return registry + "/" + i.Repository
+}
+
+// Options are the options to pull from a registry.
+type Options struct {
+	Username string
+	Password string
+	// DockerConfig is the path to the docker config.json, the credentials of the registry in it are used
+	// if Username is not set, it is $HOME/.docker/config.json by default
+	DockerConfig string
+	// Arch is the architecture selected from the manifest lists, it is amd64 by default
+	Arch string
+	// Platform is the platform selected from the manifest lists in the form of os/arch[/variant],
+	// e.g. linux/arm64, it overrides Arch if it is set
+	Platform string
+	// CacheDir is the dir of the blob cache, the downloaded blobs are kept in it
+	// and the interrupted downloads are resumed from it, it is $HOME/.kubei/cache by default
+	CacheDir string
+}
+
+func (o Options) arch() string {
+	if o.Arch == "" {
+		return defaultArch
+	}
+	return o.Arch
+}
+
+const defaultArch = "amd64"
+
+func (o Options) platform() (ocispec.Platform, error) {
+	if o.Platform != "" {
+		return parsePlatform(o.Platform)
+	}
+	return normalizePlatform(ocispec.Platform{OS: "linux", Architecture: o.arch()}), nil
+}
+
+func newHub(img image, opts Options) (*registry.Registry, error) {
+	user, password, err := getCredentials(img.Registry, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	hub, err := New(fmt.Sprintf("%s://%s", img.Scheme, img.Registry), user, password)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create registry client whit registry url: %s", img.Registry)
+	}
+	return hub, nil
 }
 
 func New(registryURL, user, password string) (*registry.Registry, error) {
@@ -65,12 +153,18 @@ func newFromTransport(registryURL, username, password string, transport http.Rou
 func checkImageUrl(imageUrl string) (image, error) {
 	img := image{}
 
-	if strings.Contains(imageUrl, "@") {
-		return img, errors.New("unsupported digest")
+	// the digest is after the tag, e.g. nginx:1.17@sha256:...
+	if i := strings.LastIndex(imageUrl, "@"); i >= 0 {
+		d, err := digest.Parse(imageUrl[i+1:])
+		if err != nil {
+			return img, errors.Wrapf(err, "invalid digest %q", imageUrl[i+1:])
+		}
+		img.Digest = d
+		imageUrl = imageUrl[:i]
 	}
 
 	if !strings.HasPrefix(imageUrl, "http://") && !strings.HasPrefix(imageUrl, "https://") {
-		imageUrl = fmt.Sprintf("https://%s", imageUrl)
+		imageUrl = fmt.Sprintf("https://%s", normalizeDockerHub(imageUrl))
 	}
 
 	registryUri, err := url.Parse(imageUrl)
@@ -81,16 +175,33 @@ func checkImageUrl(imageUrl string) (image, error) {
 	img.Registry = registryUri.Host
 	img.Scheme = registryUri.Scheme
 
-	if !strings.Contains(registryUri.Path, ":") {
-		return img, errors.New("can not find tag")
+	s := strings.SplitN(registryUri.Path, ":", 2)
+	img.Repository = strings.TrimPrefix(s[0], "/")
+	if len(s) == 2 {
+		img.Tag = s[1]
 	}
 
-	s := strings.SplitN(registryUri.Path, ":", 2)
-	if len(s) != 2 {
+	if img.Tag == "" && img.Digest == "" {
 		return img, errors.New("can not find tag")
 	}
+	return img, nil
+}
 
-	img.Repository = strings.TrimPrefix(s[0], "/")
-	img.Tag = s[1]
-	return img, err
+// normalizeDockerHub adds the docker hub registry and the library namespace to the image
+// if it has no registry, e.g. nginx:1.17 is registry-1.docker.io/library/nginx:1.17.
+func normalizeDockerHub(imageUrl string) string {
+	i := strings.Index(imageUrl, "/")
+	if i >= 0 {
+		switch first := imageUrl[:i]; {
+		case first == "docker.io" || first == "index.docker.io":
+			imageUrl = imageUrl[i+1:]
+		case strings.ContainsAny(first, ".:") || first == "localhost":
+			return imageUrl
+		}
+	}
+
+	if !strings.Contains(imageUrl, "/") {
+		imageUrl = "library/" + imageUrl
+	}
+	return "registry-1.docker.io/" + imageUrl
 }
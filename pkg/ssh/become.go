@@ -0,0 +1,120 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+// the methods which run the commands as root if the ssh user isn't root
+const (
+	BecomeSudo = "sudo"
+	BecomeSu   = "su"
+	BecomeDoas = "doas"
+)
+
+const (
+	// sudoPrompt is the password prompt of "sudo -p", it doesn't change with the locale of the remote host
+	sudoPrompt = "[kubei] password for become: "
+	// becomeMarker is printed by the script first, the output before it is from the become method, e.g. the password prompts
+	becomeMarker = "[kubei] become: done"
+	// uploadCmd writes the script from the stdin to a temp file which only the user can read, and prints the file
+	uploadCmd = `umask 077 && f=$(mktemp /tmp/.kubei-XXXXXXXXXX.sh) && cat > "$f" && echo "$f"`
+)
+
+var scriptFile = regexp.MustCompile(`^/tmp/\.kubei-[A-Za-z0-9]+\.sh$`)
+
+// Become is how the commands are run as root by a user who isn't root.
+type Become struct {
+	// Method is sudo, su or doas, su and doas read the password from a tty, so the commands are run with a pty
+	Method string
+	// Password is the password asked by the method, the password of the user for sudo and doas, of root for su
+	Password string
+}
+
+// script is the script file of the commands, the stdin is /dev/null so that the commands reading it don't hang.
+func script(cmd string) string {
+	return fmt.Sprintf("exec </dev/null\necho '%s' >&2\n%s\n", becomeMarker, cmd)
+}
+
+// becomeWriter answers the password prompt of the become method in the output before becomeMarker,
+// and writes the output after it to out. The password is sent once, a second prompt means that it is incorrect.
+type becomeWriter struct {
+	c     *Client
+	in    io.Writer
+	out   io.Writer
+	pre   io.Writer
+	abort func()
+
+	line     []byte
+	done     bool
+	answered bool
+	err      error
+}
+
+func (w *becomeWriter) Write(p []byte) (int, error) {
+	for i, b := range p {
+		if w.done {
+			if _, err := w.out.Write(p[i:]); err != nil {
+				return i, err
+			}
+			return len(p), nil
+		}
+		if b != '\n' {
+			w.line = append(w.line, b)
+			continue
+		}
+
+		line := strings.TrimSuffix(string(w.line), "\r")
+		w.line = w.line[:0]
+		if line == becomeMarker {
+			w.done = true
+			continue
+		}
+		if _, err := io.WriteString(w.pre, line+"\n"); err != nil {
+			return i, err
+		}
+	}
+
+	// a prompt is the last output before the method waits for the password
+	if !w.done && w.err == nil && w.isPrompt(string(w.line)) {
+		w.line = w.line[:0]
+		w.answer()
+	}
+	return len(p), nil
+}
+
+func (w *becomeWriter) isPrompt(line string) bool {
+	if strings.HasSuffix(line, sudoPrompt) {
+		return true
+	}
+	// the prompts of su and doas are localized, e.g. "Password: " or "Passwort: "
+	if w.c.become.Method == BecomeSudo || w.c.user == "root" {
+		return false
+	}
+	line = strings.TrimRight(line, " ")
+	return strings.HasSuffix(line, ":") || strings.HasSuffix(line, "：")
+}
+
+func (w *becomeWriter) answer() {
+	method := w.c.become.Method
+	switch {
+	case w.c.become.Password == "":
+		w.err = fmt.Errorf("%s asks for a password, but the become password is empty", method)
+	case w.answered:
+		w.err = fmt.Errorf("incorrect %s password", method)
+	default:
+		w.answered = true
+		if _, err := io.WriteString(w.in, w.c.become.Password+"\n"); err != nil {
+			w.err = err
+		} else {
+			klog.V(6).Infof("[%s] [%s] send the password to remote host", w.c.host, method)
+		}
+	}
+	if w.err != nil {
+		w.abort()
+	}
+}
@@ -0,0 +1,211 @@
+package ssh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"github.com/vbauerster/mpb/v6"
+	"github.com/vbauerster/mpb/v6/decor"
+	"golang.org/x/sync/singleflight"
+	"k8s.io/klog"
+)
+
+// partSuffix is the suffix of the file being uploaded, it is renamed to the dest file after the upload is finished.
+const partSuffix = ".part"
+
+// checksums caches the sha256 checksums of the local files, so that a file sent to many hosts is only read once,
+// the hosts sending the same file wait for the one reading it, and the different files are read in parallel.
+var checksums = struct {
+	sync.Mutex
+	m     map[string]string
+	group singleflight.Group
+}{m: map[string]string{}}
+
+// SendFile sends the local file to the remote host by sftp, and preserves the file mode.
+// The upload is skipped if the remote file has the same size and sha256 checksum,
+// and a partial upload left by a previous run is resumed if it is a prefix of the local file.
+// The progress is shown as a bar of p if p is not nil.
+func (c *Client) SendFile(dstFile, srcFile string, p *mpb.Progress) error {
+	f, err := os.Open(srcFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	sc, err := sftp.NewClient(c.client)
+	if err != nil {
+		return fmt.Errorf("unable to start sftp subsytem: %v", err)
+	}
+	defer sc.Close()
+
+	if err := sc.MkdirAll(path.Dir(dstFile)); err != nil {
+		return err
+	}
+
+	bar := newBar(p, c.host, size)
+	if err := c.sendFile(sc, f, dstFile, info, bar); err != nil {
+		if bar != nil {
+			bar.Abort(false)
+		}
+		return err
+	}
+	if bar != nil {
+		bar.SetTotal(size, true)
+	}
+	return nil
+}
+
+func (c *Client) sendFile(sc *sftp.Client, f *os.File, dstFile string, info os.FileInfo, bar *mpb.Bar) error {
+	size := info.Size()
+
+	same, err := c.isPrefix(sc, dstFile, f, size, true)
+	if err != nil {
+		return err
+	}
+	if same {
+		klog.V(3).Infof("[%s] [send] %s is up to date, skip the upload", c.host, dstFile)
+		return sc.Chmod(dstFile, info.Mode().Perm())
+	}
+
+	partFile := dstFile + partSuffix
+	var offset int64
+	if fi, err := sc.Stat(partFile); err == nil && fi.Size() > 0 && fi.Size() <= size {
+		resumable, err := c.isPrefix(sc, partFile, f, fi.Size(), false)
+		if err != nil {
+			return err
+		}
+		if resumable {
+			offset = fi.Size()
+			klog.V(3).Infof("[%s] [send] resume the upload of %s from %d bytes", c.host, dstFile, offset)
+		}
+	}
+
+	if err := upload(sc, f, partFile, offset, bar); err != nil {
+		return errors.Wrapf(err, "failed to upload %s", dstFile)
+	}
+
+	if err := sc.Chmod(partFile, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return sc.PosixRename(partFile, dstFile)
+}
+
+// isPrefix returns true if the first n bytes of the local file are the same as the remote file,
+// the remote file must be n bytes long if exact is true.
+func (c *Client) isPrefix(sc *sftp.Client, remoteFile string, f *os.File, n int64, exact bool) (bool, error) {
+	fi, err := sc.Stat(remoteFile)
+	if err != nil || fi.IsDir() {
+		return false, nil
+	}
+	if fi.Size() < n || (exact && fi.Size() != n) {
+		return false, nil
+	}
+
+	local, err := localSHA256(f, n)
+	if err != nil {
+		return false, err
+	}
+
+	out, err := c.RunOut(fmt.Sprintf("head -c %d %s | sha256sum", n, remoteFile))
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get the sha256 checksum of %s", remoteFile)
+	}
+	fields := strings.Fields(string(out))
+	return len(fields) > 0 && fields[0] == local, nil
+}
+
+func upload(sc *sftp.Client, f *os.File, dstFile string, offset int64, bar *mpb.Bar) error {
+	flags := os.O_WRONLY | os.O_CREATE
+	if offset == 0 {
+		flags |= os.O_TRUNC
+	}
+	w, err := sc.OpenFile(dstFile, flags)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if _, err := w.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	var r io.Reader = f
+	if bar != nil {
+		bar.SetCurrent(offset)
+		r = bar.ProxyReader(f)
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// localSHA256 returns the sha256 checksum of the first n bytes of the local file.
+func localSHA256(f *os.File, n int64) (string, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("%s:%d:%d:%d", f.Name(), info.Size(), info.ModTime().UnixNano(), n)
+
+	checksums.Lock()
+	sum, ok := checksums.m[key]
+	checksums.Unlock()
+	if ok {
+		return sum, nil
+	}
+
+	v, err, _ := checksums.group.Do(key, func() (interface{}, error) {
+		// the file is read by a section reader, which does not move the offset of f
+		h := sha256.New()
+		if _, err := io.Copy(h, io.NewSectionReader(f, 0, n)); err != nil {
+			return "", err
+		}
+		sum := hex.EncodeToString(h.Sum(nil))
+
+		checksums.Lock()
+		checksums.m[key] = sum
+		checksums.Unlock()
+		return sum, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return v.(string), nil
+}
+
+func newBar(p *mpb.Progress, name string, size int64) *mpb.Bar {
+	if p == nil {
+		return nil
+	}
+	return p.Add(
+		size,
+		mpb.NewBarFiller("[=>-|"),
+		mpb.PrependDecorators(
+			decor.Name(name, decor.WCSyncSpaceR),
+		),
+		mpb.AppendDecorators(
+			decor.Percentage(decor.WC{}),
+			decor.Name(" ] "),
+			decor.EwmaSpeed(decor.UnitKiB, "% .2f", 60),
+		),
+	)
+}
@@ -1,27 +1,28 @@
 package ssh
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
-	"github.com/pkg/errors"
-	"github.com/pkg/sftp"
-	"golang.org/x/crypto/ssh"
-	"golang.org/x/sync/errgroup"
 	"io"
 	"io/ioutil"
-	"k8s.io/klog"
 	"net"
-	"os"
-	"path"
 	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"k8s.io/klog"
 )
 
 type Client struct {
-	client   *ssh.Client
-	host     string
-	password string
-	user     string
+	client *ssh.Client
+	host   string
+	user   string
+	become Become
+
+	// mu guards tty, which is set if sudo requires a tty, e.g. "Defaults requiretty" in the sudoers
+	mu  sync.Mutex
+	tty bool
 }
 
 func Connect(host, port, user, password, key string) (*Client, error) {
@@ -34,7 +35,7 @@ func Connect(host, port, user, password, key string) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Client{client: client, host: host, password: password, user: user}, nil
+	return newClient(client, host, user, password), nil
 }
 
 func ConnectByJumpServer(host, port, user, password, key string, jumpServer *Client) (*Client, error) {
@@ -52,7 +53,24 @@ func ConnectByJumpServer(host, port, user, password, key string, jumpServer *Cli
 		return nil, err
 	}
 
-	return &Client{client: ssh.NewClient(ncc, chans, reqs), host: host, password: password, user: user}, nil
+	return newClient(ssh.NewClient(ncc, chans, reqs), host, user, password), nil
+}
+
+// newClient returns the client which runs the commands by sudo with the login password if the user isn't root.
+func newClient(client *ssh.Client, host, user, password string) *Client {
+	return &Client{client: client, host: host, user: user, become: Become{Method: BecomeSudo, Password: password}}
+}
+
+// SetBecome sets how the commands are run as root if the user isn't root,
+// the login password is still used if the password of b is empty.
+func (c *Client) SetBecome(b Become) {
+	if b.Method == "" {
+		b.Method = BecomeSudo
+	}
+	if b.Password == "" {
+		b.Password = c.become.Password
+	}
+	c.become = b
 }
 
 func setConf(user, password, key string) (*ssh.ClientConfig, error) {
@@ -93,190 +111,178 @@ func (c *Client) Close() error {
 }
 
 func (c *Client) Run(cmd string) error {
-	//host, _, _ := net.SplitHostPort(c.client.RemoteAddr().String())
-	cmd = c.cmdPrefix(cmd)
+	return c.run(cmd, ioutil.Discard)
+}
 
+func (c *Client) RunOut(cmd string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.run(cmd, &buf); err != nil {
+		return []byte{}, err
+	}
+	return buf.Bytes(), nil
+}
+
+// run runs the commands as root and writes the stdout to stdout. If sudo refuses to run without a tty,
+// the commands are run again with a pty, and so are the later ones.
+func (c *Client) run(cmd string, stdout io.Writer) error {
 	klog.V(6).Infof("[%s] [commands] Execute commands: \n%s", c.host, cmd)
 
-	session, err := c.client.NewSession()
-	if err != nil {
-		return err
+	err := c.runScript(cmd, stdout)
+	if err != nil && c.user != "root" && c.become.Method == BecomeSudo && !c.needTTY() &&
+		strings.Contains(err.Error(), "must have a tty") {
+		klog.V(3).Infof("[%s] [sudo] sudo requires a tty, run the commands with a pty", c.host)
+		c.mu.Lock()
+		c.tty = true
+		c.mu.Unlock()
+		return c.runScript(cmd, stdout)
 	}
-	defer session.Close()
+	return err
+}
 
-	stdout, err := session.StdoutPipe()
+// runScript uploads the commands as a script file and runs it, so the commands are not escaped by any shell.
+func (c *Client) runScript(cmd string, stdout io.Writer) error {
+	file, err := c.upload(script(cmd))
 	if err != nil {
 		return err
 	}
-	stderr, err := session.StderrPipe()
+
+	session, err := c.client.NewSession()
 	if err != nil {
 		return err
 	}
+	defer session.Close()
+
+	tty := c.needTTY()
+	if tty {
+		// the password isn't echoed, and the newlines of the output aren't converted to \r\n
+		modes := ssh.TerminalModes{ssh.ECHO: 0, ssh.ONLCR: 0}
+		if err := session.RequestPty("xterm", 40, 200, modes); err != nil {
+			return errors.Wrap(err, "failed to request a pty")
+		}
+	}
 	in, err := session.StdinPipe()
 	if err != nil {
 		return err
 	}
 
 	var buferr bytes.Buffer
-	teeStderr := io.TeeReader(stderr, &buferr)
-
-	if err := session.Start(cmd); err != nil {
-		return errors.Wrap(err, buferr.String())
+	stdoutLog := &logWriter{host: c.host, name: "remote-stdout", level: 8}
+	stderrLog := &logWriter{host: c.host, name: "remote-stderr", level: 7}
+	out := io.MultiWriter(stdout, stdoutLog)
+	errOut := io.MultiWriter(&buferr, stderrLog)
+
+	// the prompts of the become method are on the stderr, or on the stdout with a pty which merges them
+	w := &becomeWriter{c: c, in: in, pre: errOut, abort: func() { session.Close() }}
+	if tty {
+		w.out = out
+		session.Stdout, session.Stderr = w, errOut
+	} else {
+		w.out = errOut
+		session.Stdout, session.Stderr = out, w
 	}
 
-	g := errgroup.Group{}
-	g.Go(func() error {
-		return sendSudoPassword(c.password, c.host, in, teeStderr)
-	})
-
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		str := scanner.Text()
-		if str != "" {
-			klog.V(8).Infof("[%s] [remote-stdout] %s", c.host, str)
+	err = session.Run(c.command(file, tty))
+	stdoutLog.flush()
+	stderrLog.flush()
+	if w.err != nil {
+		// the script is not removed by the command which is aborted
+		if err := c.remove(file); err != nil {
+			klog.V(3).Infof("[%s] [commands] failed to remove %s: %v", c.host, file, err)
 		}
+		return errors.Wrap(w.err, buferr.String())
 	}
-
-	if err := g.Wait(); err != nil {
-		return errors.Wrap(err, buferr.String())
-	}
-
-	if err := session.Wait(); err != nil {
+	if err != nil {
 		return errors.Wrap(err, buferr.String())
 	}
-
 	return nil
 }
 
-func (c *Client) RunOut(cmd string) ([]byte, error) {
-	//host, _, _ := net.SplitHostPort(c.client.RemoteAddr().String())
-	cmd = c.cmdPrefix(cmd)
-
-	klog.V(6).Infof("[%s] [commands] Execute commands: \n%s", c.host, cmd)
-
+// upload writes the script to a temp file of the user on the remote host, and returns the file.
+func (c *Client) upload(script string) (string, error) {
 	session, err := c.client.NewSession()
 	if err != nil {
-		return []byte{}, err
+		return "", err
 	}
 	defer session.Close()
 
-	stdout, err := session.StdoutPipe()
-	if err != nil {
-		return []byte{}, err
-	}
-	stderr, err := session.StderrPipe()
-	if err != nil {
-		return []byte{}, err
-	}
-	in, err := session.StdinPipe()
-	if err != nil {
-		return []byte{}, err
+	var stdout, stderr bytes.Buffer
+	session.Stdin = strings.NewReader(script)
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	if err := session.Run(uploadCmd); err != nil {
+		return "", errors.Wrapf(err, "failed to upload the script: %s", stderr.String())
 	}
 
-	var buf bytes.Buffer
-	var buferr bytes.Buffer
-	teeStderr := io.TeeReader(stderr, &buferr)
-
-	if err := session.Start(cmd); err != nil {
-		return []byte{}, errors.Wrap(err, buferr.String())
-	}
-
-	g := errgroup.Group{}
-	g.Go(func() error {
-		return sendSudoPassword(c.password, c.host, in, teeStderr)
-	})
-
-	teeStdout := io.TeeReader(stdout, &buf)
-	scanner := bufio.NewScanner(teeStdout)
-
-	for scanner.Scan() {
-		str := scanner.Text()
-		if str != "" {
-			klog.V(8).Infof("[%s] [remote-stdout] %s", c.host, str)
-		}
+	file := strings.TrimSpace(stdout.String())
+	if !scriptFile.MatchString(file) {
+		return "", errors.Errorf("failed to upload the script: unexpected temp file %q", file)
 	}
-
-	if err := g.Wait(); err != nil {
-		return []byte{}, errors.Wrap(err, buferr.String())
-	}
-
-	if err := session.Wait(); err != nil {
-		return []byte{}, errors.Wrap(err, buferr.String())
-	}
-
-	return buf.Bytes(), nil
+	return file, nil
 }
 
-func (c *Client) SendFile(dstFile, srcFile string) error {
-	sc, err := sftp.NewClient(c.client)
-	if err != nil {
-		return fmt.Errorf("unable to start sftp subsytem: %v", err)
-	}
-
-	err = sc.MkdirAll(path.Dir(dstFile))
+func (c *Client) remove(file string) error {
+	session, err := c.client.NewSession()
 	if err != nil {
 		return err
 	}
+	defer session.Close()
+	return session.Run("rm -f " + file)
+}
 
-	w, err := sc.Create(dstFile)
-	if err != nil {
-		return err
+func (c *Client) needTTY() bool {
+	if c.user == "root" {
+		return false
 	}
-	defer w.Close()
-
-	f, err := os.Open(srcFile)
-	if err != nil {
-		return err
+	if c.become.Method != BecomeSudo {
+		return true
 	}
-	defer f.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tty
+}
 
-	_, err = io.Copy(w, f)
-	if err != nil {
-		return err
+// command runs the script file as root, and removes it.
+func (c *Client) command(file string, tty bool) string {
+	run := "bash " + file
+	if c.user != "root" {
+		switch c.become.Method {
+		case BecomeSu:
+			run = fmt.Sprintf("su root -c 'bash %s'", file)
+		case BecomeDoas:
+			run = "doas bash " + file
+		default:
+			if tty {
+				run = fmt.Sprintf("sudo -p '%s' bash %s", sudoPrompt, file)
+			} else {
+				run = fmt.Sprintf("sudo -S -p '%s' bash %s", sudoPrompt, file)
+			}
+		}
 	}
-
-	return nil
+	return fmt.Sprintf("%s; rc=$?; rm -f %s; exit $rc", run, file)
 }
 
-func sendSudoPassword(password, host string, in io.WriteCloser, out io.Reader) error {
-	var line string
+// logWriter logs the output line by line
+type logWriter struct {
+	host  string
+	name  string
+	level klog.Level
+	line  []byte
+}
 
-	r := bufio.NewReader(out)
-	for {
-		b, err := r.ReadByte()
-		if err != nil {
-			break
-		}
-		if b == byte('\n') {
-			if line != "" {
-				klog.V(7).Infof("[%s] [remote-stderr] %s", host, line)
-			}
-			line = ""
+func (l *logWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b == '\n' {
+			l.flush()
 			continue
 		}
-
-		line += string(b)
-		if line != "" && strings.HasPrefix(line, "[sudo] password for ") && strings.HasSuffix(line, ": ") {
-			_, err = in.Write([]byte(password + "\n"))
-			if err != nil {
-				return err
-			}
-			line = ""
-			klog.V(6).Infof("[%s] [sudo] send the sudo password to remote host", host)
-		}
+		l.line = append(l.line, b)
 	}
-	return nil
+	return len(p), nil
 }
 
-func (c *Client) cmdPrefix(cmd string) string {
-	r := strings.NewReplacer("$", "\\$", "\"", "\\\"")
-	cmd = r.Replace(cmd)
-
-	if c.user == "root" {
-		cmd = fmt.Sprintf("bash -c \"set -e\n%s\"", cmd)
-	} else {
-		cmd = fmt.Sprintf("sudo -S bash -c \"set -e\n%s\"", cmd)
+func (l *logWriter) flush() {
+	if len(l.line) > 0 {
+		klog.V(l.level).Infof("[%s] [%s] %s", l.host, l.name, l.line)
 	}
-
-	return cmd
+	l.line = l.line[:0]
 }
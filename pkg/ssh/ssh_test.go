@@ -0,0 +1,274 @@
+package ssh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yuyicai/kubei/pkg/ssh/sshtest"
+)
+
+func TestRunOut(t *testing.T) {
+	s := sshtest.NewServer(t, "root", "secret")
+	s.Reply(`^echo \$HOME$`, "/root\n", 0)
+
+	c, err := Connect(s.Host(), s.Port(), "root", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	out, err := c.RunOut("echo $HOME")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "/root\n" {
+		t.Errorf("RunOut() = %q, want %q", out, "/root\n")
+	}
+
+	cmds := s.Commands()
+	if len(cmds) != 1 || cmds[0].Become != "" || cmds[0].TTY {
+		t.Errorf("commands = %+v, want one command without become", cmds)
+	}
+	if files := s.FS.Files(); len(files) != 0 {
+		t.Errorf("files = %q, want the script removed", files)
+	}
+}
+
+func TestRunScriptNotEscaped(t *testing.T) {
+	s := sshtest.NewServer(t, "ubuntu", "secret")
+
+	c, err := Connect(s.Host(), s.Port(), "ubuntu", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	cmd := "sed -i 's/^\\(.*\\)$/# \\1/' \"$HOME/.bashrc\"\necho `hostname` \\\n  $(id -u)"
+	if err := c.Run(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if scripts := s.Scripts(); len(scripts) != 1 || scripts[0] != cmd {
+		t.Errorf("scripts = %q, want %q", scripts, cmd)
+	}
+}
+
+func TestRunError(t *testing.T) {
+	s := sshtest.NewServer(t, "root", "secret")
+	s.Handle(`^systemctl start kubelet$`, func(e *sshtest.Exec) int {
+		io.WriteString(e.Stderr, "Unit kubelet.service not found.\n")
+		return 5
+	})
+
+	c, err := Connect(s.Host(), s.Port(), "root", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	err = c.Run("systemctl start kubelet")
+	if err == nil || !strings.Contains(err.Error(), "Unit kubelet.service not found.") {
+		t.Errorf("Run() = %v, want the error with the stderr", err)
+	}
+}
+
+func TestRunSudo(t *testing.T) {
+	s := sshtest.NewServer(t, "ubuntu", "secret")
+	s.Reply(`^id -u$`, "0\n", 0)
+
+	c, err := Connect(s.Host(), s.Port(), "ubuntu", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	out, err := c.RunOut("id -u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "0\n" {
+		t.Errorf("RunOut() = %q, want %q", out, "0\n")
+	}
+	if cmds := s.Commands(); len(cmds) != 1 || cmds[0].Become != BecomeSudo || cmds[0].TTY {
+		t.Errorf("commands = %+v, want one command with sudo", cmds)
+	}
+
+	// the login password is sent to sudo, which is different from the become password of the server
+	s.BecomePassword = "another"
+	if err := c.Run("id -u"); err == nil || !strings.Contains(err.Error(), "incorrect sudo password") {
+		t.Errorf("Run() with a wrong sudo password = %v, want the error of sudo", err)
+	}
+
+	c.SetBecome(Become{Method: BecomeSudo, Password: "another"})
+	if err := c.Run("id -u"); err != nil {
+		t.Errorf("Run() with the become password = %v, want nil", err)
+	}
+}
+
+func TestRunSudoRequireTTY(t *testing.T) {
+	s := sshtest.NewServer(t, "centos", "secret")
+	s.RequireTTY = true
+	s.Reply(`^id -u$`, "0\n", 0)
+
+	c, err := Connect(s.Host(), s.Port(), "centos", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 2; i++ {
+		out, err := c.RunOut("id -u")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != "0\n" {
+			t.Errorf("RunOut() = %q, want %q", out, "0\n")
+		}
+	}
+
+	// sudo is run with a pty after it refuses to run without one
+	var ttys []bool
+	for _, e := range s.Commands() {
+		ttys = append(ttys, e.TTY)
+	}
+	if want := []bool{false, true, true}; !reflect.DeepEqual(ttys, want) {
+		t.Errorf("tty of the commands = %v, want %v", ttys, want)
+	}
+}
+
+func TestRunSuDoas(t *testing.T) {
+	for _, method := range []string{BecomeSu, BecomeDoas} {
+		t.Run(method, func(t *testing.T) {
+			s := sshtest.NewServer(t, "alice", "secret")
+			s.BecomePassword = "rootpw"
+			s.Prompt = "Passwort: "
+			s.Handle(`^id -u$`, func(e *sshtest.Exec) int {
+				io.WriteString(e.Stderr, "a warning\n")
+				io.WriteString(e.Stdout, "0\n")
+				return 0
+			})
+
+			c, err := Connect(s.Host(), s.Port(), "alice", "secret", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			c.SetBecome(Become{Method: method, Password: "rootpw"})
+			out, err := c.RunOut("id -u")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the stderr is merged into the stdout by the pty
+			if string(out) != "a warning\n0\n" {
+				t.Errorf("RunOut() = %q, want %q", out, "a warning\n0\n")
+			}
+			if cmds := s.Commands(); len(cmds) != 1 || cmds[0].Become != method || !cmds[0].TTY {
+				t.Errorf("commands = %+v, want one command by %s with a pty", cmds, method)
+			}
+
+			c.SetBecome(Become{Method: method, Password: "wrong"})
+			if err := c.Run("id -u"); err == nil || !strings.Contains(err.Error(), "Authentication failure") {
+				t.Errorf("Run() with a wrong password = %v, want the error of %s", err, method)
+			}
+		})
+	}
+}
+
+func TestConnectByKey(t *testing.T) {
+	s := sshtest.NewServer(t, "root", "secret")
+	key := s.ClientKey(t)
+
+	c, err := Connect(s.Host(), s.Port(), "root", "", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	if _, err := Connect(s.Host(), s.Port(), "root", "wrong", ""); err == nil {
+		t.Error("Connect() with a wrong password, want error")
+	}
+}
+
+func TestSendFile(t *testing.T) {
+	s := sshtest.NewServer(t, "root", "secret")
+
+	c, err := Connect(s.Host(), s.Port(), "root", "secret", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	data := []byte(strings.Repeat("kubei", 10000))
+	src := filepath.Join(t.TempDir(), "kube.tar.gz")
+	if err := ioutil.WriteFile(src, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// a partial upload of a previous run is resumed
+	dst := "/tmp/.kubei/kube.tar.gz"
+	s.FS.WriteFile(dst+partSuffix, data[:1000], 0644)
+
+	if err := c.SendFile(dst, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.FS.ReadFile(dst); string(got) != string(data) {
+		t.Errorf("the sent file has %d bytes, want %d bytes", len(got), len(data))
+	}
+	if mode, _ := s.FS.Mode(dst); mode != 0600 {
+		t.Errorf("the mode of the sent file = %v, want %v", mode, os.FileMode(0600))
+	}
+	if _, ok := s.FS.ReadFile(dst + partSuffix); ok {
+		t.Errorf("%s is left on the host", dst+partSuffix)
+	}
+
+	// the upload of the same file is skipped
+	n := len(s.Commands())
+	if err := c.SendFile(dst, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	if scripts := s.Scripts()[n:]; len(scripts) != 1 || !strings.HasPrefix(scripts[0], "head -c") {
+		t.Errorf("scripts = %q, want the checksum of %s only", scripts, dst)
+	}
+}
+
+func TestLocalSHA256(t *testing.T) {
+	dir := t.TempDir()
+	want := map[*os.File]string{}
+	for _, data := range []string{"kubernetes offline pkg", "kubernetes images"} {
+		file := filepath.Join(dir, data)
+		if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		sum := sha256.Sum256([]byte(data[:10]))
+		want[f] = hex.EncodeToString(sum[:])
+	}
+
+	// the hosts sending the files at the same time get the checksums of their own files
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		for f, sum := range want {
+			f, sum := f, sum
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if got, err := localSHA256(f, 10); err != nil || got != sum {
+					t.Errorf("localSHA256(%s) = %s, %v, want %s", f.Name(), got, err, sum)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
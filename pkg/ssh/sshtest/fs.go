@@ -0,0 +1,278 @@
+package sshtest
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// FS is the in-memory filesystem of a Server, it is shared by the sftp subsystem and the command handlers.
+// The directories are created implicitly by the files in them.
+type FS struct {
+	mu    sync.Mutex
+	files map[string]*file
+	dirs  map[string]bool
+}
+
+type file struct {
+	data []byte
+	mode os.FileMode
+}
+
+func newFS() *FS {
+	return &FS{files: map[string]*file{}, dirs: map[string]bool{"/": true}}
+}
+
+// WriteFile writes the file, the parent directories are created.
+func (fs *FS) WriteFile(name string, data []byte, mode os.FileMode) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	name = path.Clean(name)
+	fs.mkdirAll(path.Dir(name))
+	fs.files[name] = &file{data: append([]byte{}, data...), mode: mode.Perm()}
+}
+
+// ReadFile returns the content of the file, false if it doesn't exist.
+func (fs *FS) ReadFile(name string) ([]byte, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	f, ok := fs.files[path.Clean(name)]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte{}, f.data...), true
+}
+
+// Mode returns the permission bits of the file, false if it doesn't exist.
+func (fs *FS) Mode(name string) (os.FileMode, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	f, ok := fs.files[path.Clean(name)]
+	if !ok {
+		return 0, false
+	}
+	return f.mode, true
+}
+
+// Remove removes the file, it is not an error if the file doesn't exist.
+func (fs *FS) Remove(name string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	delete(fs.files, path.Clean(name))
+}
+
+// Files returns the names of all files in order.
+func (fs *FS) Files() []string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	var names []string
+	for name := range fs.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (fs *FS) mkdirAll(dir string) {
+	for ; dir != "/" && dir != "."; dir = path.Dir(dir) {
+		fs.dirs[dir] = true
+	}
+}
+
+func (fs *FS) stat(name string) (os.FileInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	name = path.Clean(name)
+	if f, ok := fs.files[name]; ok {
+		return fileInfo{name: path.Base(name), size: int64(len(f.data)), mode: f.mode}, nil
+	}
+	if fs.dirs[name] {
+		return fileInfo{name: path.Base(name), mode: os.ModeDir | 0755}, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func (fs *FS) list(dir string) []os.FileInfo {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	dir = path.Clean(dir)
+	var infos []os.FileInfo
+	for name, f := range fs.files {
+		if path.Dir(name) == dir {
+			infos = append(infos, fileInfo{name: path.Base(name), size: int64(len(f.data)), mode: f.mode})
+		}
+	}
+	for name := range fs.dirs {
+		if name != dir && path.Dir(name) == dir {
+			infos = append(infos, fileInfo{name: path.Base(name), mode: os.ModeDir | 0755})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos
+}
+
+// writeAt writes p to the file at the offset, the file is created if it doesn't exist.
+func (fs *FS) writeAt(name string, p []byte, off int64) (int, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	f, ok := fs.files[name]
+	if !ok {
+		return 0, os.ErrNotExist
+	}
+	if end := off + int64(len(p)); end > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, end-int64(len(f.data)))...)
+	}
+	return copy(f.data[off:], p), nil
+}
+
+type fileInfo struct {
+	name string
+	size int64
+	mode os.FileMode
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi fileInfo) Sys() interface{}   { return nil }
+
+// handlers serves FS by the sftp request server
+type handlers struct {
+	fs *FS
+}
+
+func (h handlers) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	data, ok := h.fs.ReadFile(r.Filepath)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return bytes.NewReader(data), nil
+}
+
+func (h handlers) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	name := path.Clean(r.Filepath)
+	flags := r.Pflags()
+
+	h.fs.mu.Lock()
+	defer h.fs.mu.Unlock()
+	if !h.fs.dirs[path.Dir(name)] {
+		return nil, os.ErrNotExist
+	}
+	f, ok := h.fs.files[name]
+	switch {
+	case !ok:
+		h.fs.files[name] = &file{mode: 0644}
+	case flags.Trunc:
+		f.data = nil
+	}
+	return writerAt{fs: h.fs, name: name}, nil
+}
+
+func (h handlers) Filecmd(r *sftp.Request) error {
+	name := path.Clean(r.Filepath)
+	switch r.Method {
+	case "Setstat":
+		if _, err := h.fs.stat(name); err != nil {
+			return err
+		}
+		if r.AttrFlags().Permissions {
+			h.fs.mu.Lock()
+			if f, ok := h.fs.files[name]; ok {
+				f.mode = r.Attributes().FileMode().Perm()
+			}
+			h.fs.mu.Unlock()
+		}
+		return nil
+	case "Rename", "PosixRename":
+		h.fs.mu.Lock()
+		defer h.fs.mu.Unlock()
+		f, ok := h.fs.files[name]
+		if !ok {
+			return os.ErrNotExist
+		}
+		delete(h.fs.files, name)
+		h.fs.files[path.Clean(r.Target)] = f
+		return nil
+	case "Mkdir":
+		h.fs.mu.Lock()
+		defer h.fs.mu.Unlock()
+		if !h.fs.dirs[path.Dir(name)] {
+			return os.ErrNotExist
+		}
+		h.fs.dirs[name] = true
+		return nil
+	case "Remove":
+		if _, err := h.fs.stat(name); err != nil {
+			return err
+		}
+		h.fs.Remove(name)
+		return nil
+	case "Rmdir":
+		h.fs.mu.Lock()
+		defer h.fs.mu.Unlock()
+		for f := range h.fs.files {
+			if strings.HasPrefix(f, name+"/") {
+				return os.ErrExist
+			}
+		}
+		delete(h.fs.dirs, name)
+		return nil
+	}
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+func (h handlers) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	switch r.Method {
+	case "Stat", "Lstat":
+		fi, err := h.fs.stat(r.Filepath)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{fi}, nil
+	case "List":
+		if _, err := h.fs.stat(r.Filepath); err != nil {
+			return nil, err
+		}
+		return listerAt(h.fs.list(r.Filepath)), nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+type writerAt struct {
+	fs   *FS
+	name string
+}
+
+func (w writerAt) WriteAt(p []byte, off int64) (int, error) {
+	return w.fs.writeAt(w.name, p, off)
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(infos []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(infos, l[offset:])
+	if n < len(infos) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,491 @@
+// Package sshtest provides an in-process ssh server for the tests, the same as net/http/httptest for http.
+// The commands are handled by the scripted handlers, and the files are in an in-memory filesystem served by sftp,
+// so that the code running commands on the nodes can be tested end-to-end without real hosts.
+package sshtest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Exec is a command run on the Server.
+type Exec struct {
+	// Command is the command received by the server, e.g. sudo -S -p '...' bash /tmp/.kubei-0000000001.sh; ...
+	Command string
+	// Script is the commands in the script file run by the ssh client, without the header of the client
+	Script string
+	// Become is the method which runs the script as root, sudo, su or doas, it is empty if the user is root
+	Become string
+	// TTY is true if the command is run with a pty, the stderr is merged into the stdout
+	TTY  bool
+	User string
+	FS   *FS
+
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Handler handles a command and returns its exit status.
+type Handler func(e *Exec) int
+
+type route struct {
+	pattern *regexp.Regexp
+	handler Handler
+}
+
+// Server is an in-process ssh server listening on 127.0.0.1.
+type Server struct {
+	// FS is the filesystem of the host, it is shared by sftp and the handlers
+	FS *FS
+	// User and Password are the login of the host, the key of ClientKey can be used instead of the password
+	User     string
+	Password string
+	// BecomePassword is the password asked by sudo, su and doas, they don't ask for it if it is empty
+	BecomePassword string
+	// Prompt is the password prompt of su and doas, e.g. a localized one, sudo uses the prompt of "sudo -p"
+	Prompt string
+	// RequireTTY makes sudo refuse to run without a tty, the same as "Defaults requiretty" in the sudoers
+	RequireTTY bool
+	// Strict makes the commands without a handler fail with 127, they succeed without output by default
+	Strict bool
+
+	listener  net.Listener
+	config    *ssh.ServerConfig
+	clientKey ssh.PublicKey
+
+	mu       sync.Mutex
+	routes   []route
+	commands []Exec
+	uploads  int
+	conns    map[net.Conn]bool
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server with the login user and password, sudo, su and doas ask for the same password
+// if the user isn't root. The server is closed when the test finishes.
+func NewServer(t testing.TB, user, password string) *Server {
+	t.Helper()
+
+	s := &Server{FS: newFS(), User: user, Password: password, Prompt: "Password: ", conns: map[net.Conn]bool{}}
+	if user != "root" {
+		s.BecomePassword = password
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.config = &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == s.User && string(pass) == s.Password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %q", c.User())
+		},
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			s.mu.Lock()
+			clientKey := s.clientKey
+			s.mu.Unlock()
+			if c.User() == s.User && clientKey != nil && bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("public key rejected for %q", c.User())
+		},
+	}
+	s.config.AddHostKey(signer)
+
+	s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Host returns the host of the server, 127.0.0.1.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host
+}
+
+// Port returns the port of the server.
+func (s *Server) Port() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return port
+}
+
+// Close stops the server, the connections of the clients are closed.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// ClientKey writes a private key to the temp dir of the test and returns its path, the server accepts the key of User.
+func (s *Server) ClientKey(t testing.TB) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "id_rsa")
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clientKey = pub
+	return file
+}
+
+// Handle handles the scripts matching the regexp pattern, the first matching handler is used.
+func (s *Server) Handle(pattern string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = append(s.routes, route{pattern: regexp.MustCompile(pattern), handler: h})
+}
+
+// Reply replies the scripts matching the regexp pattern with the stdout and the exit status.
+func (s *Server) Reply(pattern, stdout string, status int) {
+	s.Handle(pattern, func(e *Exec) int {
+		io.WriteString(e.Stdout, stdout)
+		return status
+	})
+}
+
+// Commands returns the commands run on the server in order.
+func (s *Server) Commands() []Exec {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Exec{}, s.commands...)
+}
+
+// Scripts returns the scripts of the commands run on the server in order.
+func (s *Server) Scripts() []string {
+	var scripts []string
+	for _, e := range s.Commands() {
+		scripts = append(scripts, e.Script)
+	}
+	return scripts
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	sc, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer sc.Close()
+	go ssh.DiscardRequests(reqs)
+
+	var wg sync.WaitGroup
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only session channels are supported")
+			continue
+		}
+		ch, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handleSession(sc.User(), ch, requests)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Server) handleSession(user string, ch ssh.Channel, requests <-chan *ssh.Request) {
+	defer ch.Close()
+
+	tty := false
+	for req := range requests {
+		switch req.Type {
+		case "pty-req":
+			tty = true
+			req.Reply(true, nil)
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+
+			status := s.exec(user, payload.Command, ch, tty)
+			ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+			return
+		case "subsystem":
+			var payload struct{ Name string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil || payload.Name != "sftp" {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+
+			server := sftp.NewRequestServer(ch, sftp.Handlers{
+				FileGet:  handlers{s.FS},
+				FilePut:  handlers{s.FS},
+				FileCmd:  handlers{s.FS},
+				FileList: handlers{s.FS},
+			})
+			server.Serve()
+			server.Close()
+			return
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+}
+
+// exec runs the command. The script files uploaded by the ssh client are run by the handlers,
+// as root by sudo, su or doas, which ask for the password the same as the real ones.
+func (s *Server) exec(user, command string, ch ssh.Channel, tty bool) int {
+	if m := uploadCmd.FindStringSubmatch(command); m != nil {
+		return s.upload(m[1], ch)
+	}
+
+	e := Exec{Command: command, Script: command, User: user, TTY: tty, FS: s.FS, Stdout: ch, Stderr: ch.Stderr()}
+	if tty {
+		e.Stderr = ch
+	}
+
+	var marker, prompt string
+	if m := runCmd.FindStringSubmatch(command); m != nil {
+		file := m[4]
+		defer s.FS.Remove(file)
+
+		data, _ := s.FS.ReadFile(file)
+		e.Script = string(data)
+		if h := scriptHeader.FindStringSubmatch(e.Script); h != nil {
+			marker, e.Script = h[1], h[2]
+		}
+		switch {
+		case strings.HasPrefix(command, "sudo "):
+			e.Become, prompt = "sudo", m[1]
+		case m[2] != "":
+			e.Become = "doas"
+		case m[3] != "":
+			e.Become = "su"
+		}
+	}
+
+	s.mu.Lock()
+	s.commands = append(s.commands, Exec{Command: e.Command, Script: e.Script, Become: e.Become, TTY: e.TTY, User: e.User})
+	routes := append([]route{}, s.routes...)
+	s.mu.Unlock()
+
+	if e.Become != "" {
+		if status := s.become(&e, prompt, ch); status != 0 {
+			return status
+		}
+	}
+	if marker != "" {
+		fmt.Fprintln(e.Stderr, marker)
+	}
+
+	for _, r := range routes {
+		if r.pattern.MatchString(e.Script) {
+			return r.handler(&e)
+		}
+	}
+	if status, ok := builtin(&e); ok {
+		return status
+	}
+	if s.Strict {
+		fmt.Fprintf(e.Stderr, "bash: %s: command not found\n", e.Script)
+		return 127
+	}
+	return 0
+}
+
+// upload writes the stdin to a new file of the mktemp template, and prints the file.
+func (s *Server) upload(template string, ch ssh.Channel) int {
+	data, err := ioutil.ReadAll(ch)
+	if err != nil {
+		fmt.Fprintf(ch.Stderr(), "cat: -: %v\n", err)
+		return 1
+	}
+
+	s.mu.Lock()
+	s.uploads++
+	suffix := fmt.Sprintf("%0*d", strings.Count(template, "X"), s.uploads)
+	s.mu.Unlock()
+
+	file := strings.Replace(template, strings.Repeat("X", len(suffix)), suffix, 1)
+	s.FS.WriteFile(file, data, 0600)
+	fmt.Fprintln(ch, file)
+	return 0
+}
+
+// become asks for the password on the tty, or on the stderr for "sudo -S" without a tty.
+func (s *Server) become(e *Exec, prompt string, ch ssh.Channel) int {
+	switch e.Become {
+	case "sudo":
+		if s.RequireTTY && !e.TTY {
+			fmt.Fprintln(e.Stderr, "sudo: sorry, you must have a tty to run sudo")
+			return 1
+		}
+	default:
+		if !e.TTY {
+			fmt.Fprintf(e.Stderr, "%s: must be run from a terminal\n", e.Become)
+			return 1
+		}
+		prompt = s.Prompt
+	}
+	if s.BecomePassword == "" {
+		return 0
+	}
+
+	r := bufio.NewReader(ch)
+	for i := 0; i < 3; i++ {
+		fmt.Fprint(e.Stderr, prompt)
+		password, err := r.ReadString('\n')
+		if err != nil {
+			fmt.Fprintf(e.Stderr, "\n%s: no password was provided\n", e.Become)
+			return 1
+		}
+		if strings.TrimSuffix(password, "\n") == s.BecomePassword {
+			return 0
+		}
+		if e.Become != "sudo" {
+			fmt.Fprintf(e.Stderr, "%s: Authentication failure\n", e.Become)
+			return 1
+		}
+		fmt.Fprintln(e.Stderr, "Sorry, try again.")
+	}
+	fmt.Fprintln(e.Stderr, "sudo: 3 incorrect password attempts")
+	return 1
+}
+
+var (
+	uploadCmd    = regexp.MustCompile(`mktemp (/tmp/\.kubei-X+\.sh)\) && cat > "\$f"`)
+	runCmd       = regexp.MustCompile(`^(?:sudo (?:-S )?-p '([^']*)' |(doas) |(su) root -c ')?bash (/tmp/\.kubei-[A-Za-z0-9]+\.sh)'?; rc=\$\?; rm -f \S+; exit \$rc$`)
+	scriptHeader = regexp.MustCompile(`(?s)^exec </dev/null\necho '([^']*)' >&2\n(.*)\n$`)
+)
+
+var (
+	catCmd       = regexp.MustCompile(`^cat (\S+)( 2>/dev/null \|\| true)?$`)
+	sha256sumCmd = regexp.MustCompile(`^sha256sum (\S+)$`)
+	headSHA256   = regexp.MustCompile(`^head -c (\d+) (\S+) \| sha256sum$`)
+	rmCmd        = regexp.MustCompile(`^rm -f (\S+)$`)
+)
+
+// builtin runs the commands on FS which are used by kubei to read and check the files.
+func builtin(e *Exec) (int, bool) {
+	script := strings.TrimSpace(e.Script)
+
+	if m := catCmd.FindStringSubmatch(script); m != nil {
+		data, ok := e.FS.ReadFile(m[1])
+		if !ok {
+			if m[2] != "" {
+				return 0, true
+			}
+			fmt.Fprintf(e.Stderr, "cat: %s: No such file or directory\n", m[1])
+			return 1, true
+		}
+		e.Stdout.Write(data)
+		return 0, true
+	}
+
+	if m := sha256sumCmd.FindStringSubmatch(script); m != nil {
+		data, ok := e.FS.ReadFile(m[1])
+		if !ok {
+			fmt.Fprintf(e.Stderr, "sha256sum: %s: No such file or directory\n", m[1])
+			return 1, true
+		}
+		fmt.Fprintf(e.Stdout, "%s  %s\n", sha256Hex(data), m[1])
+		return 0, true
+	}
+
+	if m := headSHA256.FindStringSubmatch(script); m != nil {
+		data, _ := e.FS.ReadFile(m[2])
+		if n, _ := strconv.Atoi(m[1]); n < len(data) {
+			data = data[:n]
+		}
+		fmt.Fprintf(e.Stdout, "%s  -\n", sha256Hex(data))
+		return 0, true
+	}
+
+	if m := rmCmd.FindStringSubmatch(script); m != nil {
+		e.FS.Remove(m[1])
+		return 0, true
+	}
+	return 0, false
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}